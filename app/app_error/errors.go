@@ -23,6 +23,33 @@ const (
 	ErrCodeCommentNotFound
 
 	ErrCodeTooManyRequests
+
+	ErrCodeInvalidInvitee
+	ErrCodeInvitationLimitExceeded
+	ErrCodeNotificationNotFound
+
+	ErrCodeInvalidStatusTransition
+	ErrCodeQuestionClosed
+	ErrCodeQuestionLocked
+
+	ErrCodeRevisionNotFound
+
+	ErrCodeDraftNotFound
+	ErrCodeDraftVersionConflict
+
+	ErrCodeUploadNotFound
+	ErrCodeFileTooLarge
+	ErrCodeUnsupportedFileType
+	ErrCodeInvalidSignature
+	ErrCodeInvalidImage
+
+	ErrCodeUserBlocked
+
+	ErrCodeInvalidHandle
+	ErrCodeHandleTaken
+	ErrCodeHandleChangeTooFrequent
+
+	ErrCodeDraftIncomplete
 )
 
 const (
@@ -33,6 +60,7 @@ const (
 	ErrCodeRedisCache
 	ErrCodeBloomFilter
 	ErrCodeInvalidJsonBody
+	ErrCodeBlobStore
 )
 
 var (
@@ -48,6 +76,32 @@ var (
 	ErrAnswerNotFound       = NewInputError("answer not found", ErrCodeAnswerNotFound, nil)
 	ErrCommentNotFound      = NewInputError("comment not found", ErrCodeCommentNotFound, nil)
 	ErrTooManyRequests      = NewInputError("too many requests", ErrCodeTooManyRequests, nil)
+
+	ErrInvalidInvitee          = NewInputError("invalid invitee", ErrCodeInvalidInvitee, nil)
+	ErrInvitationLimitExceeded = NewInputError("invitation limit exceeded", ErrCodeInvitationLimitExceeded, nil)
+	ErrNotificationNotFound    = NewInputError("notification not found", ErrCodeNotificationNotFound, nil)
+
+	ErrInvalidStatusTransition = NewInputError("invalid status transition", ErrCodeInvalidStatusTransition, nil)
+	ErrQuestionClosed          = NewInputError("question closed", ErrCodeQuestionClosed, nil)
+	ErrQuestionLocked          = NewInputError("question locked", ErrCodeQuestionLocked, nil)
+
+	ErrRevisionNotFound = NewInputError("revision not found", ErrCodeRevisionNotFound, nil)
+
+	ErrDraftNotFound        = NewInputError("draft not found", ErrCodeDraftNotFound, nil)
+	ErrDraftVersionConflict = NewInputError("draft version conflict", ErrCodeDraftVersionConflict, nil)
+	ErrDraftIncomplete      = NewInputError("draft title or content is empty", ErrCodeDraftIncomplete, nil)
+
+	ErrUploadNotFound      = NewInputError("upload not found", ErrCodeUploadNotFound, nil)
+	ErrFileTooLarge        = NewInputError("file too large", ErrCodeFileTooLarge, nil)
+	ErrUnsupportedFileType = NewInputError("unsupported file type", ErrCodeUnsupportedFileType, nil)
+	ErrInvalidSignature    = NewInputError("invalid or expired signature", ErrCodeInvalidSignature, nil)
+	ErrInvalidImage        = NewInputError("invalid image", ErrCodeInvalidImage, nil)
+
+	ErrUserBlocked = NewInputError("user blocked", ErrCodeUserBlocked, nil)
+
+	ErrInvalidHandle           = NewInputError("invalid handle", ErrCodeInvalidHandle, nil)
+	ErrHandleTaken             = NewInputError("handle taken", ErrCodeHandleTaken, nil)
+	ErrHandleChangeTooFrequent = NewInputError("handle changed too frequently", ErrCodeHandleChangeTooFrequent, nil)
 )
 
 var (
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"my_zhihu_backend/app/app_error"
+	"sync"
 	"time"
 )
 
@@ -38,13 +39,57 @@ func (r *Result[T]) Err(ctx context.Context) app_error.AppError {
 	}
 }
 
+const (
+	asyncQueueSize    = 1024            // 等待写入的队列长度 队列满时 PutChan 阻塞到 ctx 结束
+	asyncBatchSize    = 100             // 一次 MPut 最多写入的条目数
+	asyncBatchTimeout = 5 * time.Second // 一次 MPut 的超时时间
+)
+
+type asyncPut[T any] struct {
+	key    string
+	value  T
+	result *Result[T]
+}
+
+// AsyncCacher 异步执行 Cacher 的操作
+// PutChan 写入队列 由一个后台 goroutine 把队列中积累的写入合并成一次 MPut 其他操作每次调用启动一个 goroutine
+// 后台 goroutine 在第一次 PutChan 时启动 应该长期持有 AsyncCacher 而不是每次调用时创建
 type AsyncCacher[T any] struct {
-	base Cacher[T]
+	base  Cacher[T]
+	puts  chan asyncPut[T]
+	start sync.Once
 }
 
 func NewAsyncCacher[T any](base Cacher[T]) *AsyncCacher[T] {
 	return &AsyncCacher[T]{
 		base: base,
+		puts: make(chan asyncPut[T], asyncQueueSize),
+	}
+}
+
+// runPuts 取出队列中已有的写入 最多 asyncBatchSize 条 合并成一次 MPut 同一个 key 只保留最后一次写入
+func (a *AsyncCacher[T]) runPuts() {
+	for put := range a.puts {
+		batch := []asyncPut[T]{put}
+	drain:
+		for len(batch) < asyncBatchSize {
+			select {
+			case put := <-a.puts:
+				batch = append(batch, put)
+			default:
+				break drain
+			}
+		}
+		values := make(map[string]T, len(batch))
+		for _, put := range batch {
+			values[put.key] = put.value
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), asyncBatchTimeout)
+		err := a.base.MPut(ctx, values, nil)
+		cancel()
+		for _, put := range batch {
+			put.result.error <- err
+		}
 	}
 }
 
@@ -53,10 +98,15 @@ func (a *AsyncCacher[T]) BaseCacher() Cacher[T] {
 }
 
 func (a *AsyncCacher[T]) PutChan(ctx context.Context, key string, value T) *Result[T] {
+	a.start.Do(func() {
+		go a.runPuts()
+	})
 	result := NewResult[T]()
-	go func() {
-		result.error <- a.base.Put(ctx, key, value)
-	}()
+	select {
+	case a.puts <- asyncPut[T]{key: key, value: value, result: result}:
+	case <-ctx.Done():
+		result.error <- app_error.ErrTimeout
+	}
 	return result
 }
 
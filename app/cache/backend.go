@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"cmp"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend 缓存使用的存储 由 config.ServiceConfig.CacheBackend 选择
+type Backend string
+
+const (
+	BackendRedis  Backend = "redis"  // 使用 redis 和 RedisBloom 模块 多个实例共享缓存
+	BackendMemory Backend = "memory" // 使用进程内的实现 不需要 redis 只适合单实例部署和测试
+)
+
+// NewBloomFilterOn 按 backend 创建布隆过滤器
+func NewBloomFilterOn(backend Backend, name string, client *redis.Client) BloomFilter {
+	if backend == BackendMemory {
+		return NewLocalBloomFilter()
+	}
+	return NewRedisBloomFilter(name, client)
+}
+
+// NewJsonCacherOn 按 backend 创建 JsonCacher 或 MemoryCacher MemoryCacher 不需要序列化 忽略 opts
+func NewJsonCacherOn[T any](backend Backend, client *redis.Client, ttl time.Duration, prefix string, fallback Fallback[T], filter BloomFilter, opts ...CacherOption) Cacher[T] {
+	if backend == BackendMemory {
+		return NewMemoryCacher(ttl, prefix, fallback, filter)
+	}
+	return NewJsonCacher(client, ttl, prefix, fallback, filter, opts...)
+}
+
+// NewPlainCacherOn 按 backend 创建 PlainCacher 或 MemoryCacher MemoryCacher 忽略 opts
+func NewPlainCacherOn[T cmp.Ordered](backend Backend, client *redis.Client, ttl time.Duration, prefix string, fallback Fallback[T], filter BloomFilter, opts ...CacherOption) Cacher[T] {
+	if backend == BackendMemory {
+		return NewMemoryCacher(ttl, prefix, fallback, filter)
+	}
+	return NewPlainCacher(client, ttl, prefix, fallback, filter, opts...)
+}
+
+// NewTagIndexOn 按 backend 创建 TagIndex
+func NewTagIndexOn(backend Backend, client *redis.Client, prefix string) TagIndex {
+	if backend == BackendMemory {
+		return NewMemoryTagIndex(prefix)
+	}
+	return NewRedisTagIndex(client, prefix)
+}
+
+// NewKVStoreOn 按 backend 创建 KVStore
+func NewKVStoreOn(backend Backend, client *redis.Client) KVStore {
+	if backend == BackendMemory {
+		return NewMemoryKVStore()
+	}
+	return NewRedisKVStore(client)
+}
+
+// NewLexIndexOn 按 backend 创建 LexIndex
+func NewLexIndexOn(backend Backend, client *redis.Client, key string) LexIndex {
+	if backend == BackendMemory {
+		return NewMemoryLexIndex(key)
+	}
+	return NewRedisLexIndex(client, key)
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"iter"
+	"maps"
+	"math/rand/v2"
+	"my_zhihu_backend/app/app_error"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// mgetFallbackTimeout 批量读取共享的 fallback 的超时时间
+const mgetFallbackTimeout = 5 * time.Second
+
+// ttlOf ttls 中 key 的有效期 没有时使用 ttl
+func ttlOf(ttls map[string]time.Duration, key string, ttl time.Duration) time.Duration {
+	if t, ok := ttls[key]; ok {
+		return t
+	}
+	return ttl
+}
+
+// staggeredTTLs 为 keys 生成 ttl 的 1/2 到 1 之间的随机有效期 同一批写入的 key 不会同时过期
+// ttl 太短无法分散时返回 nil 使用默认的有效期
+func staggeredTTLs(ttl time.Duration, keys iter.Seq[string]) map[string]time.Duration {
+	half := ttl / 2
+	if half <= 0 {
+		return nil
+	}
+	ttls := make(map[string]time.Duration)
+	for key := range keys {
+		ttls[key] = half + rand.N(half)
+	}
+	return ttls
+}
+
+// mget 通过 read 只读缓存 未命中的 key 通过一次 fallback 加载并通过 MPut 写回 写回失败只记录日志
+// 写回的 key 与预热相同 有效期随机设置为 TTL 的 1/2 到 1 之间
+// 同一批未命中的 key 同一时间只执行一次 fallback 批量读取不检查布隆过滤器 由 fallback 一次查询兜底
+func mget[T any](ctx context.Context, cacher Cacher[T], read func(ctx context.Context, keys []string) ([]*T, app_error.AppError),
+	single *singleflight.Group, stats *Stats, keys []string, fallback BatchFallback[T]) ([]*T, app_error.AppError) {
+	values, err := read(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if fallback == nil {
+		return values, nil
+	}
+	var misses []string
+	for i, value := range values {
+		if value == nil {
+			misses = append(misses, keys[i])
+		}
+	}
+	if len(misses) == 0 {
+		return values, nil
+	}
+
+	// 加上前缀 避免与 Get 使用的 key 冲突
+	// fallback 的结果由同一批的所有调用者共享 不能因为第一个调用者取消而让其他调用者一起失败
+	res, ferr, shared := single.Do("mget\x00"+strings.Join(misses, "\x00"), func() (interface{}, error) {
+		timeout, cancel := context.WithTimeout(context.WithoutCancel(ctx), mgetFallbackTimeout)
+		defer cancel()
+		start := time.Now()
+		loaded, err := fallback(timeout, misses)
+		stats.fallback(start, err)
+		if err != nil {
+			return nil, err
+		}
+		return loaded, nil
+	})
+	stats.share(shared)
+	if ferr != nil {
+		return nil, ferr.(app_error.AppError)
+	}
+	loaded := res.(map[string]*T)
+	puts := make(map[string]T, len(loaded))
+	for i, key := range keys {
+		if values[i] != nil {
+			continue
+		}
+		if value := loaded[key]; value != nil {
+			values[i] = value
+			puts[key] = *value
+		}
+	}
+	if len(puts) > 0 {
+		if err := cacher.MPut(ctx, puts, staggeredTTLs(cacher.TTL(), maps.Keys(puts))); err != nil {
+			l.Warn("failed to write back cache", append(err.ErrorField(), zap.String("prefix", cacher.Prefix()), zap.Int("count", len(puts)))...)
+		}
+	}
+	return values, nil
+}
@@ -3,80 +3,238 @@ package cache
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
 	"my_zhihu_backend/app/app_error"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
-type BloomFilter struct {
-	client *redis.Client
-	name   string
+// 布隆过滤器的默认参数 与 RedisBloomFilter.Init 使用的参数相同
+const (
+	bloomErrorRate = 0.05
+	bloomCapacity  = 1000000
+)
+
+// BloomFilter 布隆过滤器 RedisBloomFilter 使用 redis 的 RedisBloom 模块 LocalBloomFilter 在进程内实现
+type BloomFilter interface {
+	Init(ctx context.Context) app_error.AppError
+	Add(ctx context.Context, value any) app_error.AppError
+	MAdd(ctx context.Context, values ...any) app_error.AppError
+	AddChan(ctx context.Context, value any) <-chan app_error.AppError      // 业务执行时在后台将数据异步存入布隆过滤器
+	MAddChan(ctx context.Context, values ...any) <-chan app_error.AppError // 业务执行时在后台将数据异步存入布隆过滤器
+	Exist(ctx context.Context, value any) (bool, app_error.AppError)
+}
+
+// addChan 在后台执行 add 结果写入返回的 channel
+func addChan(ctx context.Context, add func() error) <-chan app_error.AppError {
+	errC := make(chan app_error.AppError, 1)
+	go func() {
+		if err := add(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				errC <- app_error.ErrTimeout.WithError(err)
+			} else {
+				errC <- app_error.ErrBloomFilter.WithError(err)
+			}
+			return
+		}
+		errC <- nil
+	}()
+	return errC
+}
+
+// moduleMissing 判断错误是否是因为 redis 没有加载 RedisBloom 模块
+func moduleMissing(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown command")
+}
+
+// RedisBloomFilter redis 没有加载 RedisBloom 模块时 所有写入都会被忽略 Exist 总是返回 true 相当于不使用布隆过滤器
+type RedisBloomFilter struct {
+	client      *redis.Client
+	name        string
+	unavailable atomic.Bool // 已确认 RedisBloom 模块不可用
+}
+
+// check 处理 RedisBloom 命令返回的错误 模块不可用时记录日志并返回 nil
+func (b *RedisBloomFilter) check(err error) error {
+	if moduleMissing(err) {
+		if !b.unavailable.Swap(true) {
+			l.Warn("RedisBloom module is missing, bloom filter disabled", zap.String("filter", b.name), zap.Error(err))
+		}
+		return nil
+	}
+	return err
+}
+
+// Available RedisBloom 模块是否可用 第一次访问前总是返回 true
+func (b *RedisBloomFilter) Available() bool {
+	return !b.unavailable.Load()
 }
 
-func NewBloomFilter(name string, client *redis.Client) *BloomFilter {
-	return &BloomFilter{
+// redisBloomFilters 已创建的 RedisBloomFilter 按名称记录 用于查看状态
+var redisBloomFilters sync.Map
+
+func NewRedisBloomFilter(name string, client *redis.Client) *RedisBloomFilter {
+	filter := &RedisBloomFilter{
 		client: client,
 		name:   name,
 	}
+	redisBloomFilters.Store(name, filter)
+	return filter
+}
+
+// BloomFilterAvailability 各个 RedisBloomFilter 的 RedisBloom 模块是否可用
+func BloomFilterAvailability() map[string]bool {
+	availability := make(map[string]bool)
+	redisBloomFilters.Range(func(name, filter any) bool {
+		availability[name.(string)] = filter.(*RedisBloomFilter).Available()
+		return true
+	})
+	return availability
 }
 
-func (b *BloomFilter) Init(ctx context.Context) app_error.AppError {
-	if err := b.client.BFReserve(ctx, b.name, 0.05, 1000000).Err(); err != nil {
+func (b *RedisBloomFilter) Init(ctx context.Context) app_error.AppError {
+	if err := b.check(b.client.BFReserve(ctx, b.name, bloomErrorRate, bloomCapacity).Err()); err != nil {
 		return app_error.ErrBloomFilter.WithError(err)
 	}
 	return nil
 }
 
-func (b *BloomFilter) Add(ctx context.Context, value any) app_error.AppError {
-	if _, err := b.client.BFAdd(ctx, b.name, value).Result(); err != nil {
+func (b *RedisBloomFilter) Add(ctx context.Context, value any) app_error.AppError {
+	if b.unavailable.Load() {
+		return nil
+	}
+	if err := b.check(b.client.BFAdd(ctx, b.name, value).Err()); err != nil {
 		return app_error.ErrBloomFilter.WithError(err)
 	}
 	return nil
 }
 
-func (b *BloomFilter) MAdd(ctx context.Context, values ...any) app_error.AppError {
-	if _, err := b.client.BFMAdd(ctx, b.name, values...).Result(); err != nil {
+func (b *RedisBloomFilter) MAdd(ctx context.Context, values ...any) app_error.AppError {
+	if b.unavailable.Load() {
+		return nil
+	}
+	if err := b.check(b.client.BFMAdd(ctx, b.name, values...).Err()); err != nil {
 		return app_error.ErrBloomFilter.WithError(err)
 	}
 	return nil
 }
 
-// AddChan 业务执行时在后台将数据异步存入布隆过滤器
-func (b *BloomFilter) AddChan(ctx context.Context, value any) <-chan app_error.AppError {
-	errC := make(chan app_error.AppError, 1)
-	go func() {
-		if _, err := b.client.BFAdd(ctx, b.name, value).Result(); err != nil {
-			if errors.Is(err, context.DeadlineExceeded) {
-				errC <- app_error.ErrTimeout.WithError(err)
-			} else {
-				errC <- app_error.ErrBloomFilter.WithError(err)
-			}
+func (b *RedisBloomFilter) AddChan(ctx context.Context, value any) <-chan app_error.AppError {
+	return addChan(ctx, func() error {
+		if b.unavailable.Load() {
+			return nil
 		}
-		errC <- nil
-	}()
-	return errC
+		return b.check(b.client.BFAdd(ctx, b.name, value).Err())
+	})
 }
 
-// MAddChan 业务执行时在后台将数据异步存入布隆过滤器
-func (b *BloomFilter) MAddChan(ctx context.Context, values ...any) <-chan app_error.AppError {
-	errC := make(chan app_error.AppError, 1)
-	go func() {
-		if _, err := b.client.BFMAdd(ctx, b.name, values...).Result(); err != nil {
-			if errors.Is(err, context.DeadlineExceeded) {
-				errC <- app_error.ErrTimeout.WithError(err)
-			} else {
-				errC <- app_error.ErrBloomFilter.WithError(err)
-			}
+func (b *RedisBloomFilter) MAddChan(ctx context.Context, values ...any) <-chan app_error.AppError {
+	return addChan(ctx, func() error {
+		if b.unavailable.Load() {
+			return nil
 		}
-		errC <- nil
-	}()
+		return b.check(b.client.BFMAdd(ctx, b.name, values...).Err())
+	})
+}
+
+func (b *RedisBloomFilter) Exist(ctx context.Context, value any) (bool, app_error.AppError) {
+	if b.unavailable.Load() {
+		return true, nil
+	}
+	exists, err := b.client.BFExists(ctx, b.name, value).Result()
+	if err != nil {
+		if err := b.check(err); err != nil {
+			return false, app_error.ErrBloomFilter.WithError(err)
+		}
+		return true, nil
+	}
+	return exists, nil
+}
+
+// LocalBloomFilter 进程内的布隆过滤器 参数与 RedisBloomFilter.Init 相同 位数组在第一次写入时分配
+// 数据不会在实例之间共享 只适合单实例部署和测试
+type LocalBloomFilter struct {
+	mu   sync.RWMutex
+	bits []uint64
+	m    uint64 // 位数
+	k    uint64 // 哈希函数个数
+}
+
+func NewLocalBloomFilter() *LocalBloomFilter {
+	m := uint64(math.Ceil(-bloomCapacity * math.Log(bloomErrorRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/bloomCapacity*math.Ln2)))
+	return &LocalBloomFilter{m: m, k: k}
+}
+
+// locations 使用 double hashing 由两个 64 位哈希生成 k 个位置
+func (b *LocalBloomFilter) locations(value any) []uint64 {
+	h := fnv.New128a()
+	_, _ = fmt.Fprint(h, value)
+	sum := h.Sum(nil)
+	var h1, h2 uint64
+	for i := range 8 {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[i+8])
+	}
+	locs := make([]uint64, b.k)
+	for i := range b.k {
+		locs[i] = (h1 + i*h2) % b.m
+	}
+	return locs
+}
+
+// Init 清空过滤器
+func (b *LocalBloomFilter) Init(_ context.Context) app_error.AppError {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bits = nil
+	return nil
+}
+
+func (b *LocalBloomFilter) Add(ctx context.Context, value any) app_error.AppError {
+	return b.MAdd(ctx, value)
+}
+
+func (b *LocalBloomFilter) MAdd(_ context.Context, values ...any) app_error.AppError {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bits == nil {
+		b.bits = make([]uint64, (b.m+63)/64)
+	}
+	for _, value := range values {
+		for _, loc := range b.locations(value) {
+			b.bits[loc/64] |= 1 << (loc % 64)
+		}
+	}
+	return nil
+}
+
+func (b *LocalBloomFilter) AddChan(ctx context.Context, value any) <-chan app_error.AppError {
+	return b.MAddChan(ctx, value)
+}
+
+func (b *LocalBloomFilter) MAddChan(ctx context.Context, values ...any) <-chan app_error.AppError {
+	errC := make(chan app_error.AppError, 1)
+	errC <- b.MAdd(ctx, values...)
 	return errC
 }
 
-func (b *BloomFilter) Exist(ctx context.Context, value any) (bool, app_error.AppError) {
-	if exists, err := b.client.BFExists(ctx, b.name, value).Result(); err != nil {
-		return false, app_error.ErrBloomFilter.WithError(err)
-	} else {
-		return exists, nil
+func (b *LocalBloomFilter) Exist(_ context.Context, value any) (bool, app_error.AppError) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.bits == nil {
+		return false, nil
+	}
+	for _, loc := range b.locations(value) {
+		if b.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false, nil
+		}
 	}
+	return true, nil
 }
@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// bloomRebuildLockTTL 重建锁的有效期 多个实例同时运行时只有一个实例重建同一个过滤器
+const bloomRebuildLockTTL = 10 * time.Minute
+
+// BloomSource 从数据库中分批读取应该存在于布隆过滤器中的值 从 after 之后开始读取 通过 add 写入
+// 返回最后读取的位置 用于重建完成后补充重建期间新增的数据
+type BloomSource func(ctx context.Context, after int64, add func(values ...any) error) (last int64, err error)
+
+// FillBloomFilter 把 source 中的所有值写入 filter memory 存储下启动时用来填充进程内的过滤器
+func FillBloomFilter(ctx context.Context, filter BloomFilter, source BloomSource) error {
+	_, err := source(ctx, 0, func(values ...any) error {
+		if err := filter.MAdd(ctx, values...); err != nil {
+			return err
+		}
+		return nil
+	})
+	return err
+}
+
+// BloomManager 管理 RedisBloomFilter 的生命周期
+// 启动时按 RedisBloomFilter.Init 的参数创建过滤器 之后定期或在估计的误判率超过阈值时轮换
+// 有 BloomSource 的过滤器从数据库重建 没有的直接清空 重建时写入新的 key 完成后通过 RENAME 原子地替换
+type BloomManager struct {
+	client    *redis.Client
+	interval  time.Duration // 定期轮换的间隔
+	threshold float64       // 估计的误判率超过该值时轮换
+	check     time.Duration // 检查误判率的间隔
+
+	mu        sync.Mutex
+	sources   map[string]BloomSource
+	rebuiltAt map[string]time.Time
+}
+
+func NewBloomManager(client *redis.Client, interval time.Duration, threshold float64, check time.Duration) *BloomManager {
+	return &BloomManager{
+		client:    client,
+		interval:  interval,
+		threshold: threshold,
+		check:     check,
+		sources:   make(map[string]BloomSource),
+		rebuiltAt: make(map[string]time.Time),
+	}
+}
+
+// SetSource 设置重建过滤器时使用的数据来源
+func (m *BloomManager) SetSource(name string, source BloomSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sources[name] = source
+}
+
+// Populated 过滤器是否已经从 BloomSource 重建过或者启动时已经存在 在此之前被拦截的值不一定不存在
+func (m *BloomManager) Populated(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.rebuiltAt[name]
+	return ok
+}
+
+func (m *BloomManager) filters() []*RedisBloomFilter {
+	var filters []*RedisBloomFilter
+	redisBloomFilters.Range(func(_, filter any) bool {
+		filters = append(filters, filter.(*RedisBloomFilter))
+		return true
+	})
+	return filters
+}
+
+// Run 创建所有已注册的过滤器 之后按 check 间隔检查是否需要轮换 直到 ctx 结束
+func (m *BloomManager) Run(ctx context.Context) {
+	now := time.Now()
+	for _, filter := range m.filters() {
+		err := filter.Init(ctx)
+		if err != nil && !strings.Contains(err.Error(), "exists") {
+			l.Error("failed to reserve bloom filter", append(err.ErrorField(), zap.String("filter", filter.name))...)
+			continue
+		}
+		if err != nil { // 已经存在的过滤器中有之前写入的数据 按间隔轮换即可
+			m.mu.Lock()
+			m.rebuiltAt[filter.name] = now
+			m.mu.Unlock()
+		}
+	}
+	// 刚创建的过滤器是空的 没有记录重建时间 在这里立即从 BloomSource 重建 否则已有的数据在轮换前都会被当作不存在
+	m.checkAll(ctx, now)
+
+	ticker := time.NewTicker(m.check)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.checkAll(ctx, now)
+		}
+	}
+}
+
+func (m *BloomManager) checkAll(ctx context.Context, now time.Time) {
+	for _, filter := range m.filters() {
+		if !filter.Available() {
+			continue
+		}
+		m.mu.Lock()
+		rebuiltAt, ok := m.rebuiltAt[filter.name]
+		m.mu.Unlock()
+		reason := scheduledRotation(now, rebuiltAt, ok, m.interval)
+		if reason == "" {
+			info, err := m.client.BFInfo(ctx, filter.name).Result()
+			if err != nil {
+				if filter.check(err) != nil {
+					l.Warn("failed to get bloom filter info", zap.String("filter", filter.name), zap.Error(err))
+				}
+				continue
+			}
+			reason = capacityRotation(info.Capacity, info.ItemsInserted, m.threshold)
+		}
+		if reason == "" {
+			continue
+		}
+		if err := m.Rebuild(ctx, filter); err != nil {
+			l.Error("failed to rebuild bloom filter", zap.String("filter", filter.name), zap.String("reason", reason), zap.Error(err))
+			continue
+		}
+		l.Info("bloom filter rebuilt", zap.String("filter", filter.name), zap.String("reason", reason))
+	}
+}
+
+// scheduledRotation 按重建时间判断是否需要轮换 返回原因 不需要时返回空字符串
+// rebuilt 为 false 表示过滤器是刚创建的或者状态未知 需要立即重建
+func scheduledRotation(now, rebuiltAt time.Time, rebuilt bool, interval time.Duration) string {
+	if !rebuilt {
+		return "not populated"
+	}
+	if now.Sub(rebuiltAt) >= interval {
+		return "scheduled"
+	}
+	return ""
+}
+
+// capacityRotation 按 BF.INFO 的容量和写入的数量判断是否需要轮换 返回原因 不需要时返回空字符串
+func capacityRotation(capacity, inserted int64, threshold float64) string {
+	if capacity < bloomCapacity {
+		return "reserved with default parameters" // BF.ADD 在过滤器不存在时使用默认参数自动创建
+	}
+	if estimateFalsePositiveRate(inserted) > threshold {
+		return "false positive rate too high"
+	}
+	return ""
+}
+
+// estimateFalsePositiveRate 按创建时的位数和哈希函数个数估计写入 n 个值后的误判率
+// RedisBloom 在写满后会扩容 实际误判率会低一些 这里用于判断过滤器是否已经写入过多
+func estimateFalsePositiveRate(n int64) float64 {
+	m := -bloomCapacity * math.Log(bloomErrorRate) / (math.Ln2 * math.Ln2)
+	k := math.Ceil(-math.Log2(bloomErrorRate))
+	return math.Pow(1-math.Exp(-k*float64(n)/m), k)
+}
+
+// Rebuild 在新的 key 中重建过滤器并替换 重建期间其他实例写入旧过滤器的值在替换后从 BloomSource 补充
+func (m *BloomManager) Rebuild(ctx context.Context, filter *RedisBloomFilter) error {
+	lockKey := filter.name + "::lock"
+	if ok, err := m.client.SetNX(ctx, lockKey, time.Now().Unix(), bloomRebuildLockTTL).Result(); err != nil {
+		return err
+	} else if !ok {
+		return nil // 其他实例正在重建
+	}
+	defer m.client.Del(context.WithoutCancel(ctx), lockKey)
+
+	tmpKey := filter.name + "::rebuilding"
+	if err := m.client.Del(ctx, tmpKey).Err(); err != nil {
+		return err
+	}
+	if err := m.client.BFReserve(ctx, tmpKey, bloomErrorRate, bloomCapacity).Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	source := m.sources[filter.name]
+	m.mu.Unlock()
+
+	var last int64
+	if source != nil {
+		var err error
+		last, err = source(ctx, 0, func(values ...any) error {
+			return m.client.BFMAdd(ctx, tmpKey, values...).Err()
+		})
+		if err != nil {
+			m.client.Del(ctx, tmpKey)
+			return err
+		}
+	}
+	if err := m.client.Rename(ctx, tmpKey, filter.name).Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.rebuiltAt[filter.name] = time.Now()
+	m.mu.Unlock()
+
+	if source != nil {
+		if _, err := source(ctx, last, func(values ...any) error {
+			return filter.MAdd(ctx, values...)
+		}); err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	return nil
+}
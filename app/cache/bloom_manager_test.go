@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomRotation(t *testing.T) {
+	now := time.Now()
+	t.Run("Test_Scheduled", func(t *testing.T) {
+		assert.Equal(t, "not populated", scheduledRotation(now, time.Time{}, false, time.Hour)) // 刚创建的过滤器立即重建
+		assert.Equal(t, "", scheduledRotation(now, now.Add(-time.Minute), true, time.Hour))
+		assert.Equal(t, "scheduled", scheduledRotation(now, now.Add(-time.Hour), true, time.Hour))
+	})
+
+	t.Run("Test_Capacity", func(t *testing.T) {
+		assert.Equal(t, "reserved with default parameters", capacityRotation(100, 0, 0.1))
+		assert.Equal(t, "", capacityRotation(bloomCapacity, bloomCapacity/2, 0.1))
+		assert.Equal(t, "false positive rate too high", capacityRotation(bloomCapacity, 2*bloomCapacity, 0.1))
+	})
+
+	t.Run("Test_Estimate", func(t *testing.T) {
+		assert.Equal(t, 0.0, estimateFalsePositiveRate(0))
+		assert.InDelta(t, bloomErrorRate, estimateFalsePositiveRate(bloomCapacity), 0.01) // 写满时接近创建时的误判率
+		previous := 0.0
+		for n := int64(bloomCapacity / 10); n <= 10*bloomCapacity; n += bloomCapacity / 10 {
+			rate := estimateFalsePositiveRate(n)
+			assert.Greater(t, rate, previous)
+			previous = rate
+		}
+		assert.Greater(t, previous, 0.5)
+	})
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常访问
+	BreakerOpen                         // 连续失败 暂停访问
+	BreakerHalfOpen                     // 冷却结束 允许一个请求试探
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// Breaker 熔断器 连续失败 threshold 次后打开 cooldown 后放行一个请求试探 成功则关闭 失败则重新打开
+type Breaker struct {
+	name      string
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	threshold int
+	cooldown  time.Duration
+	openedAt  time.Time
+	probing   bool // 半开状态下已有请求在试探
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{name: name, threshold: threshold, cooldown: cooldown}
+}
+
+// RedisBreaker 缓存包中所有 redis 访问共用的熔断器
+var RedisBreaker = NewBreaker("redis", 5, 10*time.Second)
+
+// Configure 修改熔断的阈值和冷却时间
+func (b *Breaker) Configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold, b.cooldown = threshold, cooldown
+}
+
+// allow 是否允许本次访问
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state, b.probing = BreakerHalfOpen, true
+		l.Info("circuit breaker half-open", zap.String("breaker", b.name))
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerClosed {
+		l.Info("circuit breaker closed", zap.String("breaker", b.name))
+	}
+	b.state, b.failures, b.probing = BreakerClosed, 0, false
+}
+
+func (b *Breaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.state, b.openedAt, b.probing = BreakerOpen, time.Now(), false
+		l.Warn("circuit breaker opened", zap.String("breaker", b.name), zap.Int("failures", b.failures), zap.Error(err))
+	}
+}
+
+// release 本次访问的结果不能说明 redis 是否可用 既不算成功也不算失败 半开状态下允许下一个请求试探
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Do 熔断器关闭时执行 fn 打开时直接返回 ErrBreakerOpen
+// redis.Nil 不算作失败 ctx 已经取消或超时导致的错误是调用方的原因 也不计入失败次数
+func (b *Breaker) Do(ctx context.Context, fn func() error) error {
+	if !b.allow() {
+		return ErrBreakerOpen
+	}
+	err := fn()
+	switch {
+	case err == nil || errors.Is(err, redis.Nil):
+		b.success()
+	case ctx.Err() != nil:
+		b.release()
+	default:
+		b.failure(err)
+	}
+	return err
+}
+
+// BreakerStatus 熔断器的状态
+type BreakerStatus struct {
+	Name     string
+	State    BreakerState
+	Failures int
+	OpenedAt time.Time
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStatus{Name: b.name, State: b.state, Failures: b.failures, OpenedAt: b.openedAt}
+}
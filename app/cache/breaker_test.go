@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	b := NewBreaker("test", 2, 50*time.Millisecond)
+	ctx := context.TODO()
+	fail := func() error { return errors.New("connection refused") }
+
+	assert.NoError(t, b.Do(ctx, func() error { return nil }))
+	assert.ErrorIs(t, b.Do(ctx, func() error { return redis.Nil }), redis.Nil) // 未命中不算作失败
+	assert.Error(t, b.Do(ctx, fail))
+	assert.Equal(t, BreakerClosed, b.Status().State)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for range 3 { // 调用方取消导致的错误不算作失败
+		assert.ErrorIs(t, b.Do(canceled, canceled.Err), context.Canceled)
+	}
+	assert.Equal(t, BreakerClosed, b.Status().State)
+	assert.Equal(t, 1, b.Status().Failures)
+	assert.Error(t, b.Do(ctx, fail))
+	assert.Equal(t, BreakerOpen, b.Status().State)
+	assert.ErrorIs(t, b.Do(ctx, func() error { return nil }), ErrBreakerOpen)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Error(t, b.Do(ctx, fail)) // 试探失败 重新打开
+	assert.Equal(t, BreakerOpen, b.Status().State)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Error(t, b.Do(canceled, canceled.Err)) // 试探被调用方取消 下一个请求继续试探
+	assert.Equal(t, BreakerHalfOpen, b.Status().State)
+	assert.NoError(t, b.Do(ctx, func() error { return nil }))
+	assert.Equal(t, BreakerClosed, b.Status().State)
+	assert.Equal(t, 0, b.Status().Failures)
+}
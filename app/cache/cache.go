@@ -8,16 +8,22 @@ import (
 
 type Fallback[T any] func(ctx context.Context, args ...any) (*T, app_error.AppError) // 查询缓存不存在或者无效的时候执行 Fallback 回调并且将结果重新写入缓存
 
+// BatchFallback 批量读取时一次加载所有未命中的 key 不存在的 key 不出现在结果中
+type BatchFallback[T any] func(ctx context.Context, keys []string) (map[string]*T, app_error.AppError)
+
 type Cacher[T any] interface {
 	Put(ctx context.Context, key string, value T) app_error.AppError
 	Get(ctx context.Context, key string, args ...any) (*T, app_error.AppError)
+	MGet(ctx context.Context, keys []string, fallback BatchFallback[T]) ([]*T, app_error.AppError)   // 通过 pipeline 批量读取 结果与 keys 一一对应 未命中的 key 通过一次 fallback 加载并写回 不存在的位置为 nil fallback 为 nil 时只读缓存
+	MPut(ctx context.Context, values map[string]T, ttls map[string]time.Duration) app_error.AppError // 通过 pipeline 批量写入 ttls 中没有的 key 使用 TTL()
+	MInvalidate(ctx context.Context, keys []string) app_error.AppError                               // 批量删除 不返回旧值
 	Renew(ctx context.Context, key string, ttl time.Duration) app_error.AppError
 	Invalidate(ctx context.Context, key string) (*T, app_error.AppError)
 
 	Fallback() Fallback[T]
 	TTL() time.Duration
 	Prefix() string
-	BloomFilter() *BloomFilter
+	BloomFilter() BloomFilter
 }
 
 type AsyncCacherDecorator[T any] interface {
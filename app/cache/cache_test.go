@@ -22,13 +22,16 @@ func TestJsonCacher_WithContainer(t *testing.T) {
 	client := redis.NewClient(&redis.Options{
 		Addr: "localhost:6379", // TODO: 使用 testcontainer 报错 目前回退本地redis服务
 	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("redis is not available:", err)
+	}
 
 	fallbackCalled := 0
 	expectedUser := &TestUser{ID: 1, Name: "Tester"}
 
-	bloom := NewBloomFilter("test", client)
+	bloom := NewRedisBloomFilter("test", client)
 
-	cacher := NewJsonCacher[TestUser](client, time.Minute, "test:", func(ctx context.Context) (*TestUser, app_error.AppError) {
+	cacher := NewJsonCacher[TestUser](client, time.Minute, "test:", func(ctx context.Context, args ...any) (*TestUser, app_error.AppError) {
 		fallbackCalled++
 		return expectedUser, nil
 	}, bloom)
@@ -55,3 +58,166 @@ func TestJsonCacher_WithContainer(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestMemoryCacher(t *testing.T) {
+	ctx := context.TODO()
+
+	fallbackCalled := 0
+	expectedUser := &TestUser{ID: 1, Name: "Tester"}
+
+	bloom := NewLocalBloomFilter()
+	cacher := NewMemoryCacher[TestUser](time.Minute, "memory-test:", func(ctx context.Context, args ...any) (*TestUser, app_error.AppError) {
+		fallbackCalled++
+		return expectedUser, nil
+	}, bloom)
+
+	t.Run("Test_Bloom_Reject", func(t *testing.T) {
+		_, err := cacher.Get(ctx, "user_1")
+		assert.ErrorIs(t, err, app_error.ErrRedisCacheKeyNotExists)
+		assert.Equal(t, 0, fallbackCalled)
+	})
+
+	assert.Nil(t, bloom.Add(ctx, "memory-test:user_1"))
+
+	t.Run("Test_Get_And_Fallback", func(t *testing.T) {
+		val, err := cacher.Get(ctx, "user_1")
+		assert.Nil(t, err)
+		assert.Equal(t, "Tester", val.Name)
+		assert.Equal(t, 1, fallbackCalled)
+
+		val2, _ := cacher.Get(ctx, "user_1")
+		assert.Equal(t, val, val2)
+		assert.Equal(t, 1, fallbackCalled)
+
+		values, err := cacher.MGet(ctx, []string{"user_1", "user_2"}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "Tester", values[0].Name)
+		assert.Nil(t, values[1])
+	})
+
+	t.Run("Test_Invalidate", func(t *testing.T) {
+		val, err := cacher.Invalidate(ctx, "user_1")
+		assert.Nil(t, err)
+		assert.Equal(t, "Tester", val.Name)
+		assert.ErrorIs(t, cacher.Renew(ctx, "user_1", time.Minute), app_error.ErrRedisCacheKeyNotExists)
+	})
+
+	t.Run("Test_Tag_Purge", func(t *testing.T) {
+		assert.Nil(t, cacher.Put(ctx, "user_2", TestUser{ID: 2, Name: "Other"}))
+		tags := NewMemoryTagIndex("memory-test-tag:")
+		assert.Nil(t, tags.Tag(ctx, "memory-test:user_2", time.Minute, UserTag(2)))
+		gen, _ := tags.Generation(ctx, UserTag(2))
+		assert.Nil(t, tags.Purge(ctx, UserTag(2)))
+		current, _ := tags.Generation(ctx, UserTag(2))
+		assert.Equal(t, gen+1, current)
+		values, _ := cacher.MGet(ctx, []string{"user_2"}, nil)
+		assert.Nil(t, values[0])
+	})
+
+	t.Run("Test_MGet_Batch_Fallback", func(t *testing.T) {
+		assert.Nil(t, cacher.Put(ctx, "user_3", TestUser{ID: 3, Name: "Cached"}))
+		var requested []string
+		loader := func(ctx context.Context, keys []string) (map[string]*TestUser, app_error.AppError) {
+			requested = append(requested, keys...)
+			return map[string]*TestUser{"user_4": {ID: 4, Name: "Loaded"}}, nil
+		}
+		values, err := cacher.MGet(ctx, []string{"user_3", "user_4", "user_5"}, loader)
+		assert.Nil(t, err)
+		assert.Equal(t, "Cached", values[0].Name)
+		assert.Equal(t, "Loaded", values[1].Name)
+		assert.Nil(t, values[2])
+		assert.Equal(t, []string{"user_4", "user_5"}, requested)
+
+		values, _ = cacher.MGet(ctx, []string{"user_4"}, nil)
+		assert.Equal(t, "Loaded", values[0].Name)
+
+		assert.Nil(t, cacher.MInvalidate(ctx, []string{"user_3", "user_4"}))
+		values, _ = cacher.MGet(ctx, []string{"user_3", "user_4"}, nil)
+		assert.Nil(t, values[0])
+		assert.Nil(t, values[1])
+	})
+}
+
+func TestLocalBloomFilter(t *testing.T) {
+	ctx := context.TODO()
+	bloom := NewLocalBloomFilter()
+	assert.Nil(t, bloom.MAdd(ctx, "a", 1))
+	for _, v := range []any{"a", 1} {
+		exists, err := bloom.Exist(ctx, v)
+		assert.Nil(t, err)
+		assert.True(t, exists)
+	}
+	exists, _ := bloom.Exist(ctx, "b")
+	assert.False(t, exists)
+	assert.Nil(t, <-bloom.AddChan(ctx, "b"))
+	exists, _ = bloom.Exist(ctx, "b")
+	assert.True(t, exists)
+
+	assert.Nil(t, bloom.Init(ctx))
+	exists, _ = bloom.Exist(ctx, "a")
+	assert.False(t, exists)
+}
+
+func TestMemoryStores(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Test_KVStore", func(t *testing.T) {
+		store := NewMemoryKVStore()
+		assert.Nil(t, store.Set(ctx, "memory-kv:1", "token", time.Minute))
+		value, err := store.Get(ctx, "memory-kv:1")
+		assert.Nil(t, err)
+		assert.Equal(t, "token", value)
+		assert.Nil(t, store.Del(ctx, "memory-kv:1"))
+		_, err = store.Get(ctx, "memory-kv:1")
+		assert.ErrorIs(t, err, app_error.ErrRedisCacheKeyNotExists)
+	})
+
+	t.Run("Test_LexIndex", func(t *testing.T) {
+		index := NewMemoryLexIndex("memory-lex")
+		exists, _ := index.Exists(ctx)
+		assert.False(t, exists)
+		assert.Nil(t, index.Update(ctx, "", "bob\x002"))
+		assert.Nil(t, index.Rebuild(ctx, func(add func(members ...string) error) error {
+			return add("alice\x001", "bob\x002", "alex\x003")
+		}))
+		exists, _ = index.Exists(ctx)
+		assert.True(t, exists)
+		members, err := index.Range(ctx, "al", 10)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"alex\x003", "alice\x001"}, members)
+		assert.Nil(t, index.Update(ctx, "alex\x003", "carol\x003"))
+		members, _ = index.Range(ctx, "", 2)
+		assert.Equal(t, []string{"alice\x001", "bob\x002"}, members)
+		members, _ = index.Range(ctx, "a", 10)
+		assert.Equal(t, []string{"alice\x001"}, members)
+	})
+
+	t.Run("Test_TagIndex", func(t *testing.T) {
+		store := defaultMemoryStore
+		index := NewMemoryTagIndex("memory-tag:")
+		store.set("memory-tag-key:1", "a", time.Minute)
+		store.set("memory-tag-key:2", "b", time.Hour)
+		assert.Nil(t, index.Tag(ctx, "memory-tag-key:1", time.Minute, "t1"))
+		assert.Nil(t, index.Tag(ctx, "memory-tag-key:2", time.Hour, "t2"))
+
+		// 过期的键从集合中删除 过期的集合整个删除
+		store.sweep(time.Now().Add(2 * time.Minute))
+		store.mu.Lock()
+		_, ok := store.sets["memory-tag:t1"]
+		assert.False(t, ok)
+		assert.Len(t, store.sets["memory-tag:t2"].members, 1)
+		store.mu.Unlock()
+
+		gen, _ := index.Generation(ctx, "t2")
+		assert.Nil(t, index.Purge(ctx, "t2"))
+		next, _ := index.Generation(ctx, "t2")
+		assert.Equal(t, gen+1, next)
+		_, ok = store.get("memory-tag-key:2")
+		assert.False(t, ok)
+
+		// 清除次数与其他条目一样过期
+		store.sweep(time.Now().Add(tagGenerationTTL + time.Minute))
+		next, _ = index.Generation(ctx, "t2")
+		assert.Equal(t, int64(0), next)
+	})
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec 缓存值的序列化方式 ID 写入缓存值的头部 更换 Codec 后旧的缓存值会被视为失效
+type Codec interface {
+	ID() byte
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSON    Codec = jsonCodec{}
+	Msgpack Codec = msgpackCodec{} // 使用 json tag 与 JSON 的字段一致 json:"-" 的字段不会写入缓存
+	Gob     Codec = gobCodec{}     // 忽略 json tag 所有导出的字段都会写入缓存
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte                           { return 1 }
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte     { return 2 }
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte     { return 3 }
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+const (
+	zstdFlagRaw  byte = 0
+	zstdFlagZstd byte = 1
+)
+
+// EncodeAll 和 DecodeAll 可以并发使用
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// zstdCodec 序列化后超过 threshold 字节的数据使用 zstd 压缩 数据前一个字节标明是否压缩
+type zstdCodec struct {
+	base      Codec
+	threshold int
+}
+
+// Compressed 在 codec 的基础上对超过 threshold 字节的数据使用 zstd 压缩
+func Compressed(codec Codec, threshold int) Codec {
+	return zstdCodec{base: codec, threshold: threshold}
+}
+
+func (c zstdCodec) ID() byte     { return c.base.ID() | 0x80 }
+func (c zstdCodec) Name() string { return c.base.Name() + "+zstd" }
+
+func (c zstdCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.base.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= c.threshold {
+		return append([]byte{zstdFlagRaw}, data...), nil
+	}
+	return zstdEncoder.EncodeAll(data, []byte{zstdFlagZstd}), nil
+}
+
+func (c zstdCodec) Unmarshal(data []byte, v any) error {
+	if len(data) == 0 {
+		return errors.New("empty compressed data")
+	}
+	switch data[0] {
+	case zstdFlagRaw:
+		return c.base.Unmarshal(data[1:], v)
+	case zstdFlagZstd:
+		raw, err := zstdDecoder.DecodeAll(data[1:], nil)
+		if err != nil {
+			return err
+		}
+		return c.base.Unmarshal(raw, v)
+	default:
+		return errors.New("unknown compression flag")
+	}
+}
+
+// errStaleEntry 缓存值的版本或 Codec 与当前的不一致 或者无法反序列化 应视为未命中
+var errStaleEntry = errors.New("stale cache entry")
+
+// encodeEntry 缓存值的格式为 版本(1 字节) + Codec ID(1 字节) + 序列化后的数据
+func encodeEntry(codec Codec, version byte, v any) (string, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(append([]byte{version, codec.ID()}, data...)), nil
+}
+
+// decodeEntry 头部不一致或无法反序列化时返回 errStaleEntry
+// 修改了缓存的结构体但没有修改版本时 反序列化失败也按失效处理 而不是返回错误
+func decodeEntry(codec Codec, version byte, raw string, v any) error {
+	if len(raw) < 2 || raw[0] != version || raw[1] != codec.ID() {
+		return errStaleEntry
+	}
+	if err := codec.Unmarshal([]byte(raw[2:]), v); err != nil {
+		return errors.Join(errStaleEntry, err)
+	}
+	return nil
+}
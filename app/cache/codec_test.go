@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type codecUser struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Password string `json:"-"`
+}
+
+func TestCodecs(t *testing.T) {
+	user := codecUser{ID: 1, Name: strings.Repeat("Tester", 100), Password: "secret"}
+	for _, codec := range []Codec{JSON, Msgpack, Gob, Compressed(JSON, 64), Compressed(Msgpack, 1<<20)} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			raw, err := encodeEntry(codec, 1, &user)
+			assert.Nil(t, err)
+
+			got := new(codecUser)
+			assert.Nil(t, decodeEntry(codec, 1, raw, got))
+			assert.Equal(t, user.Name, got.Name)
+			if codec != Gob {
+				assert.Empty(t, got.Password)
+			}
+
+			assert.ErrorIs(t, decodeEntry(codec, 2, raw, got), errStaleEntry)
+			assert.ErrorIs(t, decodeEntry(Compressed(Gob, 0), 1, raw, got), errStaleEntry)
+		})
+	}
+
+	compressed, _ := encodeEntry(Compressed(JSON, 64), 0, &user)
+	plain, _ := encodeEntry(JSON, 0, &user)
+	assert.Less(t, len(compressed), len(plain))
+
+	assert.ErrorIs(t, decodeEntry(JSON, 0, `{"id":1}`, new(codecUser)), errStaleEntry)
+}
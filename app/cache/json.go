@@ -2,7 +2,6 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"math/rand/v2"
 	"my_zhihu_backend/app/app_error"
@@ -10,41 +9,70 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
+// JsonCacher 序列化后存入 redis 的 Cacher 序列化方式由 Codec 决定 缓存值带有版本和 Codec 的头部
+// 头部不一致或无法反序列化的缓存值视为未命中 重新执行 fallback 并覆盖
 type JsonCacher[T any] struct {
 	prefix      string
 	client      *redis.Client
 	ttl         time.Duration
 	fallback    Fallback[T]
 	plainCacher *PlainCacher[string]
-	bloomFilter *BloomFilter
+	bloomFilter BloomFilter
+	single      *singleflight.Group // 批量读取时使用 与 plainCacher 的分开 两者的结果类型不同
+	codec       Codec
+	version     byte
 }
 
-func (cacher *JsonCacher[T]) BloomFilter() *BloomFilter {
+func (cacher *JsonCacher[T]) BloomFilter() BloomFilter {
 	return cacher.bloomFilter
 }
 
-func NewJsonCacher[T any](client *redis.Client, ttl time.Duration, prefix string, fallback Fallback[T], filter *BloomFilter) *JsonCacher[T] {
+func NewJsonCacher[T any](client *redis.Client, ttl time.Duration, prefix string, fallback Fallback[T], filter BloomFilter, opts ...CacherOption) *JsonCacher[T] {
 	ttl += time.Duration(rand.IntN(5)) * time.Second // 增加过期时间的随机性 防止缓存雪崩
-	return &JsonCacher[T]{
+	options := newCacherOptions(opts)
+	cacher := &JsonCacher[T]{
 		client:      client,
 		ttl:         ttl,
 		prefix:      prefix,
 		fallback:    fallback,
 		bloomFilter: filter,
-		plainCacher: NewPlainCacher[string](client, ttl, prefix, func(ctx context.Context, args ...any) (*string, app_error.AppError) {
-			value, err := fallback(ctx, args...)
-			if err != nil {
-				return nil, err
-			}
-			if rawJson, err := json.Marshal(value); err != nil {
-				return nil, app_error.ErrRedisCache.WithError(err)
-			} else {
-				return util.Ptr(string(rawJson)), nil
-			}
-		}, filter),
+		single:      new(singleflight.Group),
+		codec:       options.codec,
+		version:     options.version,
 	}
+	cacher.plainCacher = NewPlainCacher[string](client, ttl, prefix, func(ctx context.Context, args ...any) (*string, app_error.AppError) {
+		value, err := fallback(ctx, args...)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := cacher.encode(value)
+		if err != nil {
+			return nil, err
+		}
+		return util.Ptr(raw), nil
+	}, filter, opts...)
+	return cacher
+}
+
+func (cacher *JsonCacher[T]) encode(value *T) (string, app_error.AppError) {
+	raw, err := encodeEntry(cacher.codec, cacher.version, value)
+	if err != nil {
+		return "", app_error.ErrRedisCache.WithError(err)
+	}
+	return raw, nil
+}
+
+// decode 缓存值失效时返回 errStaleEntry
+func (cacher *JsonCacher[T]) decode(raw string) (*T, error) {
+	value := new(T)
+	if err := decodeEntry(cacher.codec, cacher.version, raw, value); err != nil {
+		return nil, err
+	}
+	return value, nil
 }
 
 func (cacher *JsonCacher[T]) Fallback() Fallback[T] {
@@ -60,49 +88,87 @@ func (cacher *JsonCacher[T]) Prefix() string {
 }
 
 func (cacher *JsonCacher[T]) Renew(ctx context.Context, key string, ttl time.Duration) app_error.AppError {
-	ttl += time.Duration(rand.IntN(5)) * time.Second // 增加过期时间的随机性 防止缓存雪崩
-	if ok, err := cacher.client.Expire(ctx, cacher.prefix+key, ttl).Result(); err != nil {
-		if errors.Is(err, redis.Nil) {
-			return app_error.ErrRedisCacheKeyNotExists.WithError(err)
-		}
-		return app_error.ErrRedisCache.WithError(err)
-	} else if !ok {
-		return app_error.ErrRedisCacheKeyNotExists
-	}
-	return nil
+	return cacher.plainCacher.Renew(ctx, key, ttl)
 }
 
+// Invalidate 旧值已经失效时只删除 返回 ErrRedisCacheKeyNotExists
 func (cacher *JsonCacher[T]) Invalidate(ctx context.Context, key string) (*T, app_error.AppError) {
-	if rawJson, err := cacher.client.GetDel(ctx, cacher.prefix+key).Bytes(); err != nil {
-		if errors.Is(err, redis.Nil) {
-			return nil, app_error.ErrRedisCacheKeyNotExists.WithError(err)
-		}
-		return nil, app_error.ErrRedisCache.WithError(err)
-	} else {
-		value := new(T)
-		if err := json.Unmarshal(rawJson, value); err != nil {
-			return nil, app_error.ErrRedisCache.WithError(err)
-		}
-		return value, nil
+	raw, err := cacher.plainCacher.Invalidate(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	value, derr := cacher.decode(*raw)
+	if derr != nil {
+		return nil, app_error.ErrRedisCacheKeyNotExists.WithError(derr)
 	}
+	return value, nil
 }
 
 func (cacher *JsonCacher[T]) Get(ctx context.Context, key string, args ...any) (*T, app_error.AppError) {
-	if rawJson, err := cacher.plainCacher.Get(ctx, key, args...); err != nil {
+	raw, err := cacher.plainCacher.Get(ctx, key, args...)
+	if err != nil {
 		return nil, err
-	} else {
-		value := new(T)
-		if err := json.Unmarshal([]byte(*rawJson), value); err != nil {
-			return nil, app_error.ErrRedisCache.WithError(err)
-		}
+	}
+	value, derr := cacher.decode(*raw)
+	if derr == nil {
 		return value, nil
 	}
+	// 删除失效的缓存值后重新读取 这次会执行 fallback 并按当前的版本和 Codec 写回
+	l.Info("stale cache entry, reload", zap.String("key", cacher.prefix+key), zap.Error(derr))
+	if _, err := cacher.plainCacher.Invalidate(ctx, key); err != nil && !errors.Is(err, app_error.ErrRedisCacheKeyNotExists) {
+		return nil, err
+	}
+	if raw, err = cacher.plainCacher.Get(ctx, key, args...); err != nil {
+		return nil, err
+	}
+	if value, derr = cacher.decode(*raw); derr != nil {
+		return nil, app_error.ErrRedisCache.WithError(derr)
+	}
+	return value, nil
 }
 
 func (cacher *JsonCacher[T]) Put(ctx context.Context, key string, value T) app_error.AppError {
-	rawJson, err := json.Marshal(&value)
+	raw, err := cacher.encode(&value)
 	if err != nil {
-		return app_error.ErrRedisCache.WithError(err)
+		return err
 	}
-	return cacher.plainCacher.Put(ctx, key, string(rawJson))
+	return cacher.plainCacher.Put(ctx, key, raw)
+}
+
+// multiGet 失效的缓存值视为未命中
+func (cacher *JsonCacher[T]) multiGet(ctx context.Context, keys []string) ([]*T, app_error.AppError) {
+	raws, err := cacher.plainCacher.multiGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]*T, len(keys))
+	for i, raw := range raws {
+		if raw == nil {
+			continue
+		}
+		if value, err := cacher.decode(*raw); err == nil {
+			values[i] = value
+		}
+	}
+	return values, nil
+}
+
+func (cacher *JsonCacher[T]) MGet(ctx context.Context, keys []string, fallback BatchFallback[T]) ([]*T, app_error.AppError) {
+	return mget[T](ctx, cacher, cacher.multiGet, cacher.single, cacher.plainCacher.stats, keys, fallback)
+}
+
+func (cacher *JsonCacher[T]) MPut(ctx context.Context, values map[string]T, ttls map[string]time.Duration) app_error.AppError {
+	raws := make(map[string]string, len(values))
+	for key, value := range values {
+		raw, err := cacher.encode(&value)
+		if err != nil {
+			return err
+		}
+		raws[key] = raw
+	}
+	return cacher.plainCacher.MPut(ctx, raws, ttls)
+}
+
+func (cacher *JsonCacher[T]) MInvalidate(ctx context.Context, keys []string) app_error.AppError {
+	return cacher.plainCacher.MInvalidate(ctx, keys)
 }
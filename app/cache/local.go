@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var l = log.L().With(zap.String("module", "cache"))
+
+// LocalCacher 在 base 之前增加一层进程内的 LRU 缓存 命中时不访问 redis
+// Put 和 Invalidate 时通过 redis pub/sub 通知其他实例删除本地缓存 本地缓存的 ttl 应远小于 base 的 ttl 限制消息丢失时的不一致时间
+// 返回值在多个调用者之间共享 调用者不应修改
+type LocalCacher[T any] struct {
+	base     Cacher[T]
+	local    *lru[T]
+	client   *redis.Client
+	channel  string
+	instance string // 区分消息来自哪个实例 自己发出的消息不处理
+	stats    *Stats // 与 base 共享 本地命中时 base 不会计数
+}
+
+func NewLocalCacher[T any](base Cacher[T], client *redis.Client, channel string, capacity int, ttl time.Duration) *LocalCacher[T] {
+	return &LocalCacher[T]{
+		base:     base,
+		local:    newLRU[T](capacity, ttl),
+		client:   client,
+		channel:  channel,
+		instance: strconv.FormatInt(time.Now().UnixNano(), 36),
+		stats:    statsFor(base.Prefix()),
+	}
+}
+
+// Run 订阅失效消息 直到 ctx 结束 重新订阅时清空本地缓存 因为断开期间的消息已经丢失
+func (cacher *LocalCacher[T]) Run(ctx context.Context) {
+	for {
+		sub := cacher.client.Subscribe(ctx, cacher.channel)
+		if _, err := sub.Receive(ctx); err != nil {
+			_ = sub.Close()
+			l.Error("failed to subscribe cache invalidation", zap.String("channel", cacher.channel), zap.Error(err))
+		} else {
+			cacher.local.clear()
+			cacher.receive(ctx, sub)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (cacher *LocalCacher[T]) receive(ctx context.Context, sub *redis.PubSub) {
+	defer sub.Close()
+	for {
+		msg, err := sub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				l.Warn("cache invalidation subscription interrupted", zap.String("channel", cacher.channel), zap.Error(err))
+			}
+			return
+		}
+		instance, key, ok := strings.Cut(msg.Payload, "\x00")
+		if ok && instance != cacher.instance {
+			cacher.local.remove(key)
+		}
+	}
+}
+
+// publish 通知其他实例删除 keys 多个 key 通过 pipeline 发送 失败时只记录日志 其他实例的本地缓存在 ttl 后过期
+func (cacher *LocalCacher[T]) publish(ctx context.Context, keys ...string) {
+	if _, err := cacher.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Publish(ctx, cacher.channel, cacher.instance+"\x00"+cacher.base.Prefix()+key)
+		}
+		return nil
+	}); err != nil {
+		l.Warn("failed to publish cache invalidation", zap.Strings("keys", keys), zap.Error(err))
+	}
+}
+
+func (cacher *LocalCacher[T]) Put(ctx context.Context, key string, value T) app_error.AppError {
+	cacher.local.remove(cacher.base.Prefix() + key)
+	if err := cacher.base.Put(ctx, key, value); err != nil {
+		return err
+	}
+	cacher.local.set(cacher.base.Prefix()+key, &value)
+	cacher.publish(ctx, key)
+	return nil
+}
+
+func (cacher *LocalCacher[T]) Get(ctx context.Context, key string, args ...any) (*T, app_error.AppError) {
+	if value, ok := cacher.local.get(cacher.base.Prefix() + key); ok {
+		cacher.stats.localHit()
+		return value, nil
+	}
+	value, err := cacher.base.Get(ctx, key, args...)
+	if err != nil {
+		return nil, err
+	}
+	cacher.local.set(cacher.base.Prefix()+key, value)
+	return value, nil
+}
+
+// MGet 先读取本地缓存 其余的交给 base.MGet
+func (cacher *LocalCacher[T]) MGet(ctx context.Context, keys []string, fallback BatchFallback[T]) ([]*T, app_error.AppError) {
+	values := make([]*T, len(keys))
+	var missIdx []int
+	var missKeys []string
+	for i, key := range keys {
+		if value, ok := cacher.local.get(cacher.base.Prefix() + key); ok {
+			cacher.stats.localHit()
+			values[i] = value
+		} else {
+			missIdx = append(missIdx, i)
+			missKeys = append(missKeys, key)
+		}
+	}
+	if len(missKeys) == 0 {
+		return values, nil
+	}
+	loaded, err := cacher.base.MGet(ctx, missKeys, fallback)
+	if err != nil {
+		return nil, err
+	}
+	for j, value := range loaded {
+		if value == nil {
+			continue
+		}
+		values[missIdx[j]] = value
+		cacher.local.set(cacher.base.Prefix()+missKeys[j], value)
+	}
+	return values, nil
+}
+
+func (cacher *LocalCacher[T]) MPut(ctx context.Context, values map[string]T, ttls map[string]time.Duration) app_error.AppError {
+	for key := range values {
+		cacher.local.remove(cacher.base.Prefix() + key)
+	}
+	if err := cacher.base.MPut(ctx, values, ttls); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		cacher.local.set(cacher.base.Prefix()+key, &value)
+		keys = append(keys, key)
+	}
+	cacher.publish(ctx, keys...)
+	return nil
+}
+
+func (cacher *LocalCacher[T]) MInvalidate(ctx context.Context, keys []string) app_error.AppError {
+	for _, key := range keys {
+		cacher.local.remove(cacher.base.Prefix() + key)
+	}
+	err := cacher.base.MInvalidate(ctx, keys)
+	cacher.publish(ctx, keys...)
+	return err
+}
+
+func (cacher *LocalCacher[T]) Renew(ctx context.Context, key string, ttl time.Duration) app_error.AppError {
+	return cacher.base.Renew(ctx, key, ttl)
+}
+
+func (cacher *LocalCacher[T]) Invalidate(ctx context.Context, key string) (*T, app_error.AppError) {
+	cacher.local.remove(cacher.base.Prefix() + key)
+	value, err := cacher.base.Invalidate(ctx, key)
+	cacher.publish(ctx, key)
+	return value, err
+}
+
+func (cacher *LocalCacher[T]) Fallback() Fallback[T] {
+	return cacher.base.Fallback()
+}
+
+func (cacher *LocalCacher[T]) TTL() time.Duration {
+	return cacher.base.TTL()
+}
+
+func (cacher *LocalCacher[T]) Prefix() string {
+	return cacher.base.Prefix()
+}
+
+func (cacher *LocalCacher[T]) BloomFilter() BloomFilter {
+	return cacher.base.BloomFilter()
+}
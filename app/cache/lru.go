@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry[T any] struct {
+	key      string
+	value    *T
+	expireAt time.Time
+}
+
+// lru 有容量上限和过期时间的 LRU 并发安全
+type lru[T any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+func newLRU[T any](capacity int, ttl time.Duration) *lru[T] {
+	return &lru[T]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lru[T]) get(key string) (*T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry[T])
+	if time.Now().After(entry.expireAt) {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *lru[T]) set(key string, value *T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expireAt := time.Now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[T])
+		entry.value, entry.expireAt = value, expireAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[T]{key: key, value: value, expireAt: expireAt})
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[T]).key)
+	}
+}
+
+func (c *lru[T]) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+func (c *lru[T]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	clear(c.items)
+}
+
+func (c *lru[T]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	c := newLRU[int](2, time.Minute)
+	one, two, three := 1, 2, 3
+	c.set("a", &one)
+	c.set("b", &two)
+	_, ok := c.get("a") // a 变为最近使用
+	assert.True(t, ok)
+	c.set("c", &three) // 淘汰最久未使用的 b
+	_, ok = c.get("b")
+	assert.False(t, ok)
+	v, ok := c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, *v)
+	assert.Equal(t, 2, c.len())
+
+	c.remove("a")
+	_, ok = c.get("a")
+	assert.False(t, ok)
+
+	expired := newLRU[int](2, -time.Second)
+	expired.set("a", &one)
+	_, ok = expired.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, expired.len())
+}
@@ -0,0 +1,383 @@
+package cache
+
+import (
+	"context"
+	"math/rand/v2"
+	"my_zhihu_backend/app/app_error"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// memorySweepInterval 清理过期条目的间隔 读取时也会检查是否过期
+const memorySweepInterval = time.Minute
+
+type memoryItem struct {
+	value    any
+	expireAt time.Time
+}
+
+// memorySet MemoryTagIndex 的标签集合 与 redis 相同 每次加入成员时把有效期重新设置为 ttl
+type memorySet struct {
+	members  map[string]struct{}
+	expireAt time.Time
+}
+
+// memoryStore 进程内的键值存储 所有 MemoryCacher 和 MemoryTagIndex 共享 相当于 redis 的角色
+type memoryStore struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+	sets  map[string]memorySet
+	lexes map[string][]string // MemoryLexIndex 的成员 按字节序排列
+	once  sync.Once
+}
+
+var defaultMemoryStore = &memoryStore{
+	items: make(map[string]memoryItem),
+	sets:  make(map[string]memorySet),
+	lexes: make(map[string][]string),
+}
+
+func (s *memoryStore) sweepLater() {
+	s.once.Do(func() {
+		go func() {
+			for now := range time.Tick(memorySweepInterval) {
+				s.sweep(now)
+			}
+		}()
+	})
+}
+
+// sweep 删除过期的条目和集合 集合中已经不存在的成员也一起删除
+func (s *memoryStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, item := range s.items {
+		if now.After(item.expireAt) {
+			delete(s.items, key)
+		}
+	}
+	for name, set := range s.sets {
+		if now.After(set.expireAt) {
+			delete(s.sets, name)
+			continue
+		}
+		for member := range set.members {
+			if _, ok := s.items[member]; !ok {
+				delete(set.members, member)
+			}
+		}
+		if len(set.members) == 0 {
+			delete(s.sets, name)
+		}
+	}
+}
+
+func (s *memoryStore) get(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(item.expireAt) {
+		delete(s.items, key)
+		return nil, false
+	}
+	return item.value, true
+}
+
+func (s *memoryStore) set(key string, value any, ttl time.Duration) {
+	s.sweepLater()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = memoryItem{value: value, expireAt: time.Now().Add(ttl)}
+}
+
+func (s *memoryStore) expire(key string, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[key]
+	if !ok || time.Now().After(item.expireAt) {
+		return false
+	}
+	item.expireAt = time.Now().Add(ttl)
+	s.items[key] = item
+	return true
+}
+
+func (s *memoryStore) getDel(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[key]
+	delete(s.items, key)
+	if !ok || time.Now().After(item.expireAt) {
+		return nil, false
+	}
+	return item.value, true
+}
+
+// sAdd 把 member 加入集合 集合的有效期重新设置为 ttl
+func (s *memoryStore) sAdd(name, member string, ttl time.Duration) {
+	s.sweepLater()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	set, ok := s.sets[name]
+	if !ok || now.After(set.expireAt) {
+		set = memorySet{members: make(map[string]struct{})}
+	}
+	set.members[member] = struct{}{}
+	set.expireAt = now.Add(ttl)
+	s.sets[name] = set
+}
+
+// sample 读取 prefix 下最多 n 个键的剩余有效期
+func (s *memoryStore) sample(prefix string, n int) []KeyTTL {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var samples []KeyTTL
+	now := time.Now()
+	for key, item := range s.items {
+		if len(samples) >= n {
+			break
+		}
+		if strings.HasPrefix(key, prefix) && now.Before(item.expireAt) {
+			samples = append(samples, KeyTTL{Key: key, TTL: item.expireAt.Sub(now)})
+		}
+	}
+	return samples
+}
+
+// purgeSet 删除集合中的键以及集合本身 同时增加集合的清除次数
+// 清除次数与 redis 相同 保存在集合名加 generationSuffix 的条目中 tagGenerationTTL 后过期
+func (s *memoryStore) purgeSet(name string) {
+	s.sweepLater()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	genKey := name + generationSuffix
+	var gen int64
+	if item, ok := s.items[genKey]; ok && now.Before(item.expireAt) {
+		gen, _ = item.value.(int64)
+	}
+	s.items[genKey] = memoryItem{value: gen + 1, expireAt: now.Add(tagGenerationTTL)}
+	for key := range s.sets[name].members {
+		delete(s.items, key)
+	}
+	delete(s.sets, name)
+}
+
+// generation 这些集合被清除的次数之和
+func (s *memoryStore) generation(names ...string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var gen int64
+	for _, name := range names {
+		if item, ok := s.items[name+generationSuffix]; ok && now.Before(item.expireAt) {
+			n, _ := item.value.(int64)
+			gen += n
+		}
+	}
+	return gen
+}
+
+func (s *memoryStore) lexUpdate(key, remove, add string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members := s.lexes[key]
+	if remove != "" {
+		if i, ok := slices.BinarySearch(members, remove); ok {
+			members = slices.Delete(members, i, i+1)
+		}
+	}
+	if add != "" {
+		if i, ok := slices.BinarySearch(members, add); !ok {
+			members = slices.Insert(members, i, add)
+		}
+	}
+	if len(members) == 0 {
+		delete(s.lexes, key)
+		return
+	}
+	s.lexes[key] = members
+}
+
+func (s *memoryStore) lexExists(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.lexes[key]) > 0
+}
+
+// lexMerge 把 members 合并到已有的成员中
+func (s *memoryStore) lexMerge(key string, members []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged := append(slices.Clone(s.lexes[key]), members...)
+	slices.Sort(merged)
+	s.lexes[key] = slices.Compact(merged)
+}
+
+// lexRange 以 prefix 开头的前 count 个成员
+func (s *memoryStore) lexRange(key, prefix string, count int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members := s.lexes[key]
+	i, _ := slices.BinarySearch(members, prefix)
+	var result []string
+	for ; i < len(members) && len(result) < count && strings.HasPrefix(members[i], prefix); i++ {
+		result = append(result, members[i])
+	}
+	return result
+}
+
+// MemoryCacher 进程内实现的 Cacher 行为与 JsonCacher 相同 数据不会在实例之间共享 只适合单实例部署和测试
+// 写入和读取时复制一份值 调用者修改返回值不会影响缓存中的数据(引用类型的字段除外)
+type MemoryCacher[T any] struct {
+	store       *memoryStore
+	prefix      string
+	ttl         time.Duration
+	fallback    Fallback[T]
+	bloomFilter BloomFilter
+	single      *singleflight.Group
+	stats       *Stats
+}
+
+func NewMemoryCacher[T any](ttl time.Duration, prefix string, fallback Fallback[T], filter BloomFilter) *MemoryCacher[T] {
+	ttl += time.Duration(rand.IntN(5)) * time.Second // 增加过期时间的随机性 防止缓存雪崩
+	return &MemoryCacher[T]{
+		store:       defaultMemoryStore,
+		prefix:      prefix,
+		ttl:         ttl,
+		fallback:    fallback,
+		bloomFilter: filter,
+		single:      new(singleflight.Group),
+		stats:       statsFor(prefix),
+	}
+}
+
+func (cacher *MemoryCacher[T]) load(key string) (*T, bool) {
+	value, ok := cacher.store.get(cacher.prefix + key)
+	if !ok {
+		return nil, false
+	}
+	v, ok := value.(T)
+	if !ok {
+		return nil, false
+	}
+	return &v, true
+}
+
+func (cacher *MemoryCacher[T]) Put(ctx context.Context, key string, value T) app_error.AppError {
+	return cacher.put(ctx, key, value, cacher.ttl)
+}
+
+func (cacher *MemoryCacher[T]) put(ctx context.Context, key string, value T, ttl time.Duration) app_error.AppError {
+	if err := cacher.bloomFilter.Add(ctx, cacher.prefix+key); err != nil {
+		return err
+	}
+	cacher.store.set(cacher.prefix+key, value, ttl)
+	return nil
+}
+
+func (cacher *MemoryCacher[T]) Get(ctx context.Context, key string, args ...any) (*T, app_error.AppError) {
+	if exists, err := cacher.bloomFilter.Exist(ctx, cacher.prefix+key); err != nil {
+		return nil, err
+	} else if !exists {
+		cacher.stats.reject()
+		return nil, app_error.ErrRedisCacheKeyNotExists
+	}
+	if value, ok := cacher.load(key); ok {
+		cacher.stats.hit(1)
+		return value, nil
+	}
+	cacher.stats.miss(1)
+	res, err, shared := cacher.single.Do(key, func() (interface{}, error) {
+		start := time.Now()
+		value, err := cacher.fallback(ctx, args...)
+		cacher.stats.fallback(start, err)
+		return value, err
+	})
+	cacher.stats.share(shared)
+	if err != nil {
+		return nil, err.(app_error.AppError)
+	}
+	if err := cacher.Put(ctx, key, *res.(*T)); err != nil {
+		return nil, err
+	}
+	return res.(*T), nil
+}
+
+func (cacher *MemoryCacher[T]) multiGet(_ context.Context, keys []string) ([]*T, app_error.AppError) {
+	values := make([]*T, len(keys))
+	hits := 0
+	for i, key := range keys {
+		if value, ok := cacher.load(key); ok {
+			values[i] = value
+			hits++
+		}
+	}
+	cacher.stats.hit(hits)
+	cacher.stats.miss(len(keys) - hits)
+	return values, nil
+}
+
+func (cacher *MemoryCacher[T]) MGet(ctx context.Context, keys []string, fallback BatchFallback[T]) ([]*T, app_error.AppError) {
+	return mget[T](ctx, cacher, cacher.multiGet, cacher.single, cacher.stats, keys, fallback)
+}
+
+func (cacher *MemoryCacher[T]) MPut(ctx context.Context, values map[string]T, ttls map[string]time.Duration) app_error.AppError {
+	for key, value := range values {
+		if err := cacher.put(ctx, key, value, ttlOf(ttls, key, cacher.ttl)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cacher *MemoryCacher[T]) MInvalidate(_ context.Context, keys []string) app_error.AppError {
+	for _, key := range keys {
+		cacher.store.getDel(cacher.prefix + key)
+	}
+	return nil
+}
+
+func (cacher *MemoryCacher[T]) Renew(_ context.Context, key string, ttl time.Duration) app_error.AppError {
+	ttl += time.Duration(rand.IntN(5)) * time.Second // 增加过期时间的随机性 防止缓存雪崩
+	if !cacher.store.expire(cacher.prefix+key, ttl) {
+		return app_error.ErrRedisCacheKeyNotExists
+	}
+	return nil
+}
+
+func (cacher *MemoryCacher[T]) Invalidate(_ context.Context, key string) (*T, app_error.AppError) {
+	value, ok := cacher.store.getDel(cacher.prefix + key)
+	if !ok {
+		return nil, app_error.ErrRedisCacheKeyNotExists
+	}
+	v, ok := value.(T)
+	if !ok {
+		return nil, app_error.ErrRedisCacheKeyNotExists
+	}
+	return &v, nil
+}
+
+func (cacher *MemoryCacher[T]) Fallback() Fallback[T] {
+	return cacher.fallback
+}
+
+func (cacher *MemoryCacher[T]) TTL() time.Duration {
+	return cacher.ttl
+}
+
+func (cacher *MemoryCacher[T]) Prefix() string {
+	return cacher.prefix
+}
+
+func (cacher *MemoryCacher[T]) BloomFilter() BloomFilter {
+	return cacher.bloomFilter
+}
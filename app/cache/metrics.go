@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// Stats 一个缓存前缀的计数 同一前缀的多个 Cacher 共享
+type Stats struct {
+	hits            atomic.Int64
+	localHits       atomic.Int64 // LocalCacher 的进程内缓存命中 同时计入 hits
+	misses          atomic.Int64
+	bloomRejections atomic.Int64
+	fallbacks       atomic.Int64
+	fallbackNanos   atomic.Int64 // fallback 的总耗时
+	shared          atomic.Int64 // singleflight 共享给多个调用者的结果
+	errors          atomic.Int64 // redis 读写和 fallback 的错误 不包括 notFound
+}
+
+// StatsSnapshot Stats 在某一时刻的值
+type StatsSnapshot struct {
+	Prefix          string
+	Hits            int64
+	LocalHits       int64
+	Misses          int64
+	BloomRejections int64
+	Fallbacks       int64
+	FallbackTime    time.Duration
+	Shared          int64
+	Errors          int64
+}
+
+// HitRate 命中率 没有读取时为 0
+func (s StatsSnapshot) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+var statsByPrefix sync.Map // prefix -> *Stats
+
+// statsFor 获取 prefix 的计数 不存在时创建
+func statsFor(prefix string) *Stats {
+	if s, ok := statsByPrefix.Load(prefix); ok {
+		return s.(*Stats)
+	}
+	s, _ := statsByPrefix.LoadOrStore(prefix, new(Stats))
+	return s.(*Stats)
+}
+
+func (s *Stats) hit(n int)  { s.hits.Add(int64(n)) }
+func (s *Stats) miss(n int) { s.misses.Add(int64(n)) }
+func (s *Stats) reject()    { s.bloomRejections.Add(1) }
+func (s *Stats) fail()      { s.errors.Add(1) }
+func (s *Stats) localHit() {
+	s.localHits.Add(1)
+	s.hits.Add(1)
+}
+
+// fallback 记录一次 fallback 的耗时 内部错误同时计入 errors 不存在等输入错误不计入
+func (s *Stats) fallback(start time.Time, err app_error.AppError) {
+	s.fallbacks.Add(1)
+	s.fallbackNanos.Add(int64(time.Since(start)))
+	if err != nil && err.Type() == app_error.ErrTypeInternal {
+		s.errors.Add(1)
+	}
+}
+
+// share 记录 singleflight 的结果是否被共享
+func (s *Stats) share(shared bool) {
+	if shared {
+		s.shared.Add(1)
+	}
+}
+
+func (s *Stats) snapshot(prefix string) StatsSnapshot {
+	return StatsSnapshot{
+		Prefix:          prefix,
+		Hits:            s.hits.Load(),
+		LocalHits:       s.localHits.Load(),
+		Misses:          s.misses.Load(),
+		BloomRejections: s.bloomRejections.Load(),
+		Fallbacks:       s.fallbacks.Load(),
+		FallbackTime:    time.Duration(s.fallbackNanos.Load()),
+		Shared:          s.shared.Load(),
+		Errors:          s.errors.Load(),
+	}
+}
+
+// AllStats 所有缓存前缀的计数 按前缀排序
+func AllStats() []StatsSnapshot {
+	var snapshots []StatsSnapshot
+	statsByPrefix.Range(func(prefix, s any) bool {
+		snapshots = append(snapshots, s.(*Stats).snapshot(prefix.(string)))
+		return true
+	})
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Prefix < snapshots[j].Prefix
+	})
+	return snapshots
+}
+
+// KeyTTL 缓存 key 的剩余有效期 负数表示没有设置有效期
+type KeyTTL struct {
+	Key string
+	TTL time.Duration
+}
+
+// sampleScanRounds SampleTTLs 最多执行的 SCAN 次数 前缀下的 key 很少时避免遍历整个 keyspace
+const sampleScanRounds = 10
+
+// SampleTTLs 读取 prefix 下最多 n 个 key 的剩余有效期 不包括负缓存和 stale-while-revalidate 的标记
+// redis 使用 SCAN 迭代游标直到取得 n 个或者执行了 sampleScanRounds 次 结果不保证有 n 个 每一批的 PTTL 通过 pipeline 读取
+func SampleTTLs(ctx context.Context, backend Backend, client *redis.Client, prefix string, n int) ([]KeyTTL, error) {
+	if backend == BackendMemory {
+		return defaultMemoryStore.sample(prefix, n), nil
+	}
+	var samples []KeyTTL
+	seen := make(map[string]struct{}) // SCAN 可能多次返回同一个 key
+	var cursor uint64
+	for round := 0; round < sampleScanRounds && len(samples) < n; round++ {
+		keys, next, err := client.Scan(ctx, cursor, prefix+"*", int64(n*10)).Result()
+		if err != nil {
+			return nil, err
+		}
+		var batch []string
+		for _, key := range keys {
+			if len(samples)+len(batch) >= n {
+				break
+			}
+			if _, ok := seen[key]; ok || strings.HasSuffix(key, missingSuffix) || strings.HasSuffix(key, freshSuffix) {
+				continue
+			}
+			seen[key] = struct{}{}
+			batch = append(batch, key)
+		}
+		if len(batch) > 0 {
+			cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, key := range batch {
+					pipe.PTTL(ctx, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			for i, cmd := range cmds {
+				ttl := cmd.(*redis.DurationCmd).Val()
+				if ttl == -2*time.Nanosecond { // 扫描之后已经过期
+					continue
+				}
+				samples = append(samples, KeyTTL{Key: batch[i], TTL: ttl})
+			}
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	return samples, nil
+}
+
+var (
+	hitsDesc            = prometheus.NewDesc("cache_hits_total", "Number of cache hits.", []string{"prefix"}, nil)
+	localHitsDesc       = prometheus.NewDesc("cache_local_hits_total", "Number of in-process cache hits, also counted in cache_hits_total.", []string{"prefix"}, nil)
+	missesDesc          = prometheus.NewDesc("cache_misses_total", "Number of cache misses.", []string{"prefix"}, nil)
+	bloomRejectionsDesc = prometheus.NewDesc("cache_bloom_rejections_total", "Number of reads rejected by the bloom filter.", []string{"prefix"}, nil)
+	fallbackDesc        = prometheus.NewDesc("cache_fallback_duration_seconds", "Number and duration of fallback calls.", []string{"prefix"}, nil)
+	sharedDesc          = prometheus.NewDesc("cache_singleflight_shared_total", "Number of fallback results shared by singleflight.", []string{"prefix"}, nil)
+	errorsDesc          = prometheus.NewDesc("cache_errors_total", "Number of redis and fallback errors.", []string{"prefix"}, nil)
+)
+
+// Collector 把所有缓存前缀的计数导出给 prometheus
+type Collector struct{}
+
+func (Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{hitsDesc, localHitsDesc, missesDesc, bloomRejectionsDesc, fallbackDesc, sharedDesc, errorsDesc} {
+		ch <- desc
+	}
+}
+
+func (Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range AllStats() {
+		ch <- prometheus.MustNewConstMetric(hitsDesc, prometheus.CounterValue, float64(s.Hits), s.Prefix)
+		ch <- prometheus.MustNewConstMetric(localHitsDesc, prometheus.CounterValue, float64(s.LocalHits), s.Prefix)
+		ch <- prometheus.MustNewConstMetric(missesDesc, prometheus.CounterValue, float64(s.Misses), s.Prefix)
+		ch <- prometheus.MustNewConstMetric(bloomRejectionsDesc, prometheus.CounterValue, float64(s.BloomRejections), s.Prefix)
+		ch <- prometheus.MustNewConstSummary(fallbackDesc, uint64(s.Fallbacks), s.FallbackTime.Seconds(), nil, s.Prefix)
+		ch <- prometheus.MustNewConstMetric(sharedDesc, prometheus.CounterValue, float64(s.Shared), s.Prefix)
+		ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(s.Errors), s.Prefix)
+	}
+}
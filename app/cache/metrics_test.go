@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"my_zhihu_backend/app/app_error"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.TODO()
+	bloom := NewLocalBloomFilter()
+	cacher := NewMemoryCacher[TestUser](time.Minute, "stats-test:", func(ctx context.Context, args ...any) (*TestUser, app_error.AppError) {
+		return &TestUser{ID: 1, Name: "Tester"}, nil
+	}, bloom)
+
+	_, _ = cacher.Get(ctx, "1") // 布隆过滤器拦截
+	assert.Nil(t, bloom.Add(ctx, "stats-test:1"))
+	_, _ = cacher.Get(ctx, "1") // 未命中
+	_, _ = cacher.Get(ctx, "1") // 命中
+	_, _ = cacher.MGet(ctx, []string{"1", "2"}, nil)
+
+	var snapshot StatsSnapshot
+	for _, s := range AllStats() {
+		if s.Prefix == "stats-test:" {
+			snapshot = s
+		}
+	}
+	assert.EqualValues(t, 1, snapshot.BloomRejections)
+	assert.EqualValues(t, 2, snapshot.Hits)
+	assert.EqualValues(t, 2, snapshot.Misses)
+	assert.EqualValues(t, 1, snapshot.Fallbacks)
+	assert.EqualValues(t, 0, snapshot.Errors)
+	assert.Equal(t, 0.5, snapshot.HitRate())
+
+	samples, err := SampleTTLs(ctx, BackendMemory, nil, "stats-test:", 5)
+	assert.Nil(t, err)
+	assert.Len(t, samples, 1)
+	assert.Greater(t, samples[0].TTL, 50*time.Second)
+}
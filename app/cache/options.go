@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"time"
+)
+
+// CacherOption 设置 Cacher 的可选行为 JsonCacher 把负缓存和 stale-while-revalidate 的设置传给内部的 PlainCacher
+// MemoryCacher 忽略所有设置
+type CacherOption func(*cacherOptions)
+
+type cacherOptions struct {
+	codec   Codec // JsonCacher 的序列化方式
+	version byte  // JsonCacher 缓存值的版本
+
+	negativeTTL time.Duration      // 负缓存的有效期 0 表示不开启
+	notFound    app_error.AppError // fallback 返回该错误时视为不存在
+	softTTL     time.Duration      // 缓存值写入后超过该时间视为过期但仍可使用 0 表示不开启
+}
+
+func newCacherOptions(opts []CacherOption) cacherOptions {
+	options := cacherOptions{codec: JSON}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// isNotFound 开启了负缓存并且 err 是 notFound
+func (o cacherOptions) isNotFound(err error) bool {
+	return o.negativeTTL > 0 && o.notFound != nil && errors.Is(err, o.notFound)
+}
+
+// WithCodec 使用 codec 序列化 默认为 JSON
+func WithCodec(codec Codec) CacherOption {
+	return func(o *cacherOptions) {
+		o.codec = codec
+	}
+}
+
+// WithVersion 设置缓存值的版本 默认为 0 修改了缓存的结构体时应该修改版本 使旧的缓存值失效
+func WithVersion(version byte) CacherOption {
+	return func(o *cacherOptions) {
+		o.version = version
+	}
+}
+
+// WithNegativeCache fallback 返回 notFound 时记录 ttl 时间 期间的读取直接返回 notFound 不再执行 fallback
+// 写入该 key 时删除记录
+func WithNegativeCache(ttl time.Duration, notFound app_error.AppError) CacherOption {
+	return func(o *cacherOptions) {
+		o.negativeTTL = ttl
+		o.notFound = notFound
+	}
+}
+
+// WithStaleWhileRevalidate 缓存值写入 softTTL 之后读取时立即返回旧值 同时在后台执行一次 fallback 刷新
+// softTTL 应小于缓存的 ttl 超过 ttl 后缓存值被删除 读取时同步执行 fallback
+func WithStaleWhileRevalidate(softTTL time.Duration) CacherOption {
+	return func(o *cacherOptions) {
+		o.softTTL = softTTL
+	}
+}
@@ -8,34 +8,47 @@ import (
 	"my_zhihu_backend/app/app_error"
 	"time"
 
+	"go.uber.org/zap"
 	"golang.org/x/sync/singleflight"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	missingSuffix     = "::missing"     // 负缓存的标记 存在时表示 fallback 返回了 notFound
+	freshSuffix       = "::fresh"       // stale-while-revalidate 的标记 不存在时表示缓存值已经过了 softTTL
+	revalidateTimeout = 5 * time.Second // 后台刷新的超时时间
+)
+
 type PlainCacher[T cmp.Ordered] struct {
 	prefix   string
 	client   *redis.Client
 	ttl      time.Duration
 	fallback Fallback[T]
+	options  cacherOptions
 
-	bloomFilter *BloomFilter        // 解决缓存穿透 拦截恶意请求
+	bloomFilter BloomFilter         // 解决缓存穿透 拦截恶意请求
 	single      *singleflight.Group // 使用 singleflight 包实现同一个key同一时间只能有一个 fallback 在执行 防止缓存击穿
+	breaker     *Breaker            // redis 不可用时直接执行 fallback
+	stats       *Stats
 }
 
-func (cacher *PlainCacher[T]) BloomFilter() *BloomFilter {
+func (cacher *PlainCacher[T]) BloomFilter() BloomFilter {
 	return cacher.bloomFilter
 }
 
-func NewPlainCacher[T cmp.Ordered](client *redis.Client, ttl time.Duration, prefix string, fallback Fallback[T], filter *BloomFilter) *PlainCacher[T] {
+func NewPlainCacher[T cmp.Ordered](client *redis.Client, ttl time.Duration, prefix string, fallback Fallback[T], filter BloomFilter, opts ...CacherOption) *PlainCacher[T] {
 	ttl += time.Duration(rand.IntN(5)) * time.Second // 增加过期时间的随机性 防止缓存雪崩
 	return &PlainCacher[T]{
 		client:      client,
 		ttl:         ttl,
 		prefix:      prefix,
 		fallback:    fallback,
+		options:     newCacherOptions(opts),
 		bloomFilter: filter,
 		single:      new(singleflight.Group),
+		breaker:     RedisBreaker,
+		stats:       statsFor(prefix),
 	}
 }
 
@@ -51,12 +64,29 @@ func (cacher *PlainCacher[T]) Prefix() string {
 	return cacher.prefix
 }
 
+// markers 开启的选项对应的标记 key 写入和删除缓存值时一起删除
+func (cacher *PlainCacher[T]) markers(key string) []string {
+	var markers []string
+	if cacher.options.negativeTTL > 0 {
+		markers = append(markers, cacher.prefix+key+missingSuffix)
+	}
+	if cacher.options.softTTL > 0 {
+		markers = append(markers, cacher.prefix+key+freshSuffix)
+	}
+	return markers
+}
+
 func (cacher *PlainCacher[T]) Renew(ctx context.Context, key string, ttl time.Duration) app_error.AppError {
 	ttl += time.Duration(rand.IntN(5)) * time.Second // 增加过期时间的随机性 防止缓存雪崩
-	if ok, err := cacher.client.Expire(ctx, cacher.prefix+key, ttl).Result(); err != nil {
+	var ok bool
+	if err := cacher.breaker.Do(ctx, func() (err error) {
+		ok, err = cacher.client.Expire(ctx, cacher.prefix+key, ttl).Result()
+		return err
+	}); err != nil {
 		if errors.Is(err, redis.Nil) {
 			return app_error.ErrRedisCacheKeyNotExists.WithError(err)
 		}
+		cacher.stats.fail()
 		return app_error.ErrRedisCache.WithError(err)
 	} else if !ok {
 		return app_error.ErrRedisCacheKeyNotExists
@@ -66,47 +96,286 @@ func (cacher *PlainCacher[T]) Renew(ctx context.Context, key string, ttl time.Du
 
 func (cacher *PlainCacher[T]) Invalidate(ctx context.Context, key string) (*T, app_error.AppError) {
 	value := new(T)
-	if err := cacher.client.GetDel(ctx, cacher.prefix+key).Scan(value); err != nil {
+	if err := cacher.breaker.Do(ctx, func() error {
+		if markers := cacher.markers(key); len(markers) > 0 {
+			if err := cacher.client.Del(ctx, markers...).Err(); err != nil {
+				return err
+			}
+		}
+		return cacher.client.GetDel(ctx, cacher.prefix+key).Scan(value)
+	}); err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, app_error.ErrRedisCacheKeyNotExists.WithError(err)
 		}
+		cacher.stats.fail()
 		return nil, app_error.ErrRedisCache.WithError(err)
 	}
 	return value, nil
 }
 
-func (cacher *PlainCacher[T]) Put(ctx context.Context, key string, value T) app_error.AppError {
-	if err := cacher.bloomFilter.Add(ctx, cacher.prefix+key); err != nil {
-		return app_error.ErrRedisCache.WithError(err)
+// set 在 pipeline 中写入缓存值 同时删除负缓存的标记 写入新的 stale-while-revalidate 标记
+func (cacher *PlainCacher[T]) set(ctx context.Context, pipe redis.Pipeliner, key string, value T, ttl time.Duration) {
+	pipe.Set(ctx, cacher.prefix+key, value, ttl)
+	if cacher.options.negativeTTL > 0 {
+		pipe.Del(ctx, cacher.prefix+key+missingSuffix)
 	}
-	if err := cacher.client.Set(ctx, cacher.prefix+key, value, cacher.ttl).Err(); err != nil {
+	if cacher.options.softTTL > 0 {
+		pipe.Set(ctx, cacher.prefix+key+freshSuffix, 1, cacher.options.softTTL)
+	}
+}
+
+func (cacher *PlainCacher[T]) Put(ctx context.Context, key string, value T) app_error.AppError {
+	if err := cacher.breaker.Do(ctx, func() error {
+		if err := cacher.bloomFilter.Add(ctx, cacher.prefix+key); err != nil {
+			return err
+		}
+		_, err := cacher.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			cacher.set(ctx, pipe, key, value, cacher.ttl)
+			return nil
+		})
+		return err
+	}); err != nil {
+		cacher.stats.fail()
 		return app_error.ErrRedisCache.WithError(err)
 	}
 	return nil
 }
 
+// putMissing 记录 key 不存在 失败时只记录日志
+func (cacher *PlainCacher[T]) putMissing(ctx context.Context, key string) {
+	if err := cacher.breaker.Do(ctx, func() error {
+		return cacher.client.Set(ctx, cacher.prefix+key+missingSuffix, 1, cacher.options.negativeTTL).Err()
+	}); err != nil && !errors.Is(err, ErrBreakerOpen) {
+		cacher.stats.fail()
+		l.Warn("failed to write negative cache", zap.String("key", cacher.prefix+key), zap.Error(err))
+	}
+}
+
+// callFallback 执行 fallback 并记录耗时
+func (cacher *PlainCacher[T]) callFallback(ctx context.Context, args ...any) (*T, app_error.AppError) {
+	start := time.Now()
+	value, err := cacher.fallback(ctx, args...)
+	cacher.stats.fallback(start, err)
+	return value, err
+}
+
+// load 通过 singleflight 执行 fallback
+func (cacher *PlainCacher[T]) load(ctx context.Context, key string, args ...any) (*T, app_error.AppError) {
+	res, err, shared := cacher.single.Do(key, func() (interface{}, error) {
+		return cacher.callFallback(ctx, args...)
+	})
+	cacher.stats.share(shared)
+	if err != nil {
+		return nil, err.(app_error.AppError)
+	}
+	return res.(*T), nil
+}
+
+// read 读取缓存值 开启了负缓存或 stale-while-revalidate 时在同一个 pipeline 中读取标记
+// 缓存值不存在时 value 为 nil missing 表示记录了 key 不存在 stale 表示缓存值已经过了 softTTL
+func (cacher *PlainCacher[T]) read(ctx context.Context, key string) (value *T, missing, stale bool, err error) {
+	var get *redis.StringCmd
+	var missingCmd, freshCmd *redis.IntCmd
+	_, err = cacher.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, cacher.prefix+key)
+		if cacher.options.negativeTTL > 0 {
+			missingCmd = pipe.Exists(ctx, cacher.prefix+key+missingSuffix)
+		}
+		if cacher.options.softTTL > 0 {
+			freshCmd = pipe.Exists(ctx, cacher.prefix+key+freshSuffix)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, false, err
+	}
+	value = new(T)
+	if err := get.Scan(value); err != nil {
+		if !errors.Is(err, redis.Nil) {
+			return nil, false, false, err
+		}
+		return nil, missingCmd != nil && missingCmd.Val() > 0, false, nil
+	}
+	return value, false, freshCmd != nil && freshCmd.Val() == 0, nil
+}
+
+// revalidate 重新写入 stale-while-revalidate 的标记 写入成功的实例在后台执行 fallback 刷新缓存值
+// 其他读取者看到标记后不再刷新 刷新失败时旧值继续使用到下一个 softTTL
+func (cacher *PlainCacher[T]) revalidate(ctx context.Context, key string, args ...any) {
+	var ok bool
+	if err := cacher.breaker.Do(ctx, func() (err error) {
+		ok, err = cacher.client.SetNX(ctx, cacher.prefix+key+freshSuffix, 1, cacher.options.softTTL).Result()
+		return err
+	}); err != nil || !ok {
+		return
+	}
+	go func() {
+		timeout, cancel := context.WithTimeout(context.WithoutCancel(ctx), revalidateTimeout)
+		defer cancel()
+		value, err := cacher.callFallback(timeout, args...)
+		if err != nil {
+			if cacher.options.isNotFound(err) {
+				_, _ = cacher.Invalidate(timeout, key)
+				cacher.putMissing(timeout, key)
+				return
+			}
+			l.Warn("failed to revalidate cache", append(err.ErrorField(), zap.String("key", cacher.prefix+key))...)
+			return
+		}
+		if err := cacher.Put(timeout, key, *value); err != nil {
+			l.Warn("failed to write back cache", append(err.ErrorField(), zap.String("key", cacher.prefix+key))...)
+		}
+	}()
+}
+
+// Get 读取缓存 未命中时执行 fallback 并写回缓存
+// redis 出错或熔断器打开时降级为直接执行 fallback 不写回缓存 布隆过滤器出错时跳过检查
+// 开启负缓存时 fallback 返回 notFound 会被记录 期间直接返回 notFound
+// 开启 stale-while-revalidate 时 过了 softTTL 的缓存值直接返回 同时在后台刷新
 func (cacher *PlainCacher[T]) Get(ctx context.Context, key string, args ...any) (*T, app_error.AppError) {
-	value := new(T)
-	if exists, err := cacher.bloomFilter.Exist(ctx, cacher.prefix+key); err != nil {
-		return nil, app_error.ErrRedisCache.WithError(err)
-	} else if !exists {
+	exists := true
+	if err := cacher.breaker.Do(ctx, func() (err error) {
+		exists, err = cacher.bloomFilter.Exist(ctx, cacher.prefix+key)
+		return err
+	}); err != nil {
+		exists = true
+		if !errors.Is(err, ErrBreakerOpen) {
+			l.Warn("bloom filter unavailable, skip check", zap.String("key", cacher.prefix+key), zap.Error(err))
+		}
+	}
+	if !exists {
+		cacher.stats.reject()
 		return nil, app_error.ErrRedisCacheKeyNotExists
 	}
 
-	if err := cacher.client.Get(ctx, cacher.prefix+key).Scan(value); err != nil {
-		if errors.Is(err, redis.Nil) {
-			res, err, _ := cacher.single.Do(key, func() (interface{}, error) {
-				return cacher.fallback(ctx, args...)
-			})
-			if err != nil {
-				return nil, err.(app_error.AppError)
+	var value *T
+	var missing, stale bool
+	err := cacher.breaker.Do(ctx, func() (err error) {
+		value, missing, stale, err = cacher.read(ctx, key)
+		return err
+	})
+	switch {
+	case err == nil && value != nil:
+		cacher.stats.hit(1)
+		if stale {
+			cacher.revalidate(ctx, key, args...)
+		}
+		return value, nil
+	case err == nil && missing:
+		cacher.stats.hit(1)
+		return nil, cacher.options.notFound
+	case err == nil:
+		cacher.stats.miss(1)
+		res, err := cacher.load(ctx, key, args...)
+		if err != nil {
+			if cacher.options.isNotFound(err) {
+				cacher.putMissing(ctx, key)
 			}
-			if err := cacher.Put(ctx, key, *res.(*T)); err != nil {
-				return nil, err
+			return nil, err
+		}
+		if err := cacher.Put(ctx, key, *res); err != nil {
+			l.Warn("failed to write back cache", append(err.ErrorField(), zap.String("key", cacher.prefix+key))...)
+		}
+		return res, nil
+	default:
+		cacher.stats.miss(1)
+		cacher.stats.fail()
+		if !errors.Is(err, ErrBreakerOpen) {
+			l.Warn("redis unavailable, fallback to loader", zap.String("key", cacher.prefix+key), zap.Error(err))
+		}
+		return cacher.load(ctx, key, args...)
+	}
+}
+
+// multiGet 只读缓存 redis 出错或熔断器打开时全部视为未命中 由 fallback 从数据库读取
+// 不检查负缓存和 stale-while-revalidate 的标记 过了 softTTL 的缓存值直接返回
+func (cacher *PlainCacher[T]) multiGet(ctx context.Context, keys []string) ([]*T, app_error.AppError) {
+	values := make([]*T, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	var cmds []redis.Cmder
+	if err := cacher.breaker.Do(ctx, func() (err error) {
+		cmds, err = cacher.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Get(ctx, cacher.prefix+key)
 			}
-			return res.(*T), nil
+			return nil
+		})
+		if errors.Is(err, redis.Nil) {
+			return nil
 		}
-		return nil, app_error.ErrRedisCache.WithError(err)
+		return err
+	}); err != nil {
+		cacher.stats.miss(len(keys))
+		cacher.stats.fail()
+		if !errors.Is(err, ErrBreakerOpen) {
+			l.Warn("redis unavailable, treat all keys as missing", zap.Error(err))
+		}
+		return values, nil
 	}
-	return value, nil
+	hits := 0
+	for i, cmd := range cmds {
+		value := new(T)
+		if err := cmd.(*redis.StringCmd).Scan(value); err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			cacher.stats.fail()
+			return nil, app_error.ErrRedisCache.WithError(err)
+		}
+		values[i] = value
+		hits++
+	}
+	cacher.stats.hit(hits)
+	cacher.stats.miss(len(keys) - hits)
+	return values, nil
+}
+
+func (cacher *PlainCacher[T]) MGet(ctx context.Context, keys []string, fallback BatchFallback[T]) ([]*T, app_error.AppError) {
+	return mget[T](ctx, cacher, cacher.multiGet, cacher.single, cacher.stats, keys, fallback)
+}
+
+func (cacher *PlainCacher[T]) MPut(ctx context.Context, values map[string]T, ttls map[string]time.Duration) app_error.AppError {
+	if len(values) == 0 {
+		return nil
+	}
+	keys := make([]any, 0, len(values))
+	for key := range values {
+		keys = append(keys, cacher.prefix+key)
+	}
+	if err := cacher.breaker.Do(ctx, func() error {
+		if err := cacher.bloomFilter.MAdd(ctx, keys...); err != nil {
+			return err
+		}
+		_, err := cacher.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for key, value := range values {
+				cacher.set(ctx, pipe, key, value, ttlOf(ttls, key, cacher.ttl))
+			}
+			return nil
+		})
+		return err
+	}); err != nil {
+		cacher.stats.fail()
+		return app_error.ErrRedisCache.WithError(err)
+	}
+	return nil
+}
+
+func (cacher *PlainCacher[T]) MInvalidate(ctx context.Context, keys []string) app_error.AppError {
+	if len(keys) == 0 {
+		return nil
+	}
+	fullKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		fullKeys = append(fullKeys, cacher.prefix+key)
+		fullKeys = append(fullKeys, cacher.markers(key)...)
+	}
+	if err := cacher.breaker.Do(ctx, func() error {
+		return cacher.client.Del(ctx, fullKeys...).Err()
+	}); err != nil {
+		cacher.stats.fail()
+		return app_error.ErrRedisCache.WithError(err)
+	}
+	return nil
 }
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"my_zhihu_backend/app/app_error"
+
+	redis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainCacher_WithContainer(t *testing.T) {
+	ctx := context.TODO()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379", // TODO: 使用 testcontainer 报错 目前回退本地redis服务
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("redis is not available:", err)
+	}
+	prefix := "plain-test:" + strconv.FormatInt(time.Now().UnixNano(), 10) + ":" // 每次运行使用不同的前缀 不受上次留下的数据影响
+	bloom := NewLocalBloomFilter()
+	assert.Nil(t, bloom.MAdd(ctx, prefix+"missing", prefix+"stale"))
+
+	t.Run("Test_Negative_Cache", func(t *testing.T) {
+		var fallbackCalled atomic.Int32
+		cacher := NewPlainCacher[string](client, time.Minute, prefix, func(ctx context.Context, args ...any) (*string, app_error.AppError) {
+			fallbackCalled.Add(1)
+			return nil, app_error.ErrUserNotExists
+		}, bloom, WithNegativeCache(time.Minute, app_error.ErrUserNotExists))
+
+		// 第一次读取执行 fallback 并记录不存在 之后直接返回 notFound
+		for range 3 {
+			_, err := cacher.Get(ctx, "missing")
+			assert.ErrorIs(t, err, app_error.ErrUserNotExists)
+		}
+		assert.Equal(t, int32(1), fallbackCalled.Load())
+
+		// Put 清除不存在的标记
+		assert.Nil(t, cacher.Put(ctx, "missing", "created"))
+		val, err := cacher.Get(ctx, "missing")
+		assert.Nil(t, err)
+		assert.Equal(t, "created", *val)
+		assert.Equal(t, int32(1), fallbackCalled.Load())
+	})
+
+	t.Run("Test_Stale_While_Revalidate", func(t *testing.T) {
+		var fallbackCalled atomic.Int32
+		release := make(chan struct{})
+		cacher := NewPlainCacher[string](client, time.Minute, prefix, func(ctx context.Context, args ...any) (*string, app_error.AppError) {
+			fallbackCalled.Add(1)
+			<-release
+			value := "new"
+			return &value, nil
+		}, bloom, WithStaleWhileRevalidate(100*time.Millisecond))
+
+		assert.Nil(t, cacher.Put(ctx, "stale", "old"))
+		time.Sleep(150 * time.Millisecond)
+
+		// 过了 softTTL 的值直接返回 只有一个读取者在后台刷新
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				val, err := cacher.Get(ctx, "stale")
+				assert.Nil(t, err)
+				assert.Equal(t, "old", *val)
+			}()
+		}
+		wg.Wait()
+		close(release)
+		assert.Eventually(t, func() bool {
+			val, err := cacher.Get(ctx, "stale")
+			return err == nil && *val == "new"
+		}, 2*time.Second, 20*time.Millisecond)
+		assert.Equal(t, int32(1), fallbackCalled.Load())
+	})
+}
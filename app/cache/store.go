@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KVStore 带有效期的字符串键值 用于不经过 Cacher 的数据 如 refresh token
+type KVStore interface {
+	Set(ctx context.Context, key, value string, ttl time.Duration) app_error.AppError
+	Get(ctx context.Context, key string) (string, app_error.AppError) // 不存在时返回 ErrRedisCacheKeyNotExists
+	Del(ctx context.Context, key string) app_error.AppError
+}
+
+type RedisKVStore struct {
+	client *redis.Client
+}
+
+func NewRedisKVStore(client *redis.Client) *RedisKVStore {
+	return &RedisKVStore{client: client}
+}
+
+func (s *RedisKVStore) Set(ctx context.Context, key, value string, ttl time.Duration) app_error.AppError {
+	if err := s.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		return app_error.ErrRedisCache.WithError(err)
+	}
+	return nil
+}
+
+func (s *RedisKVStore) Get(ctx context.Context, key string) (string, app_error.AppError) {
+	value, err := s.client.Get(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", app_error.ErrRedisCacheKeyNotExists
+		}
+		return "", app_error.ErrRedisCache.WithError(err)
+	}
+	return value, nil
+}
+
+func (s *RedisKVStore) Del(ctx context.Context, key string) app_error.AppError {
+	if err := s.client.Del(ctx, key).Err(); err != nil {
+		return app_error.ErrRedisCache.WithError(err)
+	}
+	return nil
+}
+
+// MemoryKVStore 配合 MemoryCacher 使用的 KVStore
+type MemoryKVStore struct {
+	store *memoryStore
+}
+
+func NewMemoryKVStore() *MemoryKVStore {
+	return &MemoryKVStore{store: defaultMemoryStore}
+}
+
+func (s *MemoryKVStore) Set(_ context.Context, key, value string, ttl time.Duration) app_error.AppError {
+	s.store.set(key, value, ttl)
+	return nil
+}
+
+func (s *MemoryKVStore) Get(_ context.Context, key string) (string, app_error.AppError) {
+	value, ok := s.store.get(key)
+	if !ok {
+		return "", app_error.ErrRedisCacheKeyNotExists
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", app_error.ErrRedisCacheKeyNotExists
+	}
+	return str, nil
+}
+
+func (s *MemoryKVStore) Del(_ context.Context, key string) app_error.AppError {
+	s.store.getDel(key)
+	return nil
+}
+
+// LexIndex 按字节序排列的字符串集合 可以按前缀查找 用于自动补全
+type LexIndex interface {
+	Update(ctx context.Context, remove, add string) app_error.AppError // 删除 remove 并加入 add 为空表示不需要
+	Exists(ctx context.Context) (bool, app_error.AppError)
+	// Rebuild 通过 load 分批加入所有成员 与重建期间 Update 加入的成员合并 load 没有加入任何成员时不创建索引
+	Rebuild(ctx context.Context, load func(add func(members ...string) error) error) app_error.AppError
+	Range(ctx context.Context, prefix string, count int) ([]string, app_error.AppError) // 以 prefix 开头的前 count 个成员
+}
+
+// RedisLexIndex 使用所有分值都为 0 的 sorted set 同分值的成员按字节序排列 通过 ZRANGEBYLEX 按前缀查找
+type RedisLexIndex struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedisLexIndex(client *redis.Client, key string) *RedisLexIndex {
+	return &RedisLexIndex{client: client, key: key}
+}
+
+func (i *RedisLexIndex) Update(ctx context.Context, remove, add string) app_error.AppError {
+	_, err := i.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if remove != "" {
+			pipe.ZRem(ctx, i.key, remove)
+		}
+		if add != "" {
+			pipe.ZAdd(ctx, i.key, redis.Z{Member: add})
+		}
+		return nil
+	})
+	if err != nil {
+		return app_error.ErrRedisCache.WithError(err)
+	}
+	return nil
+}
+
+func (i *RedisLexIndex) Exists(ctx context.Context) (bool, app_error.AppError) {
+	n, err := i.client.Exists(ctx, i.key).Result()
+	if err != nil {
+		return false, app_error.ErrRedisCache.WithError(err)
+	}
+	return n > 0, nil
+}
+
+// Rebuild 先写入临时的 key 再改名 重建期间 Update 已经创建了索引时合并两者 避免覆盖
+func (i *RedisLexIndex) Rebuild(ctx context.Context, load func(add func(members ...string) error) error) app_error.AppError {
+	tmpKey := i.key + "::rebuilding"
+	count := 0
+	err := load(func(members ...string) error {
+		if len(members) == 0 {
+			return nil
+		}
+		zs := make([]redis.Z, 0, len(members))
+		for _, member := range members {
+			zs = append(zs, redis.Z{Member: member})
+		}
+		count += len(members)
+		return i.client.ZAdd(ctx, tmpKey, zs...).Err()
+	})
+	if err != nil {
+		return loadError(err)
+	}
+	if count == 0 {
+		return nil
+	}
+	ok, err := i.client.RenameNX(ctx, tmpKey, i.key).Result()
+	if err == nil && !ok {
+		_, err = i.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZUnionStore(ctx, i.key, &redis.ZStore{Keys: []string{i.key, tmpKey}})
+			pipe.Del(ctx, tmpKey)
+			return nil
+		})
+	}
+	if err != nil {
+		return app_error.ErrRedisCache.WithError(err)
+	}
+	return nil
+}
+
+func (i *RedisLexIndex) Range(ctx context.Context, prefix string, count int) ([]string, app_error.AppError) {
+	members, err := i.client.ZRangeByLex(ctx, i.key, &redis.ZRangeBy{
+		Min:   "[" + prefix,
+		Max:   "[" + prefix + "\xff",
+		Count: int64(count),
+	}).Result()
+	if err != nil {
+		return nil, app_error.ErrRedisCache.WithError(err)
+	}
+	return members, nil
+}
+
+// loadError load 返回的 AppError 原样返回 其余的是写入 redis 时的错误
+func loadError(err error) app_error.AppError {
+	var appErr app_error.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return app_error.ErrRedisCache.WithError(err)
+}
+
+// MemoryLexIndex 配合 MemoryCacher 使用的 LexIndex
+type MemoryLexIndex struct {
+	store *memoryStore
+	key   string
+}
+
+func NewMemoryLexIndex(key string) *MemoryLexIndex {
+	return &MemoryLexIndex{store: defaultMemoryStore, key: key}
+}
+
+func (i *MemoryLexIndex) Update(_ context.Context, remove, add string) app_error.AppError {
+	i.store.lexUpdate(i.key, remove, add)
+	return nil
+}
+
+func (i *MemoryLexIndex) Exists(_ context.Context) (bool, app_error.AppError) {
+	return i.store.lexExists(i.key), nil
+}
+
+func (i *MemoryLexIndex) Rebuild(_ context.Context, load func(add func(members ...string) error) error) app_error.AppError {
+	var all []string
+	err := load(func(members ...string) error {
+		all = append(all, members...)
+		return nil
+	})
+	if err != nil {
+		return loadError(err)
+	}
+	if len(all) > 0 {
+		i.store.lexMerge(i.key, all)
+	}
+	return nil
+}
+
+func (i *MemoryLexIndex) Range(_ context.Context, prefix string, count int) ([]string, app_error.AppError) {
+	return i.store.lexRange(i.key, prefix, count), nil
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my_zhihu_backend/app/app_error"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	generationSuffix   = "::gen"        // 记录标签被清除次数的键的后缀
+	tagGenerationTTL   = 24 * time.Hour // 清除次数的有效期 远大于一次请求的耗时
+	purgeDeleteBatches = 500            // 清除时每个 pipeline 删除的缓存键数
+)
+
+// UserTag 与用户相关的缓存使用的标签
+func UserTag(id any) string {
+	return fmt.Sprintf("user:%v", id)
+}
+
+// TagIndex 记录每个标签下的缓存键 用于按标签批量删除缓存
+// 生成响应前后各读取一次 Generation 结果不同说明期间有标签被清除 写入的缓存可能已经过期
+type TagIndex interface {
+	Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) app_error.AppError // 将完整的缓存键 key 加入各个标签
+	Purge(ctx context.Context, tags ...string) app_error.AppError                              // 删除带有任一标签的缓存
+	Generation(ctx context.Context, tags ...string) (int64, app_error.AppError)                // 这些标签被清除的次数之和
+}
+
+// RedisTagIndex 使用 redis set 记录每个标签下的缓存键
+type RedisTagIndex struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisTagIndex(client *redis.Client, prefix string) *RedisTagIndex {
+	return &RedisTagIndex{client: client, prefix: prefix}
+}
+
+// Tag 将完整的缓存键 key 加入各个标签 标签集合的过期时间延长到 ttl 不会早于最后加入的键过期
+func (t *RedisTagIndex) Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) app_error.AppError {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			pipe.SAdd(ctx, t.prefix+tag, key)
+			pipe.Expire(ctx, t.prefix+tag, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return app_error.ErrRedisCache.WithError(err)
+	}
+	return nil
+}
+
+// Purge 删除带有任一标签的缓存 先增加清除次数 再取出并删除标签集合 最后逐个删除缓存键
+// 所有命令都只访问单个键 可以在 redis cluster 中使用 取出集合之后才加入的键由 Generation 的检查处理
+func (t *RedisTagIndex) Purge(ctx context.Context, tags ...string) app_error.AppError {
+	if len(tags) == 0 {
+		return nil
+	}
+	members := make([]*redis.StringSliceCmd, 0, len(tags))
+	_, err := t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			pipe.Incr(ctx, t.prefix+tag+generationSuffix)
+			pipe.Expire(ctx, t.prefix+tag+generationSuffix, tagGenerationTTL)
+			members = append(members, pipe.SMembers(ctx, t.prefix+tag))
+			pipe.Del(ctx, t.prefix+tag)
+		}
+		return nil
+	})
+	if err != nil {
+		return app_error.ErrRedisCache.WithError(err)
+	}
+
+	var keys []string
+	for _, cmd := range members {
+		keys = append(keys, cmd.Val()...)
+	}
+	for i := 0; i < len(keys); i += purgeDeleteBatches {
+		_, err := t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys[i:min(i+purgeDeleteBatches, len(keys))] {
+				pipe.Del(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return app_error.ErrRedisCache.WithError(err)
+		}
+	}
+	return nil
+}
+
+func (t *RedisTagIndex) Generation(ctx context.Context, tags ...string) (int64, app_error.AppError) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	cmds := make([]*redis.StringCmd, 0, len(tags))
+	_, err := t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			cmds = append(cmds, pipe.Get(ctx, t.prefix+tag+generationSuffix))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, app_error.ErrRedisCache.WithError(err)
+	}
+	var gen int64
+	for _, cmd := range cmds {
+		n, _ := cmd.Int64() // 不存在时为 0
+		gen += n
+	}
+	return gen, nil
+}
+
+// MemoryTagIndex 配合 MemoryCacher 使用的 TagIndex
+type MemoryTagIndex struct {
+	store  *memoryStore
+	prefix string
+}
+
+func NewMemoryTagIndex(prefix string) *MemoryTagIndex {
+	return &MemoryTagIndex{store: defaultMemoryStore, prefix: prefix}
+}
+
+func (t *MemoryTagIndex) Tag(_ context.Context, key string, ttl time.Duration, tags ...string) app_error.AppError {
+	for _, tag := range tags {
+		t.store.sAdd(t.prefix+tag, key, ttl)
+	}
+	return nil
+}
+
+func (t *MemoryTagIndex) Purge(_ context.Context, tags ...string) app_error.AppError {
+	for _, tag := range tags {
+		t.store.purgeSet(t.prefix + tag)
+	}
+	return nil
+}
+
+func (t *MemoryTagIndex) Generation(_ context.Context, tags ...string) (int64, app_error.AppError) {
+	sets := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		sets = append(sets, t.prefix+tag)
+	}
+	return t.store.generation(sets...), nil
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"my_zhihu_backend/app/app_error"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// warmUpBatchSize 预热时一次 MPut 写入的条目数
+const warmUpBatchSize = 100
+
+// WarmUp 通过 MPut 分批写入 values 每个 key 的有效期随机设置为 TTL 的 1/2 到 1 之间 避免预热的 key 同时过期
+// 有效期与值在同一个 pipeline 中写入 写入失败时返回错误
+func WarmUp[T any](ctx context.Context, cacher Cacher[T], values map[string]T) app_error.AppError {
+	batch := make(map[string]T, warmUpBatchSize)
+	flush := func() app_error.AppError {
+		if err := cacher.MPut(ctx, batch, staggeredTTLs(cacher.TTL(), maps.Keys(batch))); err != nil {
+			return err
+		}
+		clear(batch)
+		return nil
+	}
+	for key, value := range values {
+		batch[key] = value
+		if len(batch) >= warmUpBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// hotKeys 统计一个窗口内每个 key 的读取次数
+type hotKeys struct {
+	mu          sync.Mutex
+	window      time.Duration
+	threshold   int
+	windowStart time.Time
+	counts      map[string]int
+}
+
+// hit 记录一次读取 在窗口内刚好达到阈值时返回 true 每个 key 每个窗口最多返回一次
+func (h *hotKeys) hit(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now := time.Now(); now.Sub(h.windowStart) >= h.window {
+		clear(h.counts)
+		h.windowStart = now
+	}
+	h.counts[key]++
+	return h.counts[key] == h.threshold
+}
+
+// HotKeyCacher 统计 window 内每个 key 成功读取的次数 达到 threshold 时通过 Renew 把有效期重新设置为 TTL 热点 key 不会过期
+// 应该放在最外层 进程内缓存的命中也计入读取次数
+type HotKeyCacher[T any] struct {
+	base Cacher[T]
+	hot  *hotKeys
+}
+
+func NewHotKeyCacher[T any](base Cacher[T], window time.Duration, threshold int) *HotKeyCacher[T] {
+	return &HotKeyCacher[T]{
+		base: base,
+		hot: &hotKeys{
+			window:    window,
+			threshold: threshold,
+			counts:    make(map[string]int),
+		},
+	}
+}
+
+// record 记录读取 达到阈值时在后台延长有效期
+func (cacher *HotKeyCacher[T]) record(ctx context.Context, key string) {
+	if !cacher.hot.hit(key) {
+		return
+	}
+	go func() {
+		timeout, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := cacher.base.Renew(timeout, key, cacher.base.TTL()); err != nil && !errors.Is(err, app_error.ErrRedisCacheKeyNotExists) {
+			l.Warn("failed to renew hot key", append(err.ErrorField(), zap.String("key", cacher.base.Prefix()+key))...)
+			return
+		}
+		l.Debug("hot key renewed", zap.String("key", cacher.base.Prefix()+key))
+	}()
+}
+
+func (cacher *HotKeyCacher[T]) Put(ctx context.Context, key string, value T) app_error.AppError {
+	return cacher.base.Put(ctx, key, value)
+}
+
+func (cacher *HotKeyCacher[T]) Get(ctx context.Context, key string, args ...any) (*T, app_error.AppError) {
+	value, err := cacher.base.Get(ctx, key, args...)
+	if err == nil {
+		cacher.record(ctx, key)
+	}
+	return value, err
+}
+
+func (cacher *HotKeyCacher[T]) MGet(ctx context.Context, keys []string, fallback BatchFallback[T]) ([]*T, app_error.AppError) {
+	values, err := cacher.base.MGet(ctx, keys, fallback)
+	if err == nil {
+		cacher.recordAll(ctx, keys, values)
+	}
+	return values, err
+}
+
+func (cacher *HotKeyCacher[T]) recordAll(ctx context.Context, keys []string, values []*T) {
+	for i, value := range values {
+		if value != nil {
+			cacher.record(ctx, keys[i])
+		}
+	}
+}
+
+func (cacher *HotKeyCacher[T]) MPut(ctx context.Context, values map[string]T, ttls map[string]time.Duration) app_error.AppError {
+	return cacher.base.MPut(ctx, values, ttls)
+}
+
+func (cacher *HotKeyCacher[T]) MInvalidate(ctx context.Context, keys []string) app_error.AppError {
+	return cacher.base.MInvalidate(ctx, keys)
+}
+
+func (cacher *HotKeyCacher[T]) Renew(ctx context.Context, key string, ttl time.Duration) app_error.AppError {
+	return cacher.base.Renew(ctx, key, ttl)
+}
+
+func (cacher *HotKeyCacher[T]) Invalidate(ctx context.Context, key string) (*T, app_error.AppError) {
+	return cacher.base.Invalidate(ctx, key)
+}
+
+func (cacher *HotKeyCacher[T]) Fallback() Fallback[T] {
+	return cacher.base.Fallback()
+}
+
+func (cacher *HotKeyCacher[T]) TTL() time.Duration {
+	return cacher.base.TTL()
+}
+
+func (cacher *HotKeyCacher[T]) Prefix() string {
+	return cacher.base.Prefix()
+}
+
+func (cacher *HotKeyCacher[T]) BloomFilter() BloomFilter {
+	return cacher.base.BloomFilter()
+}
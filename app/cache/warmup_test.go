@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"my_zhihu_backend/app/app_error"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmUpAndHotKeys(t *testing.T) {
+	ctx := context.TODO()
+	base := NewMemoryCacher[TestUser](time.Hour, "warmup-test:", func(ctx context.Context, args ...any) (*TestUser, app_error.AppError) {
+		return nil, app_error.ErrRedisCacheKeyNotExists
+	}, NewLocalBloomFilter())
+
+	values := make(map[string]TestUser)
+	for i := range 150 {
+		values[fmt.Sprintf("%d", i)] = TestUser{ID: i}
+	}
+	assert.Nil(t, WarmUp[TestUser](ctx, base, values))
+	samples, _ := SampleTTLs(ctx, BackendMemory, nil, "warmup-test:", 150)
+	assert.Len(t, samples, 150)
+	assertStaggered(t, samples, base.TTL())
+
+	cacher := NewHotKeyCacher[TestUser](base, time.Minute, 3)
+	assert.Nil(t, base.Renew(ctx, "1", time.Minute))
+	for range 3 {
+		_, err := cacher.Get(ctx, "1")
+		assert.Nil(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		samples, _ := SampleTTLs(ctx, BackendMemory, nil, "warmup-test:1", len(values))
+		for _, sample := range samples {
+			if sample.Key == "warmup-test:1" {
+				return sample.TTL > 30*time.Minute
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMGetWriteBackStaggered(t *testing.T) {
+	ctx := context.TODO()
+	cacher := NewMemoryCacher[TestUser](time.Hour, "writeback-test:", nil, NewLocalBloomFilter())
+	keys := make([]string, 0, 150)
+	for i := range 150 {
+		keys = append(keys, fmt.Sprintf("%d", i))
+	}
+	_, err := cacher.MGet(ctx, keys, func(ctx context.Context, keys []string) (map[string]*TestUser, app_error.AppError) {
+		loaded := make(map[string]*TestUser, len(keys))
+		for i, key := range keys {
+			loaded[key] = &TestUser{ID: i}
+		}
+		return loaded, nil
+	})
+	assert.Nil(t, err)
+	samples, _ := SampleTTLs(ctx, BackendMemory, nil, "writeback-test:", 150)
+	assert.Len(t, samples, 150)
+	assertStaggered(t, samples, cacher.TTL())
+}
+
+// assertStaggered 有效期都在 ttl 的 1/2 到 1 之间 并且是错开的
+func assertStaggered(t *testing.T, samples []KeyTTL, ttl time.Duration) {
+	lowest, highest := ttl, time.Duration(0)
+	for _, sample := range samples {
+		assert.LessOrEqual(t, sample.TTL, ttl)
+		assert.GreaterOrEqual(t, sample.TTL, ttl/2-time.Second)
+		lowest, highest = min(lowest, sample.TTL), max(highest, sample.TTL)
+	}
+	assert.Greater(t, highest-lowest, ttl/4)
+}
@@ -24,7 +24,8 @@ type Config struct {
 }
 
 type AppConfig struct {
-	ListenAddr string `mapstructure:"LISTEN_ADDR" yaml:"listenAddr"`
+	ListenAddr  string `mapstructure:"LISTEN_ADDR" yaml:"listenAddr"`
+	MetricsAddr string `mapstructure:"METRICS_ADDR" yaml:"metricsAddr"` // prometheus 指标的监听地址 与 ListenAddr 分开 不对外暴露 为空时不启动
 }
 
 type RedisConfig struct {
@@ -42,6 +43,36 @@ type ServiceConfig struct {
 	RefreshTokenExp time.Duration `mapstructure:"REFRESH_TOKEN_EXP" yaml:"refreshTokenExp"`
 	AccessTokenExp  time.Duration `mapstructure:"ACCESS_TOKEN_EXP" yaml:"accessTokenExp"`
 	Timeout         time.Duration `mapstructure:"TIMEOUT" yaml:"timeout"`
+
+	InvitationDailyLimit    int           `mapstructure:"INVITATION_DAILY_LIMIT" yaml:"invitationDailyLimit"`       // 每个用户每天最多发出的邀请回答数
+	PublishScheduleInterval time.Duration `mapstructure:"PUBLISH_SCHEDULE_INTERVAL" yaml:"publishScheduleInterval"` // 定时发布草稿的检查间隔
+
+	UploadDir             string        `mapstructure:"UPLOAD_DIR" yaml:"uploadDir"`                          // 本地文件存储的根目录
+	UploadMaxSize         int64         `mapstructure:"UPLOAD_MAX_SIZE" yaml:"uploadMaxSize"`                 // 单个文件的最大字节数
+	UploadURLPrefix       string        `mapstructure:"UPLOAD_URL_PREFIX" yaml:"uploadURLPrefix"`             // 下载链接前缀 可以配置为完整的域名地址
+	UploadSignSecret      string        `mapstructure:"UPLOAD_SIGN_SECRET" yaml:"uploadSignSecret"`           // 下载链接签名密钥 没有默认值 必须配置
+	UploadURLExpire       time.Duration `mapstructure:"UPLOAD_URL_EXPIRE" yaml:"uploadURLExpire"`             // 非图片文件下载链接的有效期
+	UploadOrphanTTL       time.Duration `mapstructure:"UPLOAD_ORPHAN_TTL" yaml:"uploadOrphanTTL"`             // 上传后未被引用的文件保留时间
+	UploadCleanupInterval time.Duration `mapstructure:"UPLOAD_CLEANUP_INTERVAL" yaml:"uploadCleanupInterval"` // 清理未引用文件的间隔
+
+	HandleChangeCooldown time.Duration `mapstructure:"HANDLE_CHANGE_COOLDOWN" yaml:"handleChangeCooldown"` // 两次修改 handle 的最小间隔
+	HandleReserveTTL     time.Duration `mapstructure:"HANDLE_RESERVE_TTL" yaml:"handleReserveTTL"`         // 旧 handle 保留给原用户的时间 之后其他用户可以使用
+
+	CacheBackend                string        `mapstructure:"CACHE_BACKEND" yaml:"cacheBackend"`                                 // 缓存使用的存储 redis 或 memory(进程内 只适合单实例部署和测试)
+	CacheBreakerThreshold       int           `mapstructure:"CACHE_BREAKER_THRESHOLD" yaml:"cacheBreakerThreshold"`              // redis 连续失败多少次后熔断
+	CacheBreakerCooldown        time.Duration `mapstructure:"CACHE_BREAKER_COOLDOWN" yaml:"cacheBreakerCooldown"`                // 熔断后多久重新尝试访问 redis
+	BloomRotateInterval         time.Duration `mapstructure:"BLOOM_ROTATE_INTERVAL" yaml:"bloomRotateInterval"`                  // 定期重建布隆过滤器的间隔
+	BloomCheckInterval          time.Duration `mapstructure:"BLOOM_CHECK_INTERVAL" yaml:"bloomCheckInterval"`                    // 检查布隆过滤器误判率的间隔
+	BloomFalsePositiveThreshold float64       `mapstructure:"BLOOM_FALSE_POSITIVE_THRESHOLD" yaml:"bloomFalsePositiveThreshold"` // 估计的误判率超过该值时重建
+	NegativeCacheTTL            time.Duration `mapstructure:"NEGATIVE_CACHE_TTL" yaml:"negativeCacheTTL"`                        // 不存在的用户在缓存中记录的时间 0 表示不记录
+	UserInfoSoftTTL             time.Duration `mapstructure:"USER_INFO_SOFT_TTL" yaml:"userInfoSoftTTL"`                         // 用户信息缓存写入后超过该时间 读取时返回旧值并在后台刷新 0 表示不开启
+	WarmUpUserCount             int           `mapstructure:"WARM_UP_USER_COUNT" yaml:"warmUpUserCount"`                         // 启动时预热粉丝最多的多少个用户的信息 0 表示不预热
+	WarmUpQuestionCount         int           `mapstructure:"WARM_UP_QUESTION_COUNT" yaml:"warmUpQuestionCount"`                 // 启动时预热浏览最多的多少个问题 0 表示不预热
+	ViewFlushInterval           time.Duration `mapstructure:"VIEW_FLUSH_INTERVAL" yaml:"viewFlushInterval"`                      // 把内存中累计的问题浏览次数写入数据库的间隔
+	HotKeyWindow                time.Duration `mapstructure:"HOT_KEY_WINDOW" yaml:"hotKeyWindow"`                                // 统计热点 key 读取次数的窗口
+	HotKeyThreshold             int           `mapstructure:"HOT_KEY_THRESHOLD" yaml:"hotKeyThreshold"`                          // 一个窗口内读取达到该次数的 key 延长有效期 0 表示不开启
+	LocalCacheSize              int           `mapstructure:"LOCAL_CACHE_SIZE" yaml:"localCacheSize"`                            // 进程内缓存的最大条目数
+	LocalCacheTTL               time.Duration `mapstructure:"LOCAL_CACHE_TTL" yaml:"localCacheTTL"`                              // 进程内缓存的有效期 也是失效消息丢失时的最长不一致时间
 }
 
 type RedisPrefixConfig struct {
@@ -49,6 +80,15 @@ type RedisPrefixConfig struct {
 
 	UserInfoPrefix   string `mapstructure:"USERINFO_PREFIX" yaml:"userInfoPrefix"`
 	UserSearchPrefix string `mapstructure:"USER_SEARCH_PREFIX" yaml:"userSearchPrefix"`
+	UsernameIndex    string `mapstructure:"USERNAME_INDEX" yaml:"usernameIndex"` // 用户名自动补全使用的 sorted set
+
+	QuestionInfoPrefix string `mapstructure:"QUESTION_INFO_PREFIX" yaml:"questionInfoPrefix"`
+
+	ResponseTagPrefix string `mapstructure:"RESPONSE_TAG_PREFIX" yaml:"responseTagPrefix"` // 接口响应缓存的标签集合
+
+	CacheInvalidationChannel string `mapstructure:"CACHE_INVALIDATION_CHANNEL" yaml:"cacheInvalidationChannel"` // 通知各实例删除进程内缓存的 pub/sub 频道
+
+	RenderedContentPrefix string `mapstructure:"RENDERED_CONTENT_PREFIX" yaml:"renderedContentPrefix"`
 }
 
 var cfg Config
@@ -57,6 +97,7 @@ var rmu sync.RWMutex
 func InitConfig() {
 	// 设置默认值
 	viper.SetDefault("app.LISTEN_ADDR", ":8080")
+	viper.SetDefault("app.METRICS_ADDR", "127.0.0.1:9090")
 	viper.SetDefault("mysql.HOST", "127.0.0.1")
 	viper.SetDefault("mysql.PORT", 3306)
 	viper.SetDefault("mysql.DB_NAME", "zhihu")
@@ -66,9 +107,40 @@ func InitConfig() {
 	viper.SetDefault("prefix.REFRESH_TOKEN", "refreshToken::")
 	viper.SetDefault("prefix.USERINFO_PREFIX", "userInfo::")
 	viper.SetDefault("prefix.USER_SEARCH_PREFIX", "userSearch::")
+	viper.SetDefault("prefix.USERNAME_INDEX", "usernameIndex")
+	viper.SetDefault("prefix.QUESTION_INFO_PREFIX", "questionInfo::")
+	viper.SetDefault("prefix.RESPONSE_TAG_PREFIX", "responseTag::")
+	viper.SetDefault("prefix.CACHE_INVALIDATION_CHANNEL", "cacheInvalidation")
+	viper.SetDefault("prefix.RENDERED_CONTENT_PREFIX", "renderedContent::")
 	viper.SetDefault("service.REFRESH_TOKEN_EXP", 7*24*time.Hour)
 	viper.SetDefault("service.ACCESS_TOKEN_EXP", 5*time.Minute)
 	viper.SetDefault("service.TIMEOUT", 5*time.Second)
+	viper.SetDefault("service.INVITATION_DAILY_LIMIT", 20)
+	viper.SetDefault("service.PUBLISH_SCHEDULE_INTERVAL", 30*time.Second)
+	viper.SetDefault("service.UPLOAD_DIR", "./uploads")
+	viper.SetDefault("service.UPLOAD_MAX_SIZE", 10<<20)
+	viper.SetDefault("service.UPLOAD_URL_PREFIX", "/uploads")
+	_ = viper.BindEnv("service.UPLOAD_SIGN_SECRET") // 没有默认值 需要绑定后 Unmarshal 才会读取环境变量
+	viper.SetDefault("service.UPLOAD_URL_EXPIRE", time.Hour)
+	viper.SetDefault("service.UPLOAD_ORPHAN_TTL", 24*time.Hour)
+	viper.SetDefault("service.UPLOAD_CLEANUP_INTERVAL", time.Hour)
+	viper.SetDefault("service.HANDLE_CHANGE_COOLDOWN", 30*24*time.Hour)
+	viper.SetDefault("service.HANDLE_RESERVE_TTL", 90*24*time.Hour)
+	viper.SetDefault("service.CACHE_BACKEND", "redis")
+	viper.SetDefault("service.CACHE_BREAKER_THRESHOLD", 5)
+	viper.SetDefault("service.CACHE_BREAKER_COOLDOWN", 10*time.Second)
+	viper.SetDefault("service.BLOOM_ROTATE_INTERVAL", 24*time.Hour)
+	viper.SetDefault("service.BLOOM_CHECK_INTERVAL", 10*time.Minute)
+	viper.SetDefault("service.BLOOM_FALSE_POSITIVE_THRESHOLD", 0.1)
+	viper.SetDefault("service.NEGATIVE_CACHE_TTL", time.Minute)
+	viper.SetDefault("service.USER_INFO_SOFT_TTL", 10*time.Minute)
+	viper.SetDefault("service.WARM_UP_USER_COUNT", 1000)
+	viper.SetDefault("service.WARM_UP_QUESTION_COUNT", 1000)
+	viper.SetDefault("service.VIEW_FLUSH_INTERVAL", 10*time.Second)
+	viper.SetDefault("service.HOT_KEY_WINDOW", time.Minute)
+	viper.SetDefault("service.HOT_KEY_THRESHOLD", 100)
+	viper.SetDefault("service.LOCAL_CACHE_SIZE", 10000)
+	viper.SetDefault("service.LOCAL_CACHE_TTL", 30*time.Second)
 
 	// 设置配置文件查找路径
 	viper.SetConfigName("config")
@@ -94,6 +166,9 @@ func InitConfig() {
 	if err != nil {
 		l.Panic("failed to unmarshal config", zap.Error(err))
 	}
+	if cfg.Service.UploadSignSecret == "" {
+		l.Panic("service.UPLOAD_SIGN_SECRET is required, set it in the config file or SERVICE_UPLOAD_SIGN_SECRET")
+	}
 
 	l.Info("config loaded", zap.Any("config", cfg))
 }
@@ -23,6 +23,64 @@ func NewArticleController(as *service.ArticleService) *ArticleController {
 	return &ArticleController{as, config.C}
 }
 
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.DateTime)
+}
+
+func (ctrl *ArticleController) newMentionsResponse(ctx context.Context, target model.MentionTarget, targetId int64) []response.MentionResponse {
+	mentions := ctrl.service.ListMentions(ctx, target, targetId)
+	records := make([]response.MentionResponse, 0, len(mentions))
+	for _, m := range mentions {
+		records = append(records, response.MentionResponse{
+			UserId:   m.UserId,
+			Username: m.Username,
+			Start:    m.Start,
+			End:      m.End,
+		})
+	}
+	return records
+}
+
+func (ctrl *ArticleController) newQuestionResponse(ctx context.Context, q *model.Question) response.QuestionResponse {
+	return response.QuestionResponse{
+		ID:               q.ID,
+		Title:            q.Title,
+		Content:          q.Content,
+		ContentHTML:      ctrl.service.RenderContent(ctx, q.Content),
+		Mentions:         ctrl.newMentionsResponse(ctx, model.MentionTargetQuestion, q.ID),
+		AuthorId:         q.AuthorId,
+		IsAvailable:      q.IsAvailable,
+		Status:           q.Status,
+		DuplicateOf:      q.DuplicateOfId,
+		AcceptedAnswerId: q.AcceptedAnswerId,
+		IsDraft:          q.IsDraft,
+		Version:          q.Version,
+		ScheduledAt:      formatOptionalTime(q.ScheduledAt),
+		UpdatedAt:        q.UpdatedAt.Format(time.DateTime),
+	}
+}
+
+func (ctrl *ArticleController) newAnswerResponse(ctx context.Context, a *model.Answer) response.AnswerResponse {
+	return response.AnswerResponse{
+		ID:          a.ID,
+		QuestionId:  a.QuestionId,
+		Content:     a.Content,
+		ContentHTML: ctrl.service.RenderContent(ctx, a.Content),
+		Mentions:    ctrl.newMentionsResponse(ctx, model.MentionTargetAnswer, a.ID),
+		AuthorId:    a.AuthorId,
+		LikeCount:   a.LikeCount,
+		IsAvailable: a.IsAvailable,
+		IsDraft:     a.IsDraft,
+		Version:     a.Version,
+		ScheduledAt: formatOptionalTime(a.ScheduledAt),
+		CreatedAt:   a.CreatedAt.Format(time.DateTime),
+		UpdatedAt:   a.UpdatedAt.Format(time.DateTime),
+	}
+}
+
 type ctrlFunc[T any] func(c *gin.Context, ctx context.Context, userId model.UserId, req *T) (*response.Response, app_error.AppError)
 
 func (ctrl *ArticleController) PostQuestion(c *gin.Context) {
@@ -32,16 +90,9 @@ func (ctrl *ArticleController) PostQuestion(c *gin.Context) {
 			return nil, err
 		}
 		return &response.Response{
-			Code: 0,
-			Ok:   true,
-			Body: response.QuestionResponse{
-				ID:          question.ID,
-				Title:       question.Title,
-				Content:     question.Content,
-				AuthorId:    question.AuthorId,
-				IsAvailable: question.IsAvailable,
-				UpdatedAt:   question.UpdatedAt.Format("2006-01-02 15:04:05"),
-			},
+			Code:    0,
+			Ok:      true,
+			Body:    ctrl.newQuestionResponse(ctx, question),
 			Message: "question posted",
 		}, nil
 	})
@@ -61,14 +112,7 @@ func (ctrl *ArticleController) UpdateQuestion(c *gin.Context) {
 				Code:    0,
 				Ok:      true,
 				Message: "question updated",
-				Body: response.QuestionResponse{
-					ID:          q.ID,
-					Title:       q.Title,
-					Content:     q.Content,
-					AuthorId:    q.AuthorId,
-					IsAvailable: q.IsAvailable,
-					UpdatedAt:   q.UpdatedAt.Format(time.DateTime),
-				},
+				Body:    ctrl.newQuestionResponse(ctx, q),
 			}, nil
 		}
 	})
@@ -109,14 +153,7 @@ func (ctrl *ArticleController) GetQuestion(c *gin.Context) {
 				Ok:            true,
 				InternalError: false,
 				Message:       "question got",
-				Body: response.QuestionResponse{
-					ID:          question.ID,
-					Title:       question.Title,
-					Content:     question.Content,
-					AuthorId:    question.AuthorId,
-					IsAvailable: question.IsAvailable,
-					UpdatedAt:   question.UpdatedAt.Format("2006-01-02 15:04:05"),
-				},
+				Body:          ctrl.newQuestionResponse(ctx, question),
 			}, nil
 		}
 	})
@@ -153,6 +190,280 @@ func (ctrl *ArticleController) ListQuestions(c *gin.Context) {
 	})
 }
 
+// WatchQuestion 关注问题
+func (ctrl *ArticleController) WatchQuestion(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		if err := ctrl.service.WatchQuestion(ctx, userId, id); err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "question watched",
+		}, nil
+	})
+}
+
+// UnwatchQuestion 取消关注问题
+func (ctrl *ArticleController) UnwatchQuestion(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		if err := ctrl.service.UnwatchQuestion(ctx, userId, id); err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "question unwatched",
+		}, nil
+	})
+}
+
+// InviteToAnswer 邀请用户回答问题
+func (ctrl *ArticleController) InviteToAnswer(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.InviteToAnswerRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		invitation, err := ctrl.service.InviteToAnswer(ctx, userId, id, req)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "invitation sent",
+			Body: response.InvitationResponse{
+				ID:         invitation.ID,
+				QuestionId: invitation.QuestionId,
+				InviterId:  invitation.InviterId,
+				InviteeId:  invitation.InviteeId,
+				CreatedAt:  invitation.CreatedAt.Format(time.DateTime),
+			},
+		}, nil
+	})
+}
+
+// SuggestInvitees 获取推荐邀请回答的用户
+func (ctrl *ArticleController) SuggestInvitees(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		var req request.SuggestInviteesRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		userIds, err := ctrl.service.SuggestInvitees(ctx, userId, id, req.Size)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "invitees suggested",
+			Body:    userIds,
+		}, nil
+	})
+}
+
+// UpdateQuestionStatus 变更问题状态
+func (ctrl *ArticleController) UpdateQuestionStatus(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.UpdateQuestionStatusRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		question, err := ctrl.service.UpdateQuestionStatus(ctx, userId, id, req)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "question status updated",
+			Body:    ctrl.newQuestionResponse(ctx, question),
+		}, nil
+	})
+}
+
+// AcceptAnswer 采纳回答
+func (ctrl *ArticleController) AcceptAnswer(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.AcceptAnswerRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		question, err := ctrl.service.AcceptAnswer(ctx, userId, id, req)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "answer accepted",
+			Body:    ctrl.newQuestionResponse(ctx, question),
+		}, nil
+	})
+}
+
+// UnacceptAnswer 取消采纳回答
+func (ctrl *ArticleController) UnacceptAnswer(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		question, err := ctrl.service.UnacceptAnswer(ctx, userId, id)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "answer unaccepted",
+			Body:    ctrl.newQuestionResponse(ctx, question),
+		}, nil
+	})
+}
+
+func (ctrl *ArticleController) listRevisions(c *gin.Context, target model.RevisionTarget) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.ListRevisionsRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		revisions, total, err := ctrl.service.ListRevisions(ctx, target, id, req.Page, req.Size)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+
+		records := make([]response.RevisionResponse, 0, len(revisions))
+		for _, r := range revisions {
+			records = append(records, response.RevisionResponse{
+				Version:   r.Version,
+				EditorId:  r.EditorId,
+				Title:     r.Title,
+				Content:   r.Content,
+				CreatedAt: r.CreatedAt.Format(time.DateTime),
+			})
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "revisions listed",
+			Body: response.ListRevisionsResponse{
+				Total:   total,
+				Page:    req.Page,
+				Size:    req.Size,
+				Records: records,
+			},
+		}, nil
+	})
+}
+
+func (ctrl *ArticleController) diffRevisions(c *gin.Context, target model.RevisionTarget) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.DiffRevisionsRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		titleDiff, contentDiff, err := ctrl.service.DiffRevisions(ctx, target, id, req)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "revisions compared",
+			Body: response.RevisionDiffResponse{
+				From:    req.From,
+				To:      req.To,
+				Mode:    req.Mode,
+				Title:   titleDiff,
+				Content: contentDiff,
+			},
+		}, nil
+	})
+}
+
+// ListQuestionRevisions 获取问题的修订历史
+func (ctrl *ArticleController) ListQuestionRevisions(c *gin.Context) {
+	ctrl.listRevisions(c, model.RevisionTargetQuestion)
+}
+
+// DiffQuestionRevisions 比较问题的两个版本
+func (ctrl *ArticleController) DiffQuestionRevisions(c *gin.Context) {
+	ctrl.diffRevisions(c, model.RevisionTargetQuestion)
+}
+
+// RollbackQuestion 将问题回滚到指定版本
+func (ctrl *ArticleController) RollbackQuestion(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		version, err := getInt64FromParams(c, "version")
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		question, err := ctrl.service.RollbackQuestion(ctx, userId, id, int(version))
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "question rolled back",
+			Body:    ctrl.newQuestionResponse(ctx, question),
+		}, nil
+	})
+}
+
+// ListAnswerRevisions 获取回答的修订历史
+func (ctrl *ArticleController) ListAnswerRevisions(c *gin.Context) {
+	ctrl.listRevisions(c, model.RevisionTargetAnswer)
+}
+
+// DiffAnswerRevisions 比较回答的两个版本
+func (ctrl *ArticleController) DiffAnswerRevisions(c *gin.Context) {
+	ctrl.diffRevisions(c, model.RevisionTargetAnswer)
+}
+
+// RollbackAnswer 将回答回滚到指定版本
+func (ctrl *ArticleController) RollbackAnswer(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		version, err := getInt64FromParams(c, "version")
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		answer, err := ctrl.service.RollbackAnswer(ctx, userId, id, int(version))
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "answer rolled back",
+			Body:    ctrl.newAnswerResponse(ctx, answer),
+		}, nil
+	})
+}
+
 //func (ctrl *ArticleController) PostAnswer(c *gin.Context) {
 //	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.PostNewAnswerRequest) (*response.Response, app_error.AppError) {
 //		answer, err := ctrl.service.PostNewAnswer(ctx, userId, req)
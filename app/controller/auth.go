@@ -42,17 +42,7 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 					Token:    rt,
 					ExpireAt: rExp,
 				},
-				User: response.UserResponse{
-					Id:       user.Id,
-					Username: user.Username,
-					Email:    user.Email,
-					Gender:   *user.Gender,
-					Region:   user.Region,
-					Other: response.UserOtherInfoResponse{
-						Introduction: user.Other.Introduction,
-						Icon:         user.Other.Icon,
-					},
-				},
+				User: service.NewUserResponse(user, service.SelfRelation),
 			},
 		}, nil
 	})
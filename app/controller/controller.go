@@ -72,12 +72,16 @@ func doOnlyWithUserId(c *gin.Context, ttl time.Duration, f func(ctx context.Cont
 }
 
 func getIdFromParams(c *gin.Context) (int64, error) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
+	return getInt64FromParams(c, "id")
+}
+
+func getInt64FromParams(c *gin.Context, key string) (int64, error) {
+	str := c.Param(key)
+	v, err := strconv.Atoi(str)
 	if err != nil {
 		return 0, err
 	}
-	return int64(id), nil
+	return int64(v), nil
 }
 
 func getCurrentUserID(c *gin.Context) int64 {
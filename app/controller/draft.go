@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/request"
+	"my_zhihu_backend/app/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateQuestionDraft 创建问题草稿
+func (ctrl *ArticleController) CreateQuestionDraft(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.SaveQuestionDraftRequest) (*response.Response, app_error.AppError) {
+		question, err := ctrl.service.CreateQuestionDraft(ctx, userId, req)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "question draft created",
+			Body:    ctrl.newQuestionResponse(ctx, question),
+		}, nil
+	})
+}
+
+// AutosaveQuestionDraft 自动保存问题草稿
+func (ctrl *ArticleController) AutosaveQuestionDraft(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.AutosaveQuestionDraftRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		question, err := ctrl.service.AutosaveQuestionDraft(ctx, userId, id, req)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "question draft saved",
+			Body:    ctrl.newQuestionResponse(ctx, question),
+		}, nil
+	})
+}
+
+// ListQuestionDrafts 获取当前用户的问题草稿
+func (ctrl *ArticleController) ListQuestionDrafts(c *gin.Context) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.ListDraftsRequest) (*response.Response, app_error.AppError) {
+		userId := model.UserId(getCurrentUserID(c))
+		questions, total, err := ctrl.service.ListQuestionDrafts(ctx, userId, req.Page, req.Size)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]response.QuestionResponse, 0, len(questions))
+		for i := range questions {
+			records = append(records, ctrl.newQuestionResponse(ctx, &questions[i]))
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "question drafts listed",
+			Body: response.ListQuestionDraftsResponse{
+				Total:   total,
+				Page:    req.Page,
+				Size:    req.Size,
+				Records: records,
+			},
+		}, nil
+	})
+}
+
+// PublishQuestionDraft 立即或定时发布问题草稿
+func (ctrl *ArticleController) PublishQuestionDraft(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.PublishDraftRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		question, err := ctrl.service.PublishQuestionDraft(ctx, userId, id, req)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		message := "question published"
+		if question.IsDraft {
+			message = "question scheduled"
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: message,
+			Body:    ctrl.newQuestionResponse(ctx, question),
+		}, nil
+	})
+}
+
+// CancelQuestionSchedule 取消问题草稿的定时发布
+func (ctrl *ArticleController) CancelQuestionSchedule(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		question, err := ctrl.service.CancelQuestionSchedule(ctx, userId, id)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "question schedule canceled",
+			Body:    ctrl.newQuestionResponse(ctx, question),
+		}, nil
+	})
+}
+
+// CreateAnswerDraft 创建回答草稿
+func (ctrl *ArticleController) CreateAnswerDraft(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.SaveAnswerDraftRequest) (*response.Response, app_error.AppError) {
+		answer, err := ctrl.service.CreateAnswerDraft(ctx, userId, req)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "answer draft created",
+			Body:    ctrl.newAnswerResponse(ctx, answer),
+		}, nil
+	})
+}
+
+// AutosaveAnswerDraft 自动保存回答草稿
+func (ctrl *ArticleController) AutosaveAnswerDraft(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.AutosaveAnswerDraftRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		answer, err := ctrl.service.AutosaveAnswerDraft(ctx, userId, id, req)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "answer draft saved",
+			Body:    ctrl.newAnswerResponse(ctx, answer),
+		}, nil
+	})
+}
+
+// ListAnswerDrafts 获取当前用户的回答草稿
+func (ctrl *ArticleController) ListAnswerDrafts(c *gin.Context) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.ListDraftsRequest) (*response.Response, app_error.AppError) {
+		userId := model.UserId(getCurrentUserID(c))
+		answers, total, err := ctrl.service.ListAnswerDrafts(ctx, userId, req.Page, req.Size)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]response.AnswerResponse, 0, len(answers))
+		for i := range answers {
+			records = append(records, ctrl.newAnswerResponse(ctx, &answers[i]))
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "answer drafts listed",
+			Body: response.ListAnswerDraftsResponse{
+				Total:   total,
+				Page:    req.Page,
+				Size:    req.Size,
+				Records: records,
+			},
+		}, nil
+	})
+}
+
+// PublishAnswerDraft 立即或定时发布回答草稿
+func (ctrl *ArticleController) PublishAnswerDraft(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.PublishDraftRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		answer, err := ctrl.service.PublishAnswerDraft(ctx, userId, id, req)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		message := "answer published"
+		if answer.IsDraft {
+			message = "answer scheduled"
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: message,
+			Body:    ctrl.newAnswerResponse(ctx, answer),
+		}, nil
+	})
+}
+
+// CancelAnswerSchedule 取消回答草稿的定时发布
+func (ctrl *ArticleController) CancelAnswerSchedule(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		answer, err := ctrl.service.CancelAnswerSchedule(ctx, userId, id)
+		if err != nil {
+			return nil, err.(app_error.AppError)
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "answer schedule canceled",
+			Body:    ctrl.newAnswerResponse(ctx, answer),
+		}, nil
+	})
+}
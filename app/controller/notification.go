@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/config"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/request"
+	"my_zhihu_backend/app/response"
+	"my_zhihu_backend/app/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationController struct {
+	service *service.NotificationService
+	cfg     config.ReadConfigFunc
+}
+
+func NewNotificationController(service *service.NotificationService) *NotificationController {
+	return &NotificationController{service: service, cfg: config.C}
+}
+
+// ListNotifications 获取当前用户的通知列表
+func (ctrl *NotificationController) ListNotifications(c *gin.Context) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.ListNotificationsRequest) (*response.Response, app_error.AppError) {
+		userId := model.UserId(getCurrentUserID(c))
+		notifications, total, err := ctrl.service.ListNotifications(ctx, userId, req.UnreadOnly, req.Page, req.Size)
+		if err != nil {
+			return nil, err
+		}
+
+		records := make([]response.NotificationResponse, 0, len(notifications))
+		for _, n := range notifications {
+			records = append(records, response.NotificationResponse{
+				ID:         n.ID,
+				Type:       n.Type,
+				ActorId:    n.ActorId,
+				QuestionId: n.QuestionId,
+				AnswerId:   n.AnswerId,
+				CommentId:  n.CommentId,
+				IsRead:     n.IsRead,
+				CreatedAt:  n.CreatedAt.Format(time.DateTime),
+			})
+		}
+
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "notifications listed",
+			Body: response.ListNotificationsResponse{
+				Total:   total,
+				Page:    req.Page,
+				Size:    req.Size,
+				Records: records,
+			},
+		}, nil
+	})
+}
+
+// MarkRead 标记单条通知已读
+func (ctrl *NotificationController) MarkRead(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		if err := ctrl.service.MarkRead(ctx, userId, id); err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "notification read",
+		}, nil
+	})
+}
+
+// MarkAllRead 标记全部通知已读
+func (ctrl *NotificationController) MarkAllRead(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		if err := ctrl.service.MarkAllRead(ctx, userId); err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "notifications read",
+		}, nil
+	})
+}
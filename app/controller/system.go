@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/config"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/response"
+	"my_zhihu_backend/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SystemController struct {
+	service *service.SystemService
+	cfg     config.ReadConfigFunc
+}
+
+func NewSystemController(service *service.SystemService) *SystemController {
+	return &SystemController{service: service, cfg: config.C}
+}
+
+// CacheStatus 获取缓存状态
+func (ctrl *SystemController) CacheStatus(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		status, err := ctrl.service.CacheStatus(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "cache status",
+			Body:          status,
+		}, nil
+	})
+}
+
+// CacheStats 获取缓存的命中率等计数
+func (ctrl *SystemController) CacheStats(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		stats, err := ctrl.service.CacheStats(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "cache stats",
+			Body:          stats,
+		}, nil
+	})
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/config"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/request"
+	"my_zhihu_backend/app/response"
+	"my_zhihu_backend/app/service"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead multipart 请求中除文件内容以外的部分(边界、头部)允许的大小
+const multipartOverhead = 1 << 20
+
+type UploadController struct {
+	service *service.UploadService
+	cfg     config.ReadConfigFunc
+}
+
+func NewUploadController(service *service.UploadService) *UploadController {
+	return &UploadController{service: service, cfg: config.C}
+}
+
+func (ctrl *UploadController) newUploadResponse(upload *model.Upload) response.UploadResponse {
+	return response.UploadResponse{
+		ID:          upload.ID,
+		Filename:    upload.Filename,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+		Hash:        upload.Hash,
+		URL:         ctrl.service.URL(upload),
+		CreatedAt:   upload.CreatedAt.Format(time.DateTime),
+	}
+}
+
+// Upload 上传文件 使用 multipart/form-data 的 file 字段
+func (ctrl *UploadController) Upload(c *gin.Context) {
+	maxSize := ctrl.cfg().Service.UploadMaxSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			if _, ok := errors.AsType[*http.MaxBytesError](err); ok {
+				return nil, app_error.ErrFileTooLarge.WithError(err)
+			}
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		if header.Size > maxSize {
+			return nil, app_error.ErrFileTooLarge
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		defer file.Close()
+
+		upload, appErr := ctrl.service.Upload(ctx, userId, header.Filename, file)
+		if appErr != nil {
+			return nil, appErr
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "file uploaded",
+			Body:    ctrl.newUploadResponse(upload),
+		}, nil
+	})
+}
+
+// Download 通过带签名的链接下载文件 不需要登录
+func (ctrl *UploadController) Download(c *gin.Context) {
+	timeout, cancel := context.WithTimeout(c.Request.Context(), ctrl.cfg().Service.Timeout)
+	defer cancel()
+	id, err := getIdFromParams(c)
+	if err != nil {
+		_ = c.Error(ErrInvalidParameters.WithError(err))
+		return
+	}
+	var req request.DownloadUploadRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(app_error.ErrInvalidSignature.WithError(err))
+		return
+	}
+
+	upload, rc, appErr := ctrl.service.Open(timeout, id, req.Expires, req.Sig)
+	if appErr != nil {
+		_ = c.Error(appErr)
+		return
+	}
+	defer rc.Close()
+
+	// 只有图片允许在浏览器中直接打开 其他文件一律作为附件下载
+	disposition := "attachment"
+	if strings.HasPrefix(upload.ContentType, "image/") {
+		disposition = "inline"
+	}
+	cacheControl := "public, max-age=31536000, immutable"
+	if req.Expires != 0 {
+		cacheControl = fmt.Sprintf("private, max-age=%d", max(req.Expires-time.Now().Unix(), 0))
+	}
+	c.DataFromReader(http.StatusOK, upload.Size, upload.ContentType, rc, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": upload.Filename}),
+		"Cache-Control":          cacheControl,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// GetURL 上传者重新获取下载链接
+func (ctrl *UploadController) GetURL(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		upload, appErr := ctrl.service.RefreshURL(ctx, userId, id)
+		if appErr != nil {
+			return nil, appErr
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "upload url refreshed",
+			Body:    ctrl.newUploadResponse(upload),
+		}, nil
+	})
+}
+
+// DeleteUpload 删除自己的上传
+func (ctrl *UploadController) DeleteUpload(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		if err := ctrl.service.DeleteUpload(ctx, userId, id); err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Code:    0,
+			Ok:      true,
+			Message: "upload deleted",
+		}, nil
+	})
+}
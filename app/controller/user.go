@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"my_zhihu_backend/app/app_error"
 	"my_zhihu_backend/app/config"
 	"my_zhihu_backend/app/model"
@@ -32,17 +33,7 @@ func (ctrl *UserController) CreateNewUser(c *gin.Context) {
 				InternalError: false,
 				Code:          0,
 				Message:       "user created",
-				Body: response.UserResponse{
-					Id:       user.Id,
-					Username: user.Username,
-					Email:    user.Email,
-					Gender:   *user.Gender,
-					Region:   user.Region,
-					Other: response.UserOtherInfoResponse{
-						Introduction: user.Other.Introduction,
-						Icon:         user.Other.Icon,
-					},
-				},
+				Body:          service.NewUserResponse(user, service.SelfRelation),
 			}, nil
 		}
 	})
@@ -80,24 +71,11 @@ func (ctrl *UserController) GetUser(c *gin.Context) {
 			return nil, err.(app_error.AppError)
 		}
 
-		resp := response.UserResponse{
-			Id:       user.Id,
-			Username: user.Username,
-			Email:    user.Email,
-			Gender:   *user.Gender,
-			Region:   user.Region,
-			Other: response.UserOtherInfoResponse{
-				Introduction: user.Other.Introduction,
-				Icon:         user.Other.Icon,
-			},
-		}
-
-		if user.Settings.HidePrivacy && user.Id != model.UserId(getCurrentUserID(c)) {
-			resp.Email = "nothing here"
-			resp.Gender = model.UserGenderSecret
-			resp.Region = "nothing here"
+		rel, appErr := ctrl.service.Relation(ctx, model.UserId(getCurrentUserID(c)), user.Id)
+		if appErr != nil {
+			return nil, appErr
 		}
-
+		resp := service.NewUserResponse(user, rel)
 		return &response.Response{
 			Ok:            true,
 			InternalError: false,
@@ -108,6 +86,35 @@ func (ctrl *UserController) GetUser(c *gin.Context) {
 	})
 }
 
+// BatchGetUsers 批量获取用户信息
+func (ctrl *UserController) BatchGetUsers(c *gin.Context) {
+	doWithBody(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.BatchGetUsersRequest) (*response.Response, app_error.AppError) {
+		users, err := ctrl.service.BatchGetUsers(ctx, req.Ids)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]model.UserId, 0, len(users))
+		for _, user := range users {
+			ids = append(ids, user.Id)
+		}
+		relations, err := ctrl.service.Relations(ctx, model.UserId(getCurrentUserID(c)), ids)
+		if err != nil {
+			return nil, err
+		}
+		resp := make([]response.UserResponse, 0, len(users))
+		for _, user := range users {
+			resp = append(resp, service.NewUserResponse(user, relations[user.Id]))
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "users retrieved",
+			Body:          resp,
+		}, nil
+	})
+}
+
 // UpdateUser 更新用户信息
 func (ctrl *UserController) UpdateUser(c *gin.Context) {
 	doWithBody(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.UpdateUserRequest) (*response.Response, app_error.AppError) {
@@ -121,26 +128,51 @@ func (ctrl *UserController) UpdateUser(c *gin.Context) {
 				InternalError: false,
 				Code:          0,
 				Message:       "user updated",
-				Body: response.UserResponse{
-					Id:       user.Id,
-					Username: user.Username,
-					Email:    user.Email,
-					Gender:   *user.Gender,
-					Region:   user.Region,
-					Other: response.UserOtherInfoResponse{
-						Introduction: user.Other.Introduction,
-						Icon:         user.Other.Icon,
-					},
-				},
+				Body:          service.NewUserResponse(user, service.SelfRelation),
 			}, nil
 		}
 	})
 }
 
+// UpdateAvatar 上传头像 使用 multipart/form-data 的 file 字段
+func (ctrl *UserController) UpdateAvatar(c *gin.Context) {
+	maxSize := ctrl.cfg().Service.UploadMaxSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			if _, ok := errors.AsType[*http.MaxBytesError](err); ok {
+				return nil, app_error.ErrFileTooLarge.WithError(err)
+			}
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		if header.Size > maxSize {
+			return nil, app_error.ErrFileTooLarge
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, ErrInvalidParameters.WithError(err)
+		}
+		defer file.Close()
+
+		user, appErr := ctrl.service.UpdateAvatar(ctx, int64(userId), file)
+		if appErr != nil {
+			return nil, appErr
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "avatar updated",
+			Body:          service.NewUserResponse(user, service.SelfRelation),
+		}, nil
+	})
+}
+
 // SearchUserByUsername 根据用户名搜索用户
 func (ctrl *UserController) SearchUserByUsername(c *gin.Context) {
 	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.SearchUserRequest) (*response.Response, app_error.AppError) {
-		userIDs, err := ctrl.service.SearchUserByUsername(ctx, req.Username)
+		users, err := ctrl.service.SearchUsers(ctx, req.Username, req.Page, req.Size)
 		if err != nil {
 			return nil, err
 		}
@@ -150,7 +182,24 @@ func (ctrl *UserController) SearchUserByUsername(c *gin.Context) {
 			InternalError: false,
 			Code:          0,
 			Message:       "users found",
-			Body:          userIDs,
+			Body:          users,
+		}, nil
+	})
+}
+
+// AutocompleteUsername 用户名前缀补全
+func (ctrl *UserController) AutocompleteUsername(c *gin.Context) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.AutocompleteUserRequest) (*response.Response, app_error.AppError) {
+		users, err := ctrl.service.AutocompleteUsername(ctx, req.Prefix, req.Size)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "users found",
+			Body:          users,
 		}, nil
 	})
 }
@@ -203,74 +252,193 @@ func (ctrl *UserController) RemoveFollowing(c *gin.Context) {
 	})
 }
 
-// GetFollowers 获取粉丝列表
-func (ctrl *UserController) GetFollowers(c *gin.Context) {
-	timeout, cancel := context.WithTimeout(c.Request.Context(), ctrl.cfg().Service.Timeout)
-	defer cancel()
+// AddBlock 拉黑用户
+func (ctrl *UserController) AddBlock(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		blockedID, err := getIdFromParams(c)
+		if err != nil {
+			return nil, app_error.NewInputError("invalid user id", app_error.ErrCodeInvalidParameters, err)
+		}
+		if err := ctrl.service.BlockUser(ctx, int64(userId), blockedID); err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "user blocked",
+		}, nil
+	})
+}
+
+// RemoveBlock 取消拉黑
+func (ctrl *UserController) RemoveBlock(c *gin.Context) {
+	doOnlyWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId) (*response.Response, app_error.AppError) {
+		blockedID, err := getIdFromParams(c)
+		if err != nil {
+			return nil, app_error.NewInputError("invalid user id", app_error.ErrCodeInvalidParameters, err)
+		}
+		if err := ctrl.service.UnblockUser(ctx, int64(userId), blockedID); err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "user unblocked",
+		}, nil
+	})
+}
+
+// getFollowsVisibleId 读取路径中的用户 id 并按隐私设置校验当前用户能否查看其粉丝或关注列表
+func (ctrl *UserController) getFollowsVisibleId(ctx context.Context, c *gin.Context, field model.PrivacyField) (model.UserId, app_error.AppError) {
 	id, err := getIdFromParams(c)
 	if err != nil {
-		_ = c.Error(app_error.NewInputError("invalid parameters", app_error.ErrCodeInvalidParameters, err))
-		return
+		return 0, app_error.NewInputError("invalid parameters", app_error.ErrCodeInvalidParameters, err)
 	}
-	currentUserID := getCurrentUserID(c)
+	if err := ctrl.service.CheckVisible(ctx, model.UserId(getCurrentUserID(c)), model.UserId(id), field); err != nil {
+		return 0, err
+	}
+	return model.UserId(id), nil
+}
 
-	if currentUserID != id {
-		user, err := ctrl.service.GetUser(timeout, id)
+// listFollows 校验路径中的用户 id 和隐私设置后获取粉丝或关注列表
+func (ctrl *UserController) listFollows(c *gin.Context, message string, field model.PrivacyField, list func(ctx context.Context, viewerId, id model.UserId, req *request.ListFollowsRequest) (*response.ListFollowsResponse, app_error.AppError)) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.ListFollowsRequest) (*response.Response, app_error.AppError) {
+		id, err := ctrl.getFollowsVisibleId(ctx, c, field)
 		if err != nil {
-			_ = c.Error(err)
-			return
+			return nil, err
 		}
-		if user.Settings.HidePrivacy {
-			_ = c.Error(app_error.ErrUserPermissionDenied)
-			return
+		resp, err := list(ctx, model.UserId(getCurrentUserID(c)), id, req)
+		if err != nil {
+			return nil, err
 		}
-	}
-	followers, err := ctrl.service.GetFollowers(timeout, model.UserId(id))
-	if err != nil {
-		_ = c.Error(err)
-		return
-	}
-	c.JSON(http.StatusOK, &response.Response{
-		Ok:            true,
-		InternalError: false,
-		Code:          0,
-		Message:       "user followers",
-		Body:          followers,
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       message,
+			Body:          resp,
+		}, nil
 	})
 }
 
+// GetFollowers 获取粉丝列表
+func (ctrl *UserController) GetFollowers(c *gin.Context) {
+	ctrl.listFollows(c, "user followers", model.PrivacyFollowers, ctrl.service.ListFollowers)
+}
+
 // GetFollowings 获取关注列表
 func (ctrl *UserController) GetFollowings(c *gin.Context) {
-	timeout, cancel := context.WithTimeout(c.Request.Context(), ctrl.cfg().Service.Timeout)
-	defer cancel()
-	id, err := getIdFromParams(c)
-	if err != nil {
-		_ = c.Error(app_error.NewInputError("invalid parameters", app_error.ErrCodeInvalidParameters, err))
-		return
-	}
-	currentUserID := getCurrentUserID(c)
+	ctrl.listFollows(c, "user followings", model.PrivacyFollowings, ctrl.service.ListFollowings)
+}
 
-	if currentUserID != id {
-		user, err := ctrl.service.GetUser(timeout, id)
+// GetActivities 获取用户动态
+func (ctrl *UserController) GetActivities(c *gin.Context) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.ListActivitiesRequest) (*response.Response, app_error.AppError) {
+		id, err := getIdFromParams(c)
 		if err != nil {
-			_ = c.Error(err)
-			return
+			return nil, app_error.NewInputError("invalid parameters", app_error.ErrCodeInvalidParameters, err)
 		}
-		if user.Settings.HidePrivacy {
-			_ = c.Error(app_error.ErrUserPermissionDenied)
-			return
+		resp, appErr := ctrl.service.ListActivities(ctx, model.UserId(getCurrentUserID(c)), model.UserId(id), req)
+		if appErr != nil {
+			return nil, appErr
 		}
-	}
-	followings, err := ctrl.service.GetFollowings(timeout, model.UserId(id))
-	if err != nil {
-		_ = c.Error(err)
-		return
-	}
-	c.JSON(http.StatusOK, &response.Response{
-		Ok:            true,
-		InternalError: false,
-		Code:          0,
-		Message:       "user followings",
-		Body:          followings,
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "user activities",
+			Body:          resp,
+		}, nil
+	})
+}
+
+// listSample 校验路径中的用户 id 和隐私设置后获取共同粉丝等用户列表
+func (ctrl *UserController) listSample(c *gin.Context, message string, field model.PrivacyField, list func(ctx context.Context, viewerId, id model.UserId, size int) (*response.UserSampleResponse, app_error.AppError)) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.ListRelatedUsersRequest) (*response.Response, app_error.AppError) {
+		id, err := ctrl.getFollowsVisibleId(ctx, c, field)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := list(ctx, model.UserId(getCurrentUserID(c)), id, req.Size)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       message,
+			Body:          resp,
+		}, nil
+	})
+}
+
+// GetMutualFollowers 获取同时关注了我和该用户的用户
+func (ctrl *UserController) GetMutualFollowers(c *gin.Context) {
+	ctrl.listSample(c, "mutual followers", model.PrivacyFollowers, ctrl.service.ListMutualFollowers)
+}
+
+// GetFollowedBy 获取我关注的人中关注了该用户的用户
+func (ctrl *UserController) GetFollowedBy(c *gin.Context) {
+	ctrl.listSample(c, "followed by followings", model.PrivacyFollowers, ctrl.service.ListFollowedBy)
+}
+
+// GetRecommendations 获取可能认识的人
+func (ctrl *UserController) GetRecommendations(c *gin.Context) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *request.ListRelatedUsersRequest) (*response.Response, app_error.AppError) {
+		users, err := ctrl.service.RecommendFollows(ctx, model.UserId(getCurrentUserID(c)), req.Size)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "recommended users",
+			Body:          users,
+		}, nil
+	})
+}
+
+// UpdateHandle 设置或修改当前用户的 handle
+func (ctrl *UserController) UpdateHandle(c *gin.Context) {
+	doWithUserId(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, userId model.UserId, req *request.UpdateHandleRequest) (*response.Response, app_error.AppError) {
+		user, err := ctrl.service.ChangeHandle(ctx, int64(userId), req.Handle)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "handle updated",
+			Body:          service.NewUserResponse(user, service.SelfRelation),
+		}, nil
+	})
+}
+
+// GetUserByHandle 通过 handle 获取用户信息
+func (ctrl *UserController) GetUserByHandle(c *gin.Context) {
+	doWithQuery(c, ctrl.cfg().Service.Timeout, func(ctx context.Context, req *struct{}) (*response.Response, app_error.AppError) {
+		user, moved, err := ctrl.service.GetUserByHandle(ctx, c.Param("handle"))
+		if err != nil {
+			return nil, err
+		}
+		rel, err := ctrl.service.Relation(ctx, model.UserId(getCurrentUserID(c)), user.Id)
+		if err != nil {
+			return nil, err
+		}
+		return &response.Response{
+			Ok:            true,
+			InternalError: false,
+			Code:          0,
+			Message:       "user retrieved",
+			Body: &response.HandleLookupResponse{
+				User:  service.NewUserResponse(user, rel),
+				Moved: moved,
+			},
+		}, nil
 	})
 }
@@ -0,0 +1,79 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ActivityDAO struct {
+	db *gorm.DB
+}
+
+func NewActivityDAO(db *gorm.DB) *ActivityDAO {
+	return &ActivityDAO{db: db}
+}
+
+// CreateActivity 记录动态 相同的动态已存在时忽略
+func (dao *ActivityDAO) CreateActivity(ctx context.Context, activity *model.Activity) app_error.AppError {
+	err := gorm.G[model.Activity](dao.db, clause.OnConflict{DoNothing: true}).Create(ctx, activity)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// DeleteActivities 删除与 activity 中非零字段全部匹配的动态
+func (dao *ActivityDAO) DeleteActivities(ctx context.Context, activity model.Activity) app_error.AppError {
+	query := gorm.G[model.Activity](dao.db).Where("1 = 1")
+	if activity.UserId != 0 {
+		query = query.Where("user_id = ?", activity.UserId)
+	}
+	if activity.Type != 0 {
+		query = query.Where("type = ?", activity.Type)
+	}
+	if activity.QuestionId != 0 {
+		query = query.Where("question_id = ?", activity.QuestionId)
+	}
+	if activity.AnswerId != 0 {
+		query = query.Where("answer_id = ?", activity.AnswerId)
+	}
+	if activity.TargetUserId != 0 {
+		query = query.Where("target_user_id = ?", activity.TargetUserId)
+	}
+	if _, err := query.Delete(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// ListActivities 按时间倒序分页获取用户的动态 types 为空时不过滤类型
+// beforeAt 为 nil 时从最新开始 否则返回 (beforeAt, beforeId) 之后的记录
+func (dao *ActivityDAO) ListActivities(ctx context.Context, userId model.UserId, types []model.ActivityType, beforeAt *time.Time, beforeId int64, size int) ([]model.Activity, app_error.AppError) {
+	query := gorm.G[model.Activity](dao.db).Where("user_id = ?", userId)
+	if len(types) > 0 {
+		query = query.Where("type IN ?", types)
+	}
+	if beforeAt != nil {
+		query = query.Where("created_at < ? or (created_at = ? and id < ?)", *beforeAt, *beforeAt, beforeId)
+	}
+	activities, err := query.Order("created_at DESC, id DESC").Limit(size).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return activities, nil
+}
@@ -6,8 +6,10 @@ import (
 	"my_zhihu_backend/app/app_error"
 	"my_zhihu_backend/app/model"
 	"my_zhihu_backend/app/response"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ArticleDAO struct {
@@ -18,15 +20,24 @@ func NewArticleDAO(db *gorm.DB) *ArticleDAO {
 	return &ArticleDAO{db: db}
 }
 
+// PostNewQuestion 创建问题 同时写入第一条修订记录
 func (a *ArticleDAO) PostNewQuestion(ctx context.Context, question *model.Question) app_error.AppError {
-	err := gorm.G[model.Question](a.db).Create(ctx, question)
-	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			return app_error.ErrTimeout.WithError(err)
+	return transaction(ctx, a.db, func(tx *gorm.DB) app_error.AppError {
+		err := gorm.G[model.Question](tx).Create(ctx, question)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
 		}
-		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
-	}
-	return nil
+		return a.appendRevision(ctx, tx, &model.Revision{
+			TargetType: model.RevisionTargetQuestion,
+			TargetId:   question.ID,
+			EditorId:   model.UserId(question.AuthorId),
+			Title:      question.Title,
+			Content:    question.Content,
+		})
+	})
 }
 
 func (a *ArticleDAO) DeleteQuestion(ctx context.Context, userId int64, questionId int64) app_error.AppError {
@@ -57,16 +68,41 @@ func (a *ArticleDAO) GetQuestion(ctx context.Context, questionId int64) (*model.
 	return &question, nil
 }
 
-func (a *ArticleDAO) UpdateQuestion(ctx context.Context, userId int64, questionId int64, updateData map[string]interface{}) (*model.Question, app_error.AppError) {
-	res := a.db.WithContext(ctx).Model(&model.Question{}).Where("id = ? and author_id = ?", questionId, userId).Select("title", "body").Updates(updateData)
-	if res.Error != nil {
-		if errors.Is(res.Error, context.DeadlineExceeded) {
-			return nil, app_error.ErrTimeout.WithError(res.Error)
+// UpdateQuestion 更新问题标题和内容 每次编辑都会追加一条修订记录 权限由调用方检查
+func (a *ArticleDAO) UpdateQuestion(ctx context.Context, editorId model.UserId, questionId int64, title, content string) (*model.Question, app_error.AppError) {
+	err := transaction(ctx, a.db, func(tx *gorm.DB) app_error.AppError {
+		question, err := gorm.G[model.Question](tx).Where("id = ?", questionId).First(ctx)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_error.ErrQuestionNotFound
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
 		}
-		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
-	}
-	if res.RowsAffected == 0 {
-		return nil, app_error.ErrUserPermissionDenied
+		// 历史数据没有修订记录 先把编辑前的内容保存为第一个版本
+		if err := a.ensureFirstRevision(ctx, tx, model.RevisionTargetQuestion, question.ID, model.UserId(question.AuthorId), question.Title, question.Content); err != nil {
+			return err
+		}
+
+		res := tx.WithContext(ctx).Model(&model.Question{}).Where("id = ?", questionId).Select("title", "content").Updates(map[string]interface{}{"title": title, "content": content})
+		if res.Error != nil {
+			if errors.Is(res.Error, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(res.Error)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
+		}
+		return a.appendRevision(ctx, tx, &model.Revision{
+			TargetType: model.RevisionTargetQuestion,
+			TargetId:   questionId,
+			EditorId:   editorId,
+			Title:      title,
+			Content:    content,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 	return a.GetQuestion(ctx, questionId)
 }
@@ -77,11 +113,11 @@ func (a *ArticleDAO) ListQuestions(ctx context.Context, page, size int, keywords
 	rawSql := `
 	select id, left(title, 50) as part, 'question' as type, Match(title, content) Against(? IN NATURAL LANGUAGE MODE) as score
 	from questions
-	where Match(title, content) Against(? IN NATURAL LANGUAGE MODE) and is_available = ?
+	where Match(title, content) Against(? IN NATURAL LANGUAGE MODE) and is_available = ? and is_draft = false
 	union all
 	select id, left(content, 50) as part, 'answer' as type, Match(content) Against(? IN NATURAL LANGUAGE MODE) as score
     from answers
-	where Match(content) Against(? IN NATURAL LANGUAGE MODE) and is_available = ?
+	where Match(content) Against(? IN NATURAL LANGUAGE MODE) and is_available = ? and is_draft = false
 	order by score desc 
 	limit ? offset ?
 `
@@ -95,8 +131,9 @@ func (a *ArticleDAO) ListQuestions(ctx context.Context, page, size int, keywords
 	return results, nil
 }
 
-func (a *ArticleDAO) PostNewAnswer(ctx context.Context, answer *model.Answer) app_error.AppError {
-	err := gorm.G[model.Answer](a.db).Create(ctx, answer)
+// IncrQuestionViews 问题的浏览次数增加 n
+func (a *ArticleDAO) IncrQuestionViews(ctx context.Context, questionId int64, n int64) app_error.AppError {
+	_, err := gorm.G[model.Question](a.db).Where("id = ?", questionId).Update(ctx, "view_count", gorm.Expr("view_count + ?", n))
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return app_error.ErrTimeout.WithError(err)
@@ -106,32 +143,113 @@ func (a *ArticleDAO) PostNewAnswer(ctx context.Context, answer *model.Answer) ap
 	return nil
 }
 
-func (a *ArticleDAO) UpdateAnswer(ctx context.Context, userId int64, answerId int64, newContent string) app_error.AppError {
-	rowsAffected, err := gorm.G[model.Answer](a.db).Where("id = ? and author_id = ?", answerId, userId).Update(ctx, "content", newContent)
+// ListQuestionIds 按 id 顺序分批读取已发布问题的 id 用于重建布隆过滤器
+func (a *ArticleDAO) ListQuestionIds(ctx context.Context, afterId int64, size int) ([]int64, app_error.AppError) {
+	var ids []int64
+	err := a.db.WithContext(ctx).Model(&model.Question{}).Where("id > ? and is_draft = false", afterId).
+		Order("id").Limit(size).Pluck("id", &ids).Error
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
-			return app_error.ErrTimeout.WithError(err)
+			return nil, app_error.ErrTimeout.WithError(err)
 		}
-		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
-	}
-	if rowsAffected == 0 {
-		return app_error.ErrUserPermissionDenied
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
 	}
-	return nil
+	return ids, nil
 }
 
-func (a *ArticleDAO) DeleteAnswer(ctx context.Context, userId int64, answerId int64) app_error.AppError {
-	rowsAffected, err := gorm.G[model.Answer](a.db).Where("id = ? and author_id = ?", answerId, userId).Delete(ctx)
+// ListMostViewedQuestions 读取浏览次数最多的 size 个已发布问题 用于预热缓存
+func (a *ArticleDAO) ListMostViewedQuestions(ctx context.Context, size int) ([]model.Question, app_error.AppError) {
+	questions, err := gorm.G[model.Question](a.db).Where("is_draft = false").Order("view_count DESC, id").Limit(size).Find(ctx)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
-			return app_error.ErrTimeout.WithError(err)
+			return nil, app_error.ErrTimeout.WithError(err)
 		}
-		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
 	}
-	if rowsAffected == 0 {
-		return app_error.ErrUserPermissionDenied
+	return questions, nil
+}
+
+// PostNewAnswer 创建回答 同时写入第一条修订记录
+func (a *ArticleDAO) PostNewAnswer(ctx context.Context, answer *model.Answer) app_error.AppError {
+	return transaction(ctx, a.db, func(tx *gorm.DB) app_error.AppError {
+		err := gorm.G[model.Answer](tx).Create(ctx, answer)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		return a.appendRevision(ctx, tx, &model.Revision{
+			TargetType: model.RevisionTargetAnswer,
+			TargetId:   answer.ID,
+			EditorId:   model.UserId(answer.AuthorId),
+			Content:    answer.Content,
+		})
+	})
+}
+
+// UpdateAnswer 更新回答内容 每次编辑都会追加一条修订记录 权限由调用方检查
+func (a *ArticleDAO) UpdateAnswer(ctx context.Context, editorId model.UserId, answerId int64, newContent string) (*model.Answer, app_error.AppError) {
+	err := transaction(ctx, a.db, func(tx *gorm.DB) app_error.AppError {
+		answer, err := gorm.G[model.Answer](tx).Where("id = ?", answerId).First(ctx)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_error.ErrAnswerNotFound
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		if err := a.ensureFirstRevision(ctx, tx, model.RevisionTargetAnswer, answer.ID, model.UserId(answer.AuthorId), "", answer.Content); err != nil {
+			return err
+		}
+
+		_, err = gorm.G[model.Answer](tx).Where("id = ?", answerId).Update(ctx, "content", newContent)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		return a.appendRevision(ctx, tx, &model.Revision{
+			TargetType: model.RevisionTargetAnswer,
+			TargetId:   answerId,
+			EditorId:   editorId,
+			Content:    newContent,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return a.GetAnswer(ctx, answerId)
+}
+
+// DeleteAnswer 删除回答 回答被采纳时在同一事务中取消采纳 返回是否取消了采纳
+func (a *ArticleDAO) DeleteAnswer(ctx context.Context, userId int64, answerId int64) (unaccepted bool, appErr app_error.AppError) {
+	appErr = transaction(ctx, a.db, func(tx *gorm.DB) app_error.AppError {
+		rowsAffected, err := gorm.G[model.Answer](tx).Where("id = ? and author_id = ?", answerId, userId).Delete(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		if rowsAffected == 0 {
+			return app_error.ErrUserPermissionDenied
+		}
+		res := tx.Model(&model.Question{}).Where("accepted_answer_id = ?", answerId).
+			Select("accepted_answer_id").Updates(map[string]interface{}{"accepted_answer_id": nil})
+		if res.Error != nil {
+			if errors.Is(res.Error, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(res.Error)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
+		}
+		unaccepted = res.RowsAffected > 0
+		return nil
+	})
+	return unaccepted, appErr
 }
 
 func (a *ArticleDAO) GetAnswer(ctx context.Context, answerId int64) (*model.Answer, app_error.AppError) {
@@ -152,7 +270,7 @@ func (a *ArticleDAO) ListAnswers(ctx context.Context, questionId int64, page, si
 	var answers []model.Answer
 	var total int64
 
-	query := gorm.G[model.Answer](a.db).Where("question_id = ? AND is_available = ?", questionId, true)
+	query := gorm.G[model.Answer](a.db).Where("question_id = ? AND is_available = ? AND is_draft = ?", questionId, true, false)
 
 	total, err := query.Count(ctx, "id")
 	if err != nil {
@@ -237,3 +355,216 @@ func (a *ArticleDAO) ListComments(ctx context.Context, answerId int64, page, siz
 
 	return comments, total, nil
 }
+
+// WatchQuestion 关注问题 重复关注不报错
+func (a *ArticleDAO) WatchQuestion(ctx context.Context, userId model.UserId, questionId int64) app_error.AppError {
+	err := gorm.G[model.QuestionWatcher](a.db).Create(ctx, &model.QuestionWatcher{QuestionId: questionId, UserId: userId})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) { // 保证幂等性
+			return nil
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// UnwatchQuestion 取消关注问题 未关注时不报错
+func (a *ArticleDAO) UnwatchQuestion(ctx context.Context, userId model.UserId, questionId int64) app_error.AppError {
+	_, err := gorm.G[model.QuestionWatcher](a.db).Where("question_id = ? and user_id = ?", questionId, userId).Delete(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// ListQuestionWatchers 获取关注问题的用户列表
+func (a *ArticleDAO) ListQuestionWatchers(ctx context.Context, questionId int64) ([]model.UserId, app_error.AppError) {
+	watchers, err := gorm.G[model.QuestionWatcher](a.db).Select("user_id").Where("question_id = ?", questionId).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+
+	results := make([]model.UserId, 0, len(watchers))
+	for _, watcher := range watchers {
+		results = append(results, watcher.UserId)
+	}
+	return results, nil
+}
+
+// CreateInvitation 邀请用户回答问题 邀请者从 since 开始发出的邀请达到 limit 时返回 app_error.ErrInvitationLimitExceeded
+// 在事务中锁住邀请者的用户行后统计和写入 同一用户并发的邀请依次执行 不会超过限制
+// 同一问题重复邀请同一用户返回 app_error.ErrInvalidInvitee
+func (a *ArticleDAO) CreateInvitation(ctx context.Context, invitation *model.QuestionInvitation, since time.Time, limit int) app_error.AppError {
+	return transaction(ctx, a.db, func(tx *gorm.DB) app_error.AppError {
+		var inviter model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", invitation.InviterId).Take(&inviter).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_error.ErrUserNotExists
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		count, err := gorm.G[model.QuestionInvitation](tx).Where("inviter_id = ? and created_at >= ?", invitation.InviterId, since).Count(ctx, "id")
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		if count >= int64(limit) {
+			return app_error.ErrInvitationLimitExceeded
+		}
+		if err := gorm.G[model.QuestionInvitation](tx).Create(ctx, invitation); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return app_error.ErrInvalidInvitee.WithError(err)
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		return nil
+	})
+}
+
+// ListInviteeSuggestions 推荐邀请回答的用户
+// 候选人为当前用户和提问者的粉丝 排除已被邀请或已回答过该问题的用户 按照与两者的关注关系数量和粉丝数排序
+func (a *ArticleDAO) ListInviteeSuggestions(ctx context.Context, question *model.Question, userId model.UserId, limit int) ([]model.UserId, app_error.AppError) {
+	var results []model.UserId
+
+	rawSql := `
+	select uf.follower_id
+	from user_followers uf join users u on u.id = uf.follower_id and u.deleted_at is null
+	where uf.following_id in (?, ?) and uf.follower_id not in (?, ?)
+		and not exists (select 1 from question_invitations qi where qi.question_id = ? and qi.invitee_id = uf.follower_id)
+		and not exists (select 1 from answers an where an.question_id = ? and an.author_id = uf.follower_id and an.is_draft = false and an.deleted_at is null)
+	group by uf.follower_id, u.follower_count
+	order by count(*) desc, u.follower_count desc
+	limit ?
+`
+
+	err := a.db.WithContext(ctx).Raw(rawSql, userId, question.AuthorId, userId, question.AuthorId, question.ID, question.ID, limit).Scan(&results).Error
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return results, nil
+}
+
+// UpdateQuestionStatus 更新问题状态 只有当前状态仍为 from 时才会更新 防止并发修改覆盖
+func (a *ArticleDAO) UpdateQuestionStatus(ctx context.Context, questionId int64, from, to model.QuestionStatus, duplicateOfId *int64) (*model.Question, app_error.AppError) {
+	res := a.db.WithContext(ctx).Model(&model.Question{}).Where("id = ? and status = ?", questionId, from).
+		Select("status", "duplicate_of_id").Updates(map[string]interface{}{"status": to, "duplicate_of_id": duplicateOfId})
+	if res.Error != nil {
+		if errors.Is(res.Error, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(res.Error)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, app_error.ErrInvalidStatusTransition
+	}
+	return a.GetQuestion(ctx, questionId)
+}
+
+// SetAcceptedAnswer 设置问题的采纳回答 answerId 为 nil 时取消采纳
+func (a *ArticleDAO) SetAcceptedAnswer(ctx context.Context, userId int64, questionId int64, answerId *int64) (*model.Question, app_error.AppError) {
+	res := a.db.WithContext(ctx).Model(&model.Question{}).Where("id = ? and author_id = ?", questionId, userId).
+		Select("accepted_answer_id").Updates(map[string]interface{}{"accepted_answer_id": answerId})
+	if res.Error != nil {
+		if errors.Is(res.Error, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(res.Error)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
+	}
+	return a.GetQuestion(ctx, questionId)
+}
+
+// appendRevision 在事务中追加修订记录 版本号为当前最大版本号加一
+func (a *ArticleDAO) appendRevision(ctx context.Context, tx *gorm.DB, revision *model.Revision) app_error.AppError {
+	var latest int
+	err := tx.WithContext(ctx).Model(&model.Revision{}).
+		Where("target_type = ? and target_id = ?", revision.TargetType, revision.TargetId).
+		Select("coalesce(max(version), 0)").Scan(&latest).Error
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	revision.Version = latest + 1
+	if err := gorm.G[model.Revision](tx).Create(ctx, revision); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// ensureFirstRevision 目标没有任何修订记录时把当前内容写入为第一个版本
+func (a *ArticleDAO) ensureFirstRevision(ctx context.Context, tx *gorm.DB, target model.RevisionTarget, targetId int64, authorId model.UserId, title, content string) app_error.AppError {
+	count, err := gorm.G[model.Revision](tx).Where("target_type = ? and target_id = ?", target, targetId).Count(ctx, "id")
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	if count > 0 {
+		return nil
+	}
+	return a.appendRevision(ctx, tx, &model.Revision{
+		TargetType: target,
+		TargetId:   targetId,
+		EditorId:   authorId,
+		Title:      title,
+		Content:    content,
+	})
+}
+
+// ListRevisions 分页获取修订记录 按版本号倒序
+func (a *ArticleDAO) ListRevisions(ctx context.Context, target model.RevisionTarget, targetId int64, page, size int) ([]model.Revision, int64, app_error.AppError) {
+	query := gorm.G[model.Revision](a.db).Where("target_type = ? and target_id = ?", target, targetId)
+
+	total, err := query.Count(ctx, "id")
+	if err != nil {
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+
+	revisions, err := query.Offset((page - 1) * size).Limit(size).Order("version DESC").Find(ctx)
+	if err != nil {
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+
+	return revisions, total, nil
+}
+
+// GetRevision 获取指定版本的修订记录
+func (a *ArticleDAO) GetRevision(ctx context.Context, target model.RevisionTarget, targetId int64, version int) (*model.Revision, app_error.AppError) {
+	revision, err := gorm.G[model.Revision](a.db).Where("target_type = ? and target_id = ? and version = ?", target, targetId, version).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_error.ErrRevisionNotFound
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return &revision, nil
+}
@@ -0,0 +1,202 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// draftable 问题和回答共用 is_draft version scheduled_at author_id 字段 草稿相关查询使用泛型复用
+type draftable interface {
+	model.Question | model.Answer
+}
+
+func createDraft[T draftable](ctx context.Context, db *gorm.DB, draft *T) app_error.AppError {
+	if err := gorm.G[T](db).Create(ctx, draft); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+func listDrafts[T draftable](ctx context.Context, db *gorm.DB, authorId model.UserId, page, size int) ([]T, int64, app_error.AppError) {
+	query := gorm.G[T](db).Where("author_id = ? and is_draft = ?", authorId, true)
+
+	total, err := query.Count(ctx, "id")
+	if err != nil {
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+
+	drafts, err := query.Offset((page - 1) * size).Limit(size).Order("updated_at DESC").Find(ctx)
+	if err != nil {
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return drafts, total, nil
+}
+
+// autosaveDraft 只有版本号与客户端持有的版本一致时才会保存 保存后版本号加一
+func autosaveDraft[T draftable](ctx context.Context, db *gorm.DB, authorId model.UserId, id int64, version int, fields map[string]interface{}) app_error.AppError {
+	fields["version"] = gorm.Expr("version + ?", 1)
+	res := db.WithContext(ctx).Model(new(T)).Where("id = ? and author_id = ? and is_draft = ? and version = ?", id, authorId, true, version).Updates(fields)
+	if res.Error != nil {
+		if errors.Is(res.Error, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(res.Error)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return app_error.ErrDraftVersionConflict
+	}
+	return nil
+}
+
+// scheduleDraft 设置草稿的定时发布时间 at 为 nil 时取消定时发布
+func scheduleDraft[T draftable](ctx context.Context, db *gorm.DB, authorId model.UserId, id int64, at *time.Time) app_error.AppError {
+	res := db.WithContext(ctx).Model(new(T)).Where("id = ? and author_id = ? and is_draft = ?", id, authorId, true).
+		Select("scheduled_at").Updates(map[string]interface{}{"scheduled_at": at})
+	if res.Error != nil {
+		if errors.Is(res.Error, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(res.Error)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
+	}
+	return nil
+}
+
+// publishDraft 在事务中将草稿改为已发布 草稿已被其他请求或实例发布 或者 required 中的字段为空时返回 false
+// 调用方发布前已经检查过必填字段 这里的条件防止检查之后自动保存清空了内容
+func publishDraft[T draftable](ctx context.Context, tx *gorm.DB, id int64, required ...string) (bool, app_error.AppError) {
+	query := tx.WithContext(ctx).Model(new(T)).Where("id = ? and is_draft = ?", id, true)
+	for _, column := range required {
+		query = query.Where(column + " <> ''")
+	}
+	res := query.
+		Select("is_draft", "scheduled_at").Updates(map[string]interface{}{"is_draft": false, "scheduled_at": nil})
+	if res.Error != nil {
+		if errors.Is(res.Error, context.DeadlineExceeded) {
+			return false, app_error.ErrTimeout.WithError(res.Error)
+		}
+		return false, app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// listDueDrafts 获取到达定时发布时间的草稿
+func listDueDrafts[T draftable](ctx context.Context, db *gorm.DB, now time.Time, limit int) ([]T, app_error.AppError) {
+	drafts, err := gorm.G[T](db).Where("is_draft = ? and scheduled_at <= ?", true, now).Order("scheduled_at ASC").Limit(limit).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return drafts, nil
+}
+
+// CreateQuestionDraft 创建问题草稿 草稿不写入修订记录
+func (a *ArticleDAO) CreateQuestionDraft(ctx context.Context, question *model.Question) app_error.AppError {
+	return createDraft(ctx, a.db, question)
+}
+
+// CreateAnswerDraft 创建回答草稿
+func (a *ArticleDAO) CreateAnswerDraft(ctx context.Context, answer *model.Answer) app_error.AppError {
+	return createDraft(ctx, a.db, answer)
+}
+
+// ListQuestionDrafts 分页获取用户的问题草稿
+func (a *ArticleDAO) ListQuestionDrafts(ctx context.Context, authorId model.UserId, page, size int) ([]model.Question, int64, app_error.AppError) {
+	return listDrafts[model.Question](ctx, a.db, authorId, page, size)
+}
+
+// ListAnswerDrafts 分页获取用户的回答草稿
+func (a *ArticleDAO) ListAnswerDrafts(ctx context.Context, authorId model.UserId, page, size int) ([]model.Answer, int64, app_error.AppError) {
+	return listDrafts[model.Answer](ctx, a.db, authorId, page, size)
+}
+
+// AutosaveQuestionDraft 自动保存问题草稿
+func (a *ArticleDAO) AutosaveQuestionDraft(ctx context.Context, authorId model.UserId, questionId int64, version int, title, content string) (*model.Question, app_error.AppError) {
+	if err := autosaveDraft[model.Question](ctx, a.db, authorId, questionId, version, map[string]interface{}{"title": title, "content": content}); err != nil {
+		return nil, err
+	}
+	return a.GetQuestion(ctx, questionId)
+}
+
+// AutosaveAnswerDraft 自动保存回答草稿
+func (a *ArticleDAO) AutosaveAnswerDraft(ctx context.Context, authorId model.UserId, answerId int64, version int, content string) (*model.Answer, app_error.AppError) {
+	if err := autosaveDraft[model.Answer](ctx, a.db, authorId, answerId, version, map[string]interface{}{"content": content}); err != nil {
+		return nil, err
+	}
+	return a.GetAnswer(ctx, answerId)
+}
+
+// ScheduleQuestion 设置问题草稿的定时发布时间
+func (a *ArticleDAO) ScheduleQuestion(ctx context.Context, authorId model.UserId, questionId int64, at *time.Time) (*model.Question, app_error.AppError) {
+	if err := scheduleDraft[model.Question](ctx, a.db, authorId, questionId, at); err != nil {
+		return nil, err
+	}
+	return a.GetQuestion(ctx, questionId)
+}
+
+// ScheduleAnswer 设置回答草稿的定时发布时间
+func (a *ArticleDAO) ScheduleAnswer(ctx context.Context, authorId model.UserId, answerId int64, at *time.Time) (*model.Answer, app_error.AppError) {
+	if err := scheduleDraft[model.Answer](ctx, a.db, authorId, answerId, at); err != nil {
+		return nil, err
+	}
+	return a.GetAnswer(ctx, answerId)
+}
+
+// PublishQuestion 发布问题草稿并写入第一条修订记录 草稿已被发布时 published 为 false
+func (a *ArticleDAO) PublishQuestion(ctx context.Context, questionId int64) (question *model.Question, published bool, err app_error.AppError) {
+	err = transaction(ctx, a.db, func(tx *gorm.DB) app_error.AppError {
+		var err app_error.AppError
+		if published, err = publishDraft[model.Question](ctx, tx, questionId, "title", "content"); err != nil || !published {
+			return err
+		}
+		q, e := gorm.G[model.Question](tx).Where("id = ?", questionId).First(ctx)
+		if e != nil {
+			return app_error.NewInternalError(app_error.ErrCodeMysql, e)
+		}
+		question = &q
+		return a.ensureFirstRevision(ctx, tx, model.RevisionTargetQuestion, q.ID, model.UserId(q.AuthorId), q.Title, q.Content)
+	})
+	if err != nil || !published {
+		return nil, false, err
+	}
+	return question, true, nil
+}
+
+// PublishAnswer 发布回答草稿并写入第一条修订记录 草稿已被发布时 published 为 false
+func (a *ArticleDAO) PublishAnswer(ctx context.Context, answerId int64) (answer *model.Answer, published bool, err app_error.AppError) {
+	err = transaction(ctx, a.db, func(tx *gorm.DB) app_error.AppError {
+		var err app_error.AppError
+		if published, err = publishDraft[model.Answer](ctx, tx, answerId, "content"); err != nil || !published {
+			return err
+		}
+		an, e := gorm.G[model.Answer](tx).Where("id = ?", answerId).First(ctx)
+		if e != nil {
+			return app_error.NewInternalError(app_error.ErrCodeMysql, e)
+		}
+		answer = &an
+		return a.ensureFirstRevision(ctx, tx, model.RevisionTargetAnswer, an.ID, model.UserId(an.AuthorId), "", an.Content)
+	})
+	if err != nil || !published {
+		return nil, false, err
+	}
+	return answer, true, nil
+}
+
+// ListDueQuestionDrafts 获取到达发布时间的问题草稿
+func (a *ArticleDAO) ListDueQuestionDrafts(ctx context.Context, now time.Time, limit int) ([]model.Question, app_error.AppError) {
+	return listDueDrafts[model.Question](ctx, a.db, now, limit)
+}
+
+// ListDueAnswerDrafts 获取到达发布时间的回答草稿
+func (a *ArticleDAO) ListDueAnswerDrafts(ctx context.Context, now time.Time, limit int) ([]model.Answer, app_error.AppError) {
+	return listDueDrafts[model.Answer](ctx, a.db, now, limit)
+}
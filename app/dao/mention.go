@@ -0,0 +1,77 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+
+	"gorm.io/gorm"
+)
+
+type MentionDAO struct {
+	db *gorm.DB
+}
+
+func NewMentionDAO(db *gorm.DB) *MentionDAO {
+	return &MentionDAO{db: db}
+}
+
+// ReplaceMentions 在事务中用 mentions 替换内容原有的提及记录 返回之前没有被提及过的用户 用于发送通知
+func (dao *MentionDAO) ReplaceMentions(ctx context.Context, target model.MentionTarget, targetId int64, mentions []model.Mention) ([]model.UserId, app_error.AppError) {
+	var previous []model.UserId
+	err := transaction(ctx, dao.db, func(tx *gorm.DB) app_error.AppError {
+		err := tx.Model(new(model.Mention)).
+			Where("target_type = ? and target_id = ?", target, targetId).Pluck("user_id", &previous).Error
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+
+		if _, err := gorm.G[model.Mention](tx).Where("target_type = ? and target_id = ?", target, targetId).Delete(ctx); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		if len(mentions) > 0 {
+			if err := gorm.G[model.Mention](tx).CreateInBatches(ctx, &mentions, 100); err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					return app_error.ErrTimeout.WithError(err)
+				}
+				return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[model.UserId]bool, len(previous)+len(mentions))
+	for _, id := range previous {
+		seen[id] = true
+	}
+	var added []model.UserId
+	for _, m := range mentions {
+		if !seen[m.UserId] {
+			seen[m.UserId] = true
+			added = append(added, m.UserId)
+		}
+	}
+	return added, nil
+}
+
+// ListMentions 获取内容中的提及 按位置排序
+func (dao *MentionDAO) ListMentions(ctx context.Context, target model.MentionTarget, targetId int64) ([]model.Mention, app_error.AppError) {
+	mentions, err := gorm.G[model.Mention](dao.db).Where("target_type = ? and target_id = ?", target, targetId).Order("start ASC").Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return mentions, nil
+}
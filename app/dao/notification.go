@@ -0,0 +1,86 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+
+	"gorm.io/gorm"
+)
+
+type NotificationDAO struct {
+	db *gorm.DB
+}
+
+func NewNotificationDAO(db *gorm.DB) *NotificationDAO {
+	return &NotificationDAO{db: db}
+}
+
+// CreateNotifications 批量创建通知
+func (dao *NotificationDAO) CreateNotifications(ctx context.Context, notifications []model.Notification) app_error.AppError {
+	if len(notifications) == 0 {
+		return nil
+	}
+	err := gorm.G[model.Notification](dao.db).CreateInBatches(ctx, &notifications, 100)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// ListNotifications 分页获取用户的通知 按时间倒序
+func (dao *NotificationDAO) ListNotifications(ctx context.Context, userId model.UserId, unreadOnly bool, page, size int) ([]model.Notification, int64, app_error.AppError) {
+	query := gorm.G[model.Notification](dao.db).Where("user_id = ?", userId)
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+
+	total, err := query.Count(ctx, "id")
+	if err != nil {
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+
+	notifications, err := query.Offset((page - 1) * size).Limit(size).Order("created_at DESC").Find(ctx)
+	if err != nil {
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+
+	return notifications, total, nil
+}
+
+// MarkRead 将通知标为已读
+func (dao *NotificationDAO) MarkRead(ctx context.Context, userId model.UserId, notificationId int64) app_error.AppError {
+	rowsAffected, err := gorm.G[model.Notification](dao.db).Where("id = ? and user_id = ?", notificationId, userId).Update(ctx, "is_read", true)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	if rowsAffected == 0 { // 已读的通知同样不会产生影响行 需要确认通知是否存在
+		count, err := gorm.G[model.Notification](dao.db).Where("id = ? and user_id = ?", notificationId, userId).Count(ctx, "id")
+		if err != nil {
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		if count == 0 {
+			return app_error.ErrNotificationNotFound
+		}
+	}
+	return nil
+}
+
+// MarkAllRead 将用户的全部通知标为已读
+func (dao *NotificationDAO) MarkAllRead(ctx context.Context, userId model.UserId) app_error.AppError {
+	_, err := gorm.G[model.Notification](dao.db).Where("user_id = ? and is_read = ?", userId, false).Update(ctx, "is_read", true)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+
+	"gorm.io/gorm"
+)
+
+// transaction 在事务中执行 fn fn 返回错误时回滚 fn 返回的 AppError 原样返回 提交失败按 mysql 错误处理
+func transaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) app_error.AppError) app_error.AppError {
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+	var appErr app_error.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return app_error.ErrTimeout.WithError(err)
+	}
+	return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+}
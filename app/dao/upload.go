@@ -0,0 +1,169 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UploadDAO struct {
+	db *gorm.DB
+}
+
+func NewUploadDAO(db *gorm.DB) *UploadDAO {
+	return &UploadDAO{db: db}
+}
+
+// CreateUpload 在事务中记录 Blob 和上传记录 Blob 已存在时刷新创建时间
+// 写入 Blob 会锁住这一行 put 在持有锁时写入实际文件 避免清理任务同时删除同一个文件
+func (dao *UploadDAO) CreateUpload(ctx context.Context, blob *model.Blob, upload *model.Upload, put func() app_error.AppError) app_error.AppError {
+	return transaction(ctx, dao.db, func(tx *gorm.DB) app_error.AppError {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}},
+			DoUpdates: clause.Assignments(map[string]any{"created_at": time.Now()}),
+		}).Create(blob).Error
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		if err := put(); err != nil {
+			return err
+		}
+		if err := gorm.G[model.Upload](tx).Create(ctx, upload); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		return nil
+	})
+}
+
+func (dao *UploadDAO) GetUpload(ctx context.Context, id int64) (*model.Upload, app_error.AppError) {
+	upload, err := gorm.G[model.Upload](dao.db).Where("id = ?", id).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_error.ErrUploadNotFound
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return &upload, nil
+}
+
+// FindUploadByHash 查找用户上传过的相同内容 同一用户重复上传时直接复用
+// 复用还没有被引用的上传时刷新创建时间 避免刚返回给用户就被清理任务删除
+func (dao *UploadDAO) FindUploadByHash(ctx context.Context, ownerId model.UserId, hash string) (*model.Upload, app_error.AppError) {
+	_, err := gorm.G[model.Upload](dao.db).Where("owner_id = ? and hash = ? and bound_at IS NULL", ownerId, hash).Update(ctx, "created_at", time.Now())
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	upload, err := gorm.G[model.Upload](dao.db).Where("owner_id = ? and hash = ?", ownerId, hash).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return &upload, nil
+}
+
+// DeleteUpload 删除用户自己的上传记录 文件由清理任务在没有引用后删除
+func (dao *UploadDAO) DeleteUpload(ctx context.Context, ownerId model.UserId, id int64) app_error.AppError {
+	rowsAffected, err := gorm.G[model.Upload](dao.db).Where("id = ? and owner_id = ?", id, ownerId).Delete(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	if rowsAffected == 0 {
+		return app_error.ErrUploadNotFound
+	}
+	return nil
+}
+
+// BindUploads 标记 ownerId 的上传已被内容引用 已标记的不会重复更新
+// 内容中引用的其他用户的上传不会被标记
+func (dao *UploadDAO) BindUploads(ctx context.Context, ownerId model.UserId, ids []int64) app_error.AppError {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := gorm.G[model.Upload](dao.db).Where("id IN ? and owner_id = ? and bound_at IS NULL", ids, ownerId).Update(ctx, "bound_at", time.Now())
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// DeleteOrphanUploads 删除 before 之前上传且一直没有被引用的记录 返回删除的数量
+func (dao *UploadDAO) DeleteOrphanUploads(ctx context.Context, before time.Time) (int, app_error.AppError) {
+	rowsAffected, err := gorm.G[model.Upload](dao.db).Where("bound_at IS NULL and created_at < ?", before).Delete(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 0, app_error.ErrTimeout.WithError(err)
+		}
+		return 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return rowsAffected, nil
+}
+
+// DeleteUnreferencedBlobs 删除最多 limit 个没有上传记录指向的 Blob 返回删除的数量
+// 每个 Blob 在单独的事务中再次检查引用后删除 remove 在持有行锁时删除实际文件
+// 同时上传相同内容的请求会等待事务结束 remove 失败时回滚 下次清理时重试
+func (dao *UploadDAO) DeleteUnreferencedBlobs(ctx context.Context, before time.Time, limit int, remove func(hash string) app_error.AppError) (int, app_error.AppError) {
+	var hashes []string
+	err := dao.db.WithContext(ctx).Model(new(model.Blob)).
+		Where("created_at < ? and NOT EXISTS (SELECT 1 FROM uploads WHERE uploads.hash = blobs.hash)", before).
+		Limit(limit).Pluck("hash", &hashes).Error
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 0, app_error.ErrTimeout.WithError(err)
+		}
+		return 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+
+	deleted := 0
+	for _, hash := range hashes {
+		err := transaction(ctx, dao.db, func(tx *gorm.DB) app_error.AppError {
+			res := tx.Where("hash = ? and created_at < ? and NOT EXISTS (SELECT 1 FROM uploads WHERE uploads.hash = blobs.hash)", hash, before).
+				Delete(new(model.Blob))
+			if res.Error != nil {
+				if errors.Is(res.Error, context.DeadlineExceeded) {
+					return app_error.ErrTimeout.WithError(res.Error)
+				}
+				return app_error.NewInternalError(app_error.ErrCodeMysql, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return nil
+			}
+			if err := remove(hash); err != nil {
+				return err
+			}
+			deleted++
+			return nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
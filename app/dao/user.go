@@ -2,15 +2,20 @@ package dao
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"my_zhihu_backend/app/app_error"
 	"my_zhihu_backend/app/config"
 	"my_zhihu_backend/app/log"
 	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/util"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var l = log.L().With(zap.String("module", "user dao"))
@@ -69,9 +74,173 @@ func (dao *UserDAO) GetByEmail(ctx context.Context, email string) (*model.User,
 	return &user, nil
 }
 
-// ListUserByUsername 通过 username 搜索用户 返回用户详情列表
-func (dao *UserDAO) ListUserByUsername(ctx context.Context, username string) ([]model.User, app_error.AppError) {
-	users, err := gorm.G[model.User](dao.db).Where("username LIKE ?", "%"+username+"%").Find(ctx)
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`) // 转义 LIKE 中的通配符
+
+// GetByHandle 通过 handle 获取用户详情 handle 需要已经转换为小写
+func (dao *UserDAO) GetByHandle(ctx context.Context, handle string) (*model.User, app_error.AppError) {
+	user, err := gorm.G[model.User](dao.db).Where("handle = ?", handle).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_error.ErrUserNotExists
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return &user, nil
+}
+
+// GetHandleHistory 获取曾经使用过 handle 的用户 没有时返回 nil
+func (dao *UserDAO) GetHandleHistory(ctx context.Context, handle string) (*model.UserHandleHistory, app_error.AppError) {
+	history, err := gorm.G[model.UserHandleHistory](dao.db).Where("handle = ?", handle).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return &history, nil
+}
+
+// ChangeHandle 修改用户的 handle 旧 handle 写入历史记录
+// 其他用户在 reservedSince 之后停止使用的 handle 仍然保留给原用户 不能使用
+func (dao *UserDAO) ChangeHandle(ctx context.Context, user *model.User, handle string, reservedSince time.Time) app_error.AppError {
+	return transaction(ctx, dao.db, func(tx *gorm.DB) app_error.AppError {
+		history, err := gorm.G[model.UserHandleHistory](tx).Where("handle = ?", handle).First(ctx)
+		if err == nil {
+			if history.UserId != user.Id && history.CreatedAt.After(reservedSince) {
+				return app_error.ErrHandleTaken
+			}
+			_, err = gorm.G[model.UserHandleHistory](tx).Where("handle = ?", handle).Delete(ctx)
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		if err == nil {
+			_, err = gorm.G[model.User](tx).Where("id = ?", user.Id).Updates(ctx, model.User{Handle: &handle, HandleChangedAt: util.Ptr(time.Now())})
+		}
+		if err == nil && user.Handle != nil {
+			err = gorm.G[model.UserHandleHistory](tx, clause.OnConflict{UpdateAll: true}).Create(ctx, &model.UserHandleHistory{Handle: *user.Handle, UserId: user.Id})
+		}
+		if err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return app_error.ErrHandleTaken
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return app_error.ErrTimeout.WithError(err)
+			}
+			return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+		}
+		return nil
+	})
+}
+
+// ListUsersByHandles 按 handle 批量查找用户
+func (dao *UserDAO) ListUsersByHandles(ctx context.Context, handles []string) ([]model.User, app_error.AppError) {
+	if len(handles) == 0 {
+		return nil, nil
+	}
+	users, err := gorm.G[model.User](dao.db).Where("handle IN ?", handles).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return users, nil
+}
+
+// maxSearchResults 搜索用户时最多统计和翻页的结果数
+const maxSearchResults = 1000
+
+// SearchUsers 搜索用户名包含 keyword 的用户 依次按完全匹配、前缀匹配、粉丝数排序
+// 前缀匹配可以使用 username 的索引 其余的包含匹配单独查询 排在所有前缀匹配之后
+// total 最多统计到 maxSearchResults 超出该范围的页直接返回空
+func (dao *UserDAO) SearchUsers(ctx context.Context, keyword string, page, size int) ([]model.User, int64, app_error.AppError) {
+	escaped := likeEscaper.Replace(keyword)
+	prefix := dao.db.Where("username LIKE ?", escaped+"%")
+	substring := dao.db.Where("username LIKE ? and username NOT LIKE ?", "%"+escaped+"%", escaped+"%")
+
+	prefixTotal, err := dao.countUsers(ctx, prefix, maxSearchResults)
+	if err != nil {
+		return nil, 0, err
+	}
+	substringTotal, err := dao.countUsers(ctx, substring, maxSearchResults-int(prefixTotal))
+	if err != nil {
+		return nil, 0, err
+	}
+	total := prefixTotal + substringTotal
+	offset := (page - 1) * size
+	if offset >= int(total) {
+		return nil, total, nil
+	}
+	limit := min(size, int(total)-offset)
+
+	var users []model.User
+	if offset < int(prefixTotal) {
+		found, err := dao.findUsers(ctx, prefix, offset, min(limit, int(prefixTotal)-offset),
+			clause.Expr{SQL: "CASE WHEN username = ? THEN 0 ELSE 1 END, follower_count DESC, id", Vars: []any{keyword}})
+		if err != nil {
+			return nil, 0, err
+		}
+		users = found
+	}
+	if rest := limit - len(users); rest > 0 {
+		found, err := dao.findUsers(ctx, substring, max(offset-int(prefixTotal), 0), rest, clause.Expr{SQL: "follower_count DESC, id"})
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, found...)
+	}
+	return users, total, nil
+}
+
+// countUsers 统计满足 cond 的用户数 最多统计到 limit
+func (dao *UserDAO) countUsers(ctx context.Context, cond *gorm.DB, limit int) (int64, app_error.AppError) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	var total int64
+	matched := dao.db.Model(new(model.User)).Select("id").Where(cond).Limit(limit)
+	if err := dao.db.WithContext(ctx).Table("(?) AS matched", matched).Count(&total).Error; err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 0, app_error.ErrTimeout.WithError(err)
+		}
+		return 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return total, nil
+}
+
+// findUsers 按 order 分页读取满足 cond 的用户 order 需要包装成 clause.OrderBy 直接传给 Order 的 clause.Expr 会被忽略
+func (dao *UserDAO) findUsers(ctx context.Context, cond *gorm.DB, offset, limit int, order clause.Expr) ([]model.User, app_error.AppError) {
+	users, err := gorm.G[model.User](dao.db).Where(cond).Order(clause.OrderBy{Expression: order}).Offset(offset).Limit(limit).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return users, nil
+}
+
+// ListUsernames 按 id 顺序分批读取 id 和用户名 用于重建用户名索引
+func (dao *UserDAO) ListUsernames(ctx context.Context, afterId model.UserId, size int) ([]model.User, app_error.AppError) {
+	users, err := gorm.G[model.User](dao.db).Select("id", "username").Where("id > ?", afterId).Order("id").Limit(size).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return users, nil
+}
+
+// ListMostFollowedUsers 读取粉丝最多的 size 个用户 用于预热缓存
+func (dao *UserDAO) ListMostFollowedUsers(ctx context.Context, size int) ([]model.User, app_error.AppError) {
+	users, err := gorm.G[model.User](dao.db).Order("follower_count DESC, id").Limit(size).Find(ctx)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return nil, app_error.ErrTimeout.WithError(err)
@@ -97,8 +266,17 @@ func (dao *UserDAO) UpdateFields(ctx context.Context, id model.UserId, fields ma
 			expr := "json_set(ifnull(other, '{}')"
 			args := make([]any, 0, len(otherMap)*2)
 			for k, v := range otherMap {
-				expr += ", ?, ?"
-				args = append(args, "$."+k, v)
+				if m, ok := v.(map[string]string); ok { // 对象需要转换为 json 类型 否则会被当作字符串写入
+					b, err := json.Marshal(m)
+					if err != nil {
+						return nil, ErrMysqlInvalidFields
+					}
+					expr += ", ?, cast(? as json)"
+					args = append(args, "$."+k, string(b))
+				} else {
+					expr += ", ?, ?"
+					args = append(args, "$."+k, v)
+				}
 			}
 			expr += ")"
 			fields["other"] = gorm.Expr(expr, args...)
@@ -318,3 +496,250 @@ func (dao *UserDAO) ListFollowings(ctx context.Context, followerID model.UserId)
 
 	return results, nil
 }
+
+// ListFollowersPage 按关注时间倒序分页获取粉丝 beforeAt 为 nil 时从最新开始 否则返回 (beforeAt, beforeId) 之后的记录
+func (dao *UserDAO) ListFollowersPage(ctx context.Context, followingID model.UserId, beforeAt *time.Time, beforeId model.UserId, size int) ([]model.UserFollowers, app_error.AppError) {
+	query := gorm.G[model.UserFollowers](dao.db).Where("following_id = ?", followingID)
+	if beforeAt != nil {
+		query = query.Where("created_at < ? or (created_at = ? and follower_id < ?)", *beforeAt, *beforeAt, beforeId)
+	}
+	relations, err := query.Order("created_at DESC, follower_id DESC").Limit(size).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return relations, nil
+}
+
+// ListFollowingsPage 按关注时间倒序分页获取关注的用户
+func (dao *UserDAO) ListFollowingsPage(ctx context.Context, followerID model.UserId, beforeAt *time.Time, beforeId model.UserId, size int) ([]model.UserFollowers, app_error.AppError) {
+	query := gorm.G[model.UserFollowers](dao.db).Where("follower_id = ?", followerID)
+	if beforeAt != nil {
+		query = query.Where("created_at < ? or (created_at = ? and following_id < ?)", *beforeAt, *beforeAt, beforeId)
+	}
+	relations, err := query.Order("created_at DESC, following_id DESC").Limit(size).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return relations, nil
+}
+
+// ListUsersByIds 批量获取用户
+func (dao *UserDAO) ListUsersByIds(ctx context.Context, ids []model.UserId) ([]model.User, app_error.AppError) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	users, err := gorm.G[model.User](dao.db).Where("id IN ?", ids).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return users, nil
+}
+
+// ListUsersByUsernames 按用户名精确查找用户 同名用户会全部返回
+func (dao *UserDAO) ListUsersByUsernames(ctx context.Context, usernames []string) ([]model.User, app_error.AppError) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	users, err := gorm.G[model.User](dao.db).Where("username IN ?", usernames).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return users, nil
+}
+
+// FilterFollowings 返回 ids 中被 followerID 关注的用户
+func (dao *UserDAO) FilterFollowings(ctx context.Context, followerID model.UserId, ids []model.UserId) ([]model.UserId, app_error.AppError) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var results []model.UserId
+	err := dao.db.WithContext(ctx).Model(new(model.UserFollowers)).
+		Where("follower_id = ? and following_id IN ?", followerID, ids).Pluck("following_id", &results).Error
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return results, nil
+}
+
+// FilterFollowers 返回 ids 中关注了 followingID 的用户
+func (dao *UserDAO) FilterFollowers(ctx context.Context, followingID model.UserId, ids []model.UserId) ([]model.UserId, app_error.AppError) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var results []model.UserId
+	err := dao.db.WithContext(ctx).Model(new(model.UserFollowers)).
+		Where("following_id = ? and follower_id IN ?", followingID, ids).Pluck("follower_id", &results).Error
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return results, nil
+}
+
+// BlockUser 拉黑用户 重复拉黑不报错
+func (dao *UserDAO) BlockUser(ctx context.Context, blockerID, blockedID model.UserId) app_error.AppError {
+	if _, err := dao.GetById(ctx, blockedID); err != nil {
+		return err
+	}
+	err := gorm.G[model.UserBlock](dao.db).Create(ctx, &model.UserBlock{BlockerID: blockerID, BlockedID: blockedID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// UnblockUser 取消拉黑
+func (dao *UserDAO) UnblockUser(ctx context.Context, blockerID, blockedID model.UserId) app_error.AppError {
+	_, err := gorm.G[model.UserBlock](dao.db).Where("blocker_id = ? and blocked_id = ?", blockerID, blockedID).Delete(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return app_error.ErrTimeout.WithError(err)
+		}
+		return app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return nil
+}
+
+// ListBlockedAmong 返回 ids 中与 userId 存在任一方向拉黑关系的用户
+func (dao *UserDAO) ListBlockedAmong(ctx context.Context, userId model.UserId, ids []model.UserId) ([]model.UserId, app_error.AppError) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var results []model.UserId
+	err := dao.db.WithContext(ctx).Raw(`
+		SELECT blocked_id FROM user_blocks WHERE blocker_id = ? AND blocked_id IN ?
+		UNION
+		SELECT blocker_id FROM user_blocks WHERE blocked_id = ? AND blocker_id IN ?`,
+		userId, ids, userId, ids).Scan(&results).Error
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return results, nil
+}
+
+// IsBlocked 两个用户之间是否存在任一方向的拉黑关系
+func (dao *UserDAO) IsBlocked(ctx context.Context, a, b model.UserId) (bool, app_error.AppError) {
+	blocked, err := dao.ListBlockedAmong(ctx, a, []model.UserId{b})
+	if err != nil {
+		return false, err
+	}
+	return len(blocked) > 0, nil
+}
+
+// ListMutualFollowers 同时关注了 a 和 b 的用户 按关注 a 的时间倒序 同时返回总数
+func (dao *UserDAO) ListMutualFollowers(ctx context.Context, a, b model.UserId, size int) ([]model.UserId, int64, app_error.AppError) {
+	query := gorm.G[model.UserFollowers](dao.db).
+		Where("following_id = ? and follower_id in (?)", a, dao.db.Model(&model.UserFollowers{}).Select("follower_id").Where("following_id = ?", b))
+	total, err := query.Count(ctx, "follower_id")
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, 0, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	relations, err := query.Order("created_at DESC, follower_id DESC").Limit(size).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, 0, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	results := make([]model.UserId, 0, len(relations))
+	for _, r := range relations {
+		results = append(results, r.FollowerID)
+	}
+	return results, total, nil
+}
+
+// ListFollowingsFollowing followerID 关注的用户中关注了 followingID 的用户 按关注 followingID 的时间倒序 同时返回总数
+func (dao *UserDAO) ListFollowingsFollowing(ctx context.Context, followerID, followingID model.UserId, size int) ([]model.UserId, int64, app_error.AppError) {
+	query := gorm.G[model.UserFollowers](dao.db).
+		Where("following_id = ? and follower_id in (?)", followingID, dao.db.Model(&model.UserFollowers{}).Select("following_id").Where("follower_id = ?", followerID))
+	total, err := query.Count(ctx, "follower_id")
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, 0, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	relations, err := query.Order("created_at DESC, follower_id DESC").Limit(size).Find(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, 0, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, 0, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	results := make([]model.UserId, 0, len(relations))
+	for _, r := range relations {
+		results = append(results, r.FollowerID)
+	}
+	return results, total, nil
+}
+
+// RecommendedFollow 推荐关注的用户 Overlap 为我关注的用户中关注了该用户的人数
+type RecommendedFollow struct {
+	UserId  model.UserId
+	Overlap int
+}
+
+// ListRecommendedFollows 二度关注推荐 统计我关注的用户各自关注了谁 按重合人数倒序
+// 排除自己、已关注的、存在拉黑关系的和已注销的用户 关注列表对我不可见的用户不参与统计
+// 关注列表的可见范围与 model.UserSettings 一致 没有单独设置时按旧版本的 hide_privacy 判断
+func (dao *UserDAO) ListRecommendedFollows(ctx context.Context, userId model.UserId, size int) ([]RecommendedFollow, app_error.AppError) {
+	var results []RecommendedFollow
+	err := dao.db.WithContext(ctx).Raw(`
+		SELECT f2.following_id AS user_id, COUNT(*) AS overlap
+		FROM user_followers f1
+		JOIN (
+			SELECT id, CAST(COALESCE(JSON_UNQUOTE(JSON_EXTRACT(settings, '$.followings_visibility')), IF(JSON_EXTRACT(settings, '$.hide_privacy') = true, ?, ?)) AS SIGNED) AS visibility
+			FROM users WHERE deleted_at IS NULL
+		) m ON m.id = f1.following_id
+			AND (m.visibility IN (?, ?) OR (m.visibility = ? AND EXISTS (
+				SELECT 1 FROM user_followers r WHERE r.follower_id = m.id AND r.following_id = f1.follower_id)))
+		JOIN user_followers f2 ON f2.follower_id = f1.following_id
+		JOIN users u ON u.id = f2.following_id AND u.deleted_at IS NULL
+		WHERE f1.follower_id = ?
+			AND f2.following_id <> ?
+			AND f2.following_id NOT IN (SELECT following_id FROM user_followers WHERE follower_id = ?)
+			AND f2.following_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)
+			AND f2.following_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = ?)
+		GROUP BY f2.following_id
+		ORDER BY overlap DESC, user_id DESC
+		LIMIT ?`,
+		model.VisibilityPrivate, model.VisibilityPublic,
+		model.VisibilityPublic, model.VisibilityFollowers, model.VisibilityMutual,
+		userId, userId, userId, userId, userId, size).Scan(&results).Error
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, app_error.ErrTimeout.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeMysql, err)
+	}
+	return results, nil
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"my_zhihu_backend/app/app_error"
 	"my_zhihu_backend/app/cache"
+	"my_zhihu_backend/app/config"
 	"my_zhihu_backend/app/log"
 	"my_zhihu_backend/app/response"
 	"my_zhihu_backend/app/service"
@@ -127,34 +129,136 @@ func (q *queryResponseHijack) Write(b []byte) (int, error) {
 	return q.ResponseWriter.Write(b)
 }
 
-func CacheQuery(client *redis.Client, prefix, filterName string) gin.HandlerFunc {
+const (
+	cacheQueryTTL               = 15 * time.Minute // 接口响应缓存的有效期
+	cacheQueryCompressThreshold = 4096             // 序列化后超过该字节数的响应使用 zstd 压缩后缓存
+)
+
+type cacheQueryOptions struct {
+	varyByUser bool
+	headers    []string
+	tags       func(c *gin.Context) []string
+}
+
+type CacheQueryOption func(*cacheQueryOptions)
+
+// VaryByUser 响应与当前用户有关 缓存键包含当前用户 id 同时为缓存加上当前用户的标签
+// 需要放在 Auth 之后
+func VaryByUser() CacheQueryOption {
+	return func(o *cacheQueryOptions) {
+		o.varyByUser = true
+	}
+}
+
+// VaryByHeader 缓存键包含这些请求头的值
+func VaryByHeader(headers ...string) CacheQueryOption {
+	return func(o *cacheQueryOptions) {
+		o.headers = append(o.headers, headers...)
+	}
+}
+
+// WithTags 为缓存加上标签 通过 cache.TagIndex 按标签删除
+func WithTags(tags func(c *gin.Context) []string) CacheQueryOption {
+	return func(o *cacheQueryOptions) {
+		o.tags = tags
+	}
+}
+
+// TagByParam 使用路径参数中的用户 id 作为标签
+func TagByParam(param string) CacheQueryOption {
+	return WithTags(func(c *gin.Context) []string {
+		return []string{cache.UserTag(c.Param(param))}
+	})
+}
+
+// cacheKey 缓存键由 RequestURI 和 VaryByUser VaryByHeader 指定的维度组成
+func (o *cacheQueryOptions) cacheKey(c *gin.Context) string {
+	var b strings.Builder
+	b.WriteString(c.Request.URL.RequestURI())
+	if o.varyByUser {
+		fmt.Fprintf(&b, "|user=%v", c.MustGet("id"))
+	}
+	for _, h := range o.headers {
+		fmt.Fprintf(&b, "|%s=%s", h, c.GetHeader(h))
+	}
+	return b.String()
+}
+
+func (o *cacheQueryOptions) cacheTags(c *gin.Context) []string {
+	var tags []string
+	if o.tags != nil {
+		tags = o.tags(c)
+	}
+	if o.varyByUser {
+		tags = append(tags, cache.UserTag(c.MustGet("id")))
+	}
+	return tags
+}
+
+// setCacheStatus 按 RFC 9211 设置 Cache-Status 响应头 用于调试
+func setCacheStatus(c *gin.Context, status, key string) {
+	c.Header("Cache-Status", fmt.Sprintf("my_zhihu; %s; key=%q", status, key))
+}
+
+// CacheQuery 缓存 GET 请求的响应 默认只按 RequestURI 区分 与当前用户有关的响应需要使用 VaryByUser
+func CacheQuery(client *redis.Client, prefix, filterName string, opts ...CacheQueryOption) gin.HandlerFunc {
+	options := new(cacheQueryOptions)
+	for _, opt := range opts {
+		opt(options)
+	}
+	backend := cache.Backend(config.C().Service.CacheBackend)
+	tagIndex := cache.NewTagIndexOn(backend, client, config.C().Prefix.ResponseTagPrefix)
+	cacher := cache.NewJsonCacherOn(backend, client, cacheQueryTTL, prefix, func(ctx context.Context, args ...any) (*response.Response, app_error.AppError) {
+		return nil, app_error.ErrRedisCacheKeyNotExists
+	}, cache.NewBloomFilterOn(backend, filterName, client), cache.WithCodec(cache.Compressed(cache.JSON, cacheQueryCompressThreshold)))
 	return func(c *gin.Context) {
-		cacher := cache.NewJsonCacher(client, 15*time.Minute, prefix, func(ctx context.Context, args ...any) (*response.Response, app_error.AppError) {
-			return nil, app_error.ErrRedisCacheKeyNotExists
-		}, cache.NewBloomFilter(filterName, client))
+		key := options.cacheKey(c)
 		timeout, cancel := context.WithTimeout(c.Request.Context(), 1*time.Second)
 		defer cancel()
-		resp, err := cacher.Get(timeout, c.Request.URL.RequestURI(), c)
+		resp, err := cacher.Get(timeout, key, c)
 		if err == nil && resp != nil { // 缓存命中 直接返回
+			setCacheStatus(c, "hit", key)
 			c.JSON(http.StatusOK, resp)
 			c.Abort()
 			return
 		}
+		if (err != nil && !errors.Is(err, app_error.ErrRedisCacheKeyNotExists)) || cache.RedisBreaker.Status().State == cache.BreakerOpen {
+			setCacheStatus(c, "fwd=bypass", key) // 缓存不可用
+		} else {
+			setCacheStatus(c, "fwd=miss", key)
+		}
+
+		// 生成响应前读取标签的清除次数 读取失败时无法判断写入的缓存是否过期 不缓存这次的响应
+		tags := options.cacheTags(c)
+		gen, genErr := tagIndex.Generation(timeout, tags...)
 
 		hijack := &queryResponseHijack{c.Writer, bytes.NewBufferString("")} // 缓存未命中 开始劫持ResponseWriter
 		c.Writer = hijack
 		c.Next()
-		if c.Writer.Status() == http.StatusOK && c.Errors.Last() == nil { // controller 正常响应
-			go func(rawJson []byte, key string) {
+		if c.Writer.Status() == http.StatusOK && c.Errors.Last() == nil && genErr == nil { // controller 正常响应
+			go func(rawJson []byte, key string, tags []string) {
 				var resp response.Response
 				if err := json.Unmarshal(rawJson, &resp); err != nil {
 					l.Error("failed to unmarshal json to response.Response", app_error.ErrInvalidJsonBody.WithError(err).ErrorField()...)
 					return
 				}
+				// 先记录标签再写入缓存 避免写入后、记录标签前被清除时遗漏
+				if err := tagIndex.Tag(context.Background(), cacher.Prefix()+key, cacher.TTL(), tags...); err != nil {
+					l.Error("failed to tag cached response", err.ErrorField()...)
+					return
+				}
 				if err := cacher.Put(context.Background(), key, resp); err != nil {
 					l.Error("failed to cache response", err.ErrorField()...)
+					return
+				}
+				// 生成响应期间标签被清除过 响应可能是清除前读到的旧数据 删除刚写入的缓存
+				// 写入之后才发生的清除会通过标签删除这个键 不需要处理
+				if current, err := tagIndex.Generation(context.Background(), tags...); err != nil || current != gen {
+					if _, err := cacher.Invalidate(context.Background(), key); err != nil && !errors.Is(err, app_error.ErrRedisCacheKeyNotExists) {
+						l.Error("failed to invalidate stale cached response", err.ErrorField()...)
+					}
 				}
-			}(hijack.body.Bytes(), c.Request.URL.RequestURI())
+			}(hijack.body.Bytes(), key, tags)
 		}
 	}
 }
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type ActivityType int
+
+const (
+	ActivityAsk           ActivityType = iota + 1 // 提出问题
+	ActivityAnswer                                // 回答问题
+	ActivityFollowUser                            // 关注用户
+	ActivityWatchQuestion                         // 关注问题
+)
+
+// Activity 用户动态 按类型使用 QuestionId AnswerId TargetUserId 中的一部分 其余为零值
+// 相同的动态只记录一次 撤销操作或删除内容时删除对应的动态
+type Activity struct {
+	ID           int64        `gorm:"primarykey"`
+	CreatedAt    time.Time    `gorm:"index:idx_activity_user_time,priority:2"`
+	UserId       UserId       `gorm:"type:int;not null;index:idx_activity_user_time,priority:1;uniqueIndex:idx_activity_unique,priority:1"`
+	Type         ActivityType `gorm:"not null;uniqueIndex:idx_activity_unique,priority:2"`
+	QuestionId   int64        `gorm:"not null;default:0;index;uniqueIndex:idx_activity_unique,priority:3"`
+	AnswerId     int64        `gorm:"not null;default:0;index;uniqueIndex:idx_activity_unique,priority:4"`
+	TargetUserId UserId       `gorm:"type:int;not null;default:0;uniqueIndex:idx_activity_unique,priority:5"`
+}
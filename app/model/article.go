@@ -16,8 +16,27 @@ type Question struct {
 	AuthorId    int64          `gorm:"type:int;index"`
 	User        User           `gorm:"foreignKey:AuthorId;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	IsAvailable bool           `gorm:"default:true;index"`
+
+	IsDraft     bool       `gorm:"not null;default:false;index"` // 草稿不出现在搜索和列表中
+	Version     int        `gorm:"not null;default:1"`           // 草稿自动保存使用的乐观锁版本号
+	ScheduledAt *time.Time `gorm:"index"`                        // 草稿的定时发布时间
+
+	Status           QuestionStatus `gorm:"not null;default:0;index"`
+	DuplicateOfId    *int64         `gorm:"index"` // Status 为 QuestionStatusDuplicate 时指向被重复的问题
+	AcceptedAnswerId *int64         // 提问者采纳的回答
+
+	ViewCount int64 `gorm:"not null;default:0;index" json:"-"` // 浏览次数 用于预热浏览最多的问题 不在接口中返回 也不写入缓存
 }
 
+type QuestionStatus int
+
+const (
+	QuestionStatusOpen      QuestionStatus = iota
+	QuestionStatusClosed                   // 关闭 不再接受新回答
+	QuestionStatusDuplicate                // 重复问题 不再接受新回答
+	QuestionStatusLocked                   // 锁定 不允许回答和编辑 已有的回答也只有版主可以编辑和删除 只有版主可以锁定和解锁
+)
+
 type Answer struct {
 	ID          int64 `gorm:"primarykey"`
 	CreatedAt   time.Time
@@ -29,6 +48,9 @@ type Answer struct {
 	Content     string         `gorm:"type:text;not null;index:,class:FULLTEXT,option:WITH PARSER ngram VISIBLE"`
 	LikeCount   int            `gorm:"default:0"`
 	IsAvailable bool           `gorm:"default:true;index"`
+	IsDraft     bool           `gorm:"not null;default:false;index"` // 草稿不出现在回答列表中
+	Version     int            `gorm:"not null;default:1"`           // 草稿自动保存使用的乐观锁版本号
+	ScheduledAt *time.Time     `gorm:"index"`                        // 草稿的定时发布时间
 }
 
 type Comment struct {
@@ -45,3 +67,38 @@ type Comment struct {
 	LikeCount   int            `gorm:"default:0"`
 	IsAvailable bool           `gorm:"default:true;index"`
 }
+
+// QuestionWatcher 关注问题的用户 联合主键保证同一用户不会重复关注同一问题
+type QuestionWatcher struct {
+	QuestionId int64  `gorm:"primaryKey;autoIncrement:false"`
+	UserId     UserId `gorm:"primaryKey;type:int;index"` // 给用户加索引 方便查询用户关注的问题
+	CreatedAt  time.Time
+}
+
+// QuestionInvitation 邀请回答 同一问题同一用户只会被邀请一次
+type QuestionInvitation struct {
+	ID         int64     `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index"`
+	QuestionId int64     `gorm:"not null;uniqueIndex:idx_question_invitee"`
+	InviterId  UserId    `gorm:"type:int;not null;index"`
+	InviteeId  UserId    `gorm:"type:int;not null;uniqueIndex:idx_question_invitee"`
+}
+
+type RevisionTarget int
+
+const (
+	RevisionTargetQuestion RevisionTarget = iota + 1
+	RevisionTargetAnswer
+)
+
+// Revision 问题和回答的修订记录 只追加不修改 Version 从 1 开始按编辑顺序递增
+type Revision struct {
+	ID         int64 `gorm:"primarykey"`
+	CreatedAt  time.Time
+	TargetType RevisionTarget `gorm:"not null;uniqueIndex:idx_revision_version"`
+	TargetId   int64          `gorm:"not null;uniqueIndex:idx_revision_version"`
+	Version    int            `gorm:"not null;uniqueIndex:idx_revision_version"`
+	EditorId   UserId         `gorm:"type:int;not null;index"`
+	Title      string         `gorm:"type:varchar(255);not null;default:''"` // 回答没有标题
+	Content    string         `gorm:"type:text;not null"`
+}
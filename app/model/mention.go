@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type MentionTarget int
+
+const (
+	MentionTargetQuestion MentionTarget = iota + 1
+	MentionTargetAnswer
+	MentionTargetComment
+)
+
+// Mention 内容中 @ 到的用户 Start End 为 @username 在内容中按字符(rune)计算的起止位置
+type Mention struct {
+	ID         int64 `gorm:"primarykey"`
+	CreatedAt  time.Time
+	TargetType MentionTarget `gorm:"not null;index:idx_mention_target"`
+	TargetId   int64         `gorm:"not null;index:idx_mention_target"`
+	AuthorId   UserId        `gorm:"type:int;not null"`
+	UserId     UserId        `gorm:"type:int;not null;index"`
+	Username   string        `gorm:"type:varchar(50);not null"`
+	Start      int           `gorm:"not null"`
+	End        int           `gorm:"not null"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type NotificationType int
+
+const (
+	NotificationNewAnswer  NotificationType = iota + 1 // 关注的问题有了新回答
+	NotificationInvitation                             // 被邀请回答问题
+	NotificationMention                                // 在问题、回答或评论中被提及
+)
+
+// Notification 站内通知 ActorId 为触发通知的用户
+type Notification struct {
+	ID         int64            `gorm:"primarykey"`
+	CreatedAt  time.Time        `gorm:"index"`
+	UserId     UserId           `gorm:"type:int;not null;index"`
+	Type       NotificationType `gorm:"not null"`
+	ActorId    UserId           `gorm:"type:int;not null"`
+	QuestionId int64            `gorm:"not null;default:0"`
+	AnswerId   int64            `gorm:"not null;default:0"`
+	CommentId  int64            `gorm:"not null;default:0"`
+	IsRead     bool             `gorm:"not null;default:false;index"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Blob 实际存储的文件 以内容的 sha256 作为主键 多次上传相同内容只保存一份
+type Blob struct {
+	Hash        string `gorm:"primaryKey;type:char(64)"`
+	CreatedAt   time.Time
+	Size        int64  `gorm:"not null"`
+	ContentType string `gorm:"type:varchar(127);not null"`
+}
+
+// Upload 用户的一次上传 指向实际存储的 Blob
+// 上传后被问题、回答或头像引用时记录 BoundAt 超过保留期仍未被引用的上传会被清理
+type Upload struct {
+	ID          int64      `gorm:"primarykey"`
+	CreatedAt   time.Time  `gorm:"index"`
+	OwnerId     UserId     `gorm:"type:int;not null;index:idx_owner_hash"`
+	Hash        string     `gorm:"type:char(64);not null;index:idx_owner_hash;index"`
+	Filename    string     `gorm:"type:varchar(255);not null"`
+	Size        int64      `gorm:"not null"`
+	ContentType string     `gorm:"type:varchar(127);not null"`
+	BoundAt     *time.Time `gorm:"index"`
+}
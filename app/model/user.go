@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -9,21 +10,24 @@ import (
 type UserId int64 // 使用雪花算法生成 UserId
 
 type User struct {
-	Id             UserId         `gorm:"primaryKey;not null;type:int" json:"id"`
-	Username       string         `gorm:"index;not null;type:varchar(50)" json:"username"`
-	HPassword      string         `gorm:"not null;type:varchar(128);not null" json:"-"` // 使用bcrypt来生成哈希值 不需要存储盐值 varchar(128)为将来算法升级准备
-	Email          string         `gorm:"unique;not null;type:varchar(100);index" json:"email"`
-	Followers      []User         `gorm:"many2many:user_followers;foreignKey:Id;joinForeignKey:FollowingID;References:Id;joinReferences:FollowerID" json:"followers"`  // 我的粉丝
-	Followings     []User         `gorm:"many2many:user_followers;foreignKey:Id;joinForeignKey:FollowerID;References:Id;joinReferences:FollowingID" json:"followings"` // 我的关注
-	FollowerCount  int            `gorm:"not null;type:int;default:0" json:"follower_count"`
-	FollowingCount int            `gorm:"not null;type:int;default:0" json:"following_count"`
-	Gender         *UserGender    `gorm:"default:0;not null" json:"gender"` // 使用指针类型区分null和默认零值
-	Region         string         `gorm:"not null;type:varchar(50);default:'';not null" json:"region"`
-	Settings       UserSettings   `gorm:"serializer:json;type:json" json:"settings"`
-	Other          UserOtherInfo  `gorm:"serializer:json;type:json" json:"other"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	Id              UserId         `gorm:"primaryKey;not null;type:int" json:"id"`
+	Username        string         `gorm:"index;not null;type:varchar(50)" json:"username"` // 显示名 可以重复
+	Handle          *string        `gorm:"uniqueIndex;type:varchar(30)" json:"handle"`      // 唯一的用户标识 统一保存为小写 未设置时为 nil
+	HandleChangedAt *time.Time     `json:"handle_changed_at"`
+	HPassword       string         `gorm:"not null;type:varchar(128);not null" json:"-"` // 使用bcrypt来生成哈希值 不需要存储盐值 varchar(128)为将来算法升级准备
+	Email           string         `gorm:"unique;not null;type:varchar(100);index" json:"email"`
+	Followers       []User         `gorm:"many2many:user_followers;foreignKey:Id;joinForeignKey:FollowingID;References:Id;joinReferences:FollowerID" json:"followers"`  // 我的粉丝
+	Followings      []User         `gorm:"many2many:user_followers;foreignKey:Id;joinForeignKey:FollowerID;References:Id;joinReferences:FollowingID" json:"followings"` // 我的关注
+	FollowerCount   int            `gorm:"not null;type:int;default:0" json:"follower_count"`
+	FollowingCount  int            `gorm:"not null;type:int;default:0" json:"following_count"`
+	Gender          *UserGender    `gorm:"default:0;not null" json:"gender"` // 使用指针类型区分null和默认零值
+	Region          string         `gorm:"not null;type:varchar(50);default:'';not null" json:"region"`
+	Settings        UserSettings   `gorm:"serializer:json;type:json" json:"settings"`
+	Other           UserOtherInfo  `gorm:"serializer:json;type:json" json:"other"`
+	Role            UserRole       `gorm:"not null;default:0" json:"role"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 // UserFollowers 联合主键保证关注关系不会重复出现 a->b 不允许重复 但a->b b->a可同时存在
@@ -33,6 +37,21 @@ type UserFollowers struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// UserHandleHistory 用户用过的 handle 用于旧链接和旧提及的跳转 CreatedAt 为停止使用的时间
+// 在 config.ServiceConfig.HandleReserveTTL 内只有原用户可以重新使用
+type UserHandleHistory struct {
+	Handle    string    `gorm:"primaryKey;type:varchar(30)" json:"handle"`
+	UserId    UserId    `gorm:"not null;index" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserBlock 拉黑关系 BlockerID 拉黑了 BlockedID 双方互相不能关注和提及
+type UserBlock struct {
+	BlockerID UserId    `gorm:"primaryKey;type:int" json:"blocker_id"`
+	BlockedID UserId    `gorm:"primaryKey;type:int;index" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type UserGender int
 
 const (
@@ -41,12 +60,91 @@ const (
 	UserGenderFemale
 )
 
+type UserRole int
+
+const (
+	UserRoleNormal    UserRole = iota
+	UserRoleModerator          // 版主 可以管理问题状态
+)
+
 // UserOtherInfo 个性签名 简介 头像等不参与计算比较的杂项
 type UserOtherInfo struct {
-	Introduction string `json:"introduction"`
-	Icon         string `json:"icon"` // 指向图像的URL地址
+	Introduction string            `json:"introduction"`
+	Icon         string            `json:"icon"`                 // 指向图像的URL地址 上传头像后为最大尺寸的地址
+	IconSizes    map[string]string `json:"icon_sizes,omitempty"` // 上传头像后各尺寸的URL地址 键为边长像素数
 }
 
+// Visibility 资料字段对其他用户的可见范围
+type Visibility int
+
+const (
+	VisibilityPublic    Visibility = iota // 所有人可见
+	VisibilityFollowers                   // 关注了我的用户可见
+	VisibilityMutual                      // 互相关注的用户可见
+	VisibilityPrivate                     // 仅自己可见
+)
+
+// PrivacyField 可以单独设置可见范围的字段
+type PrivacyField int
+
+const (
+	PrivacyEmail PrivacyField = iota
+	PrivacyGender
+	PrivacyRegion
+	PrivacyFollowers  // 粉丝列表
+	PrivacyFollowings // 关注列表
+	PrivacyActivity   // 动态
+)
+
 type UserSettings struct {
-	HidePrivacy bool `json:"hide_privacy"`
+	EmailVisibility      Visibility `json:"email_visibility"`
+	GenderVisibility     Visibility `json:"gender_visibility"`
+	RegionVisibility     Visibility `json:"region_visibility"`
+	FollowersVisibility  Visibility `json:"followers_visibility"`
+	FollowingsVisibility Visibility `json:"followings_visibility"`
+	ActivityVisibility   Visibility `json:"activity_visibility"`
+}
+
+// Visibility 获取字段的可见范围
+func (s *UserSettings) Visibility(field PrivacyField) Visibility {
+	switch field {
+	case PrivacyEmail:
+		return s.EmailVisibility
+	case PrivacyGender:
+		return s.GenderVisibility
+	case PrivacyRegion:
+		return s.RegionVisibility
+	case PrivacyFollowers:
+		return s.FollowersVisibility
+	case PrivacyFollowings:
+		return s.FollowingsVisibility
+	case PrivacyActivity:
+		return s.ActivityVisibility
+	}
+	return VisibilityPrivate
+}
+
+// SetAll 将所有字段设置为同一个可见范围
+func (s *UserSettings) SetAll(v Visibility) {
+	*s = UserSettings{v, v, v, v, v, v}
+}
+
+// UnmarshalJSON 兼容旧版本只有 hide_privacy 的设置 hide_privacy 为 true 时没有单独设置的字段视为仅自己可见
+func (s *UserSettings) UnmarshalJSON(data []byte) error {
+	type plain UserSettings
+	var legacy struct {
+		HidePrivacy bool `json:"hide_privacy"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	var settings plain
+	if legacy.HidePrivacy {
+		(*UserSettings)(&settings).SetAll(VisibilityPrivate)
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return err
+	}
+	*s = UserSettings(settings)
+	return nil
 }
@@ -25,7 +25,7 @@ func NewMysqlDBConn(cfg config.ReadConfigFunc) *gorm.DB {
 }
 
 func AutoMigrate(db *gorm.DB) {
-	if err := db.AutoMigrate(new(model.User), new(model.UserFollowers), new(model.Answer), new(model.Question), new(model.Comment)); err != nil {
+	if err := db.AutoMigrate(new(model.User), new(model.UserFollowers), new(model.Answer), new(model.Question), new(model.Comment), new(model.QuestionWatcher), new(model.QuestionInvitation), new(model.Notification), new(model.Revision), new(model.Blob), new(model.Upload), new(model.Mention), new(model.UserBlock), new(model.UserHandleHistory), new(model.Activity)); err != nil {
 		panic(err)
 	}
 }
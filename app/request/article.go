@@ -1,5 +1,10 @@
 package request
 
+import (
+	"my_zhihu_backend/app/model"
+	"time"
+)
+
 type PostNewQuestionRequest struct {
 	Title   string `json:"title" binding:"required"`
 	Content string `json:"content" binding:"required"`
@@ -46,3 +51,66 @@ type ListCommentsRequest struct {
 	Page     int   `form:"page,default=1"`
 	Size     int   `form:"size,default=20"`
 }
+
+type InviteToAnswerRequest struct {
+	InviteeId model.UserId `json:"invitee_id" binding:"required"`
+}
+
+type SuggestInviteesRequest struct {
+	Size int `form:"size,default=10" binding:"min=1,max=50"`
+}
+
+type UpdateQuestionStatusRequest struct {
+	Status      model.QuestionStatus `json:"status" binding:"oneof=0 1 2 3"`
+	DuplicateOf *int64               `json:"duplicate_of,omitempty"` // 标记为重复问题时必填
+}
+
+type AcceptAnswerRequest struct {
+	AnswerId int64 `json:"answer_id" binding:"required"`
+}
+
+type ListRevisionsRequest struct {
+	Page int `form:"page,default=1" binding:"min=1"`
+	Size int `form:"size,default=20" binding:"min=1,max=100"`
+}
+
+const (
+	DiffModeLine = "line"
+	DiffModeChar = "char"
+)
+
+type DiffRevisionsRequest struct {
+	From int    `form:"from" binding:"required,min=1"`
+	To   int    `form:"to" binding:"required,min=1"`
+	Mode string `form:"mode,default=line" binding:"oneof=line char"`
+}
+
+type SaveQuestionDraftRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+type AutosaveQuestionDraftRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Version int    `json:"version" binding:"required,min=1"` // 上一次保存得到的版本号
+}
+
+type SaveAnswerDraftRequest struct {
+	QuestionId int64  `json:"question_id" binding:"required"`
+	Content    string `json:"content"`
+}
+
+type AutosaveAnswerDraftRequest struct {
+	Content string `json:"content"`
+	Version int    `json:"version" binding:"required,min=1"`
+}
+
+type PublishDraftRequest struct {
+	PublishAt *time.Time `json:"publish_at,omitempty"` // 为空或早于当前时间时立即发布
+}
+
+type ListDraftsRequest struct {
+	Page int `form:"page,default=1" binding:"min=1"`
+	Size int `form:"size,default=20" binding:"min=1,max=100"`
+}
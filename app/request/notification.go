@@ -0,0 +1,7 @@
+package request
+
+type ListNotificationsRequest struct {
+	Page       int  `form:"page,default=1" binding:"min=1"`
+	Size       int  `form:"size,default=20" binding:"min=1,max=100"`
+	UnreadOnly bool `form:"unread_only"`
+}
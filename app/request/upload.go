@@ -0,0 +1,6 @@
+package request
+
+type DownloadUploadRequest struct {
+	Expires int64  `form:"expires" binding:"min=0"` // 0 表示链接不过期
+	Sig     string `form:"sig" binding:"required,hexadecimal"`
+}
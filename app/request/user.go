@@ -13,12 +13,20 @@ type CreateNewUserRequest struct {
 }
 
 type UserOtherInfoRequest struct {
-	Introduction *string `json:"introduction" binding:"omitempty"`
-	Icon         *string `json:"icon" binding:"omitempty"`
+	Introduction *string           `json:"introduction" binding:"omitempty"`
+	Icon         *string           `json:"icon" binding:"omitempty"`
+	IconSizes    map[string]string `json:"-"` // 只能由头像上传接口设置
 }
 
+// UserSettings 各字段的可见范围 0 所有人 1 关注了我的用户 2 互相关注的用户 3 仅自己
 type UserSettings struct {
-	HidePrivacy *bool `json:"hide_privacy" binding:"omitempty"`
+	HidePrivacy          *bool             `json:"hide_privacy" binding:"omitempty"` // 已废弃 true 时所有字段仅自己可见 false 时全部公开 单独设置的字段优先
+	EmailVisibility      *model.Visibility `json:"email_visibility" binding:"omitempty,oneof=0 1 2 3"`
+	GenderVisibility     *model.Visibility `json:"gender_visibility" binding:"omitempty,oneof=0 1 2 3"`
+	RegionVisibility     *model.Visibility `json:"region_visibility" binding:"omitempty,oneof=0 1 2 3"`
+	FollowersVisibility  *model.Visibility `json:"followers_visibility" binding:"omitempty,oneof=0 1 2 3"`
+	FollowingsVisibility *model.Visibility `json:"followings_visibility" binding:"omitempty,oneof=0 1 2 3"`
+	ActivityVisibility   *model.Visibility `json:"activity_visibility" binding:"omitempty,oneof=0 1 2 3"`
 }
 
 // UpdateUserRequest 用于更新用户信息
@@ -33,5 +41,42 @@ type UpdateUserRequest struct {
 
 // SearchUserRequest 用于搜索用户
 type SearchUserRequest struct {
-	Username string `form:"username" binding:"required"`
+	Username string `form:"username" binding:"required,max=50"`
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	Size     int    `form:"size,default=20" binding:"min=1,max=100"`
+}
+
+// AutocompleteUserRequest 用户名前缀补全
+type AutocompleteUserRequest struct {
+	Prefix string `form:"prefix" binding:"required,max=50"`
+	Size   int    `form:"size,default=10" binding:"min=1,max=20"`
+}
+
+// ListFollowsRequest 粉丝和关注列表的游标分页
+type ListFollowsRequest struct {
+	Cursor  string `form:"cursor"` // 上一页返回的 next_cursor 为空时从最新开始
+	Size    int    `form:"size,default=20" binding:"min=1,max=100"`
+	Hydrate bool   `form:"hydrate"` // 是否返回用户摘要
+}
+
+// ListActivitiesRequest 用户动态的游标分页 Types 为空时返回全部类型
+type ListActivitiesRequest struct {
+	Cursor string   `form:"cursor"`
+	Size   int      `form:"size,default=20" binding:"min=1,max=100"`
+	Types  []string `form:"type" binding:"dive,oneof=ask answer follow_user watch_question"`
+}
+
+// ListRelatedUsersRequest 共同粉丝、推荐关注等只返回前若干个用户的列表
+type ListRelatedUsersRequest struct {
+	Size int `form:"size,default=10" binding:"min=1,max=50"`
+}
+
+// BatchGetUsersRequest 批量获取用户信息
+type BatchGetUsersRequest struct {
+	Ids []model.UserId `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// UpdateHandleRequest 设置或修改 handle
+type UpdateHandleRequest struct {
+	Handle string `json:"handle" binding:"required"`
 }
@@ -1,5 +1,10 @@
 package response
 
+import (
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/util"
+)
+
 type ArticleSearchResult struct {
 	ID    int64   `json:"id"`
 	Part  string  `json:"part"` // 部分内容
@@ -8,12 +13,28 @@ type ArticleSearchResult struct {
 }
 
 type QuestionResponse struct {
-	ID          int64  `json:"id"`
-	Title       string `json:"title"`
-	Content     string `json:"content"`
-	AuthorId    int64  `json:"author_id"`
-	IsAvailable bool   `json:"is_available"`
-	UpdatedAt   string `json:"updated_at"`
+	ID               int64                `json:"id"`
+	Title            string               `json:"title"`
+	Content          string               `json:"content"`      // 原始 Markdown
+	ContentHTML      string               `json:"content_html"` // 服务端渲染并过滤后的 HTML
+	Mentions         []MentionResponse    `json:"mentions"`
+	AuthorId         int64                `json:"author_id"`
+	IsAvailable      bool                 `json:"is_available"`
+	Status           model.QuestionStatus `json:"status"`
+	DuplicateOf      *int64               `json:"duplicate_of,omitempty"`
+	AcceptedAnswerId *int64               `json:"accepted_answer_id,omitempty"`
+	IsDraft          bool                 `json:"is_draft"`
+	Version          int                  `json:"version"`
+	ScheduledAt      string               `json:"scheduled_at,omitempty"`
+	UpdatedAt        string               `json:"updated_at"`
+}
+
+// MentionResponse 内容中的 @username 位置按字符(rune)计算 不包含 End
+type MentionResponse struct {
+	UserId   model.UserId `json:"user_id"`
+	Username string       `json:"username"`
+	Start    int          `json:"start"`
+	End      int          `json:"end"`
 }
 
 type ArticleSearchResponse struct {
@@ -22,3 +43,64 @@ type ArticleSearchResponse struct {
 	Size    int                   `json:"size"`
 	Records []ArticleSearchResult `json:"records"`
 }
+
+type InvitationResponse struct {
+	ID         int64        `json:"id"`
+	QuestionId int64        `json:"question_id"`
+	InviterId  model.UserId `json:"inviter_id"`
+	InviteeId  model.UserId `json:"invitee_id"`
+	CreatedAt  string       `json:"created_at"`
+}
+
+type RevisionResponse struct {
+	Version   int          `json:"version"`
+	EditorId  model.UserId `json:"editor_id"`
+	Title     string       `json:"title,omitempty"`
+	Content   string       `json:"content"`
+	CreatedAt string       `json:"created_at"`
+}
+
+type ListRevisionsResponse struct {
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	Size    int                `json:"size"`
+	Records []RevisionResponse `json:"records"`
+}
+
+type RevisionDiffResponse struct {
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Mode    string           `json:"mode"`
+	Title   []util.DiffChunk `json:"title,omitempty"`
+	Content []util.DiffChunk `json:"content"`
+}
+
+type AnswerResponse struct {
+	ID          int64             `json:"id"`
+	QuestionId  int64             `json:"question_id"`
+	Content     string            `json:"content"`      // 原始 Markdown
+	ContentHTML string            `json:"content_html"` // 服务端渲染并过滤后的 HTML
+	Mentions    []MentionResponse `json:"mentions"`
+	AuthorId    int64             `json:"author_id"`
+	LikeCount   int               `json:"like_count"`
+	IsAvailable bool              `json:"is_available"`
+	IsDraft     bool              `json:"is_draft"`
+	Version     int               `json:"version"`
+	ScheduledAt string            `json:"scheduled_at,omitempty"`
+	CreatedAt   string            `json:"created_at"`
+	UpdatedAt   string            `json:"updated_at"`
+}
+
+type ListQuestionDraftsResponse struct {
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	Size    int                `json:"size"`
+	Records []QuestionResponse `json:"records"`
+}
+
+type ListAnswerDraftsResponse struct {
+	Total   int64            `json:"total"`
+	Page    int              `json:"page"`
+	Size    int              `json:"size"`
+	Records []AnswerResponse `json:"records"`
+}
@@ -0,0 +1,21 @@
+package response
+
+import "my_zhihu_backend/app/model"
+
+type NotificationResponse struct {
+	ID         int64                  `json:"id"`
+	Type       model.NotificationType `json:"type"`
+	ActorId    model.UserId           `json:"actor_id"`
+	QuestionId int64                  `json:"question_id,omitempty"`
+	AnswerId   int64                  `json:"answer_id,omitempty"`
+	CommentId  int64                  `json:"comment_id,omitempty"`
+	IsRead     bool                   `json:"is_read"`
+	CreatedAt  string                 `json:"created_at"`
+}
+
+type ListNotificationsResponse struct {
+	Total   int64                  `json:"total"`
+	Page    int                    `json:"page"`
+	Size    int                    `json:"size"`
+	Records []NotificationResponse `json:"records"`
+}
@@ -0,0 +1,37 @@
+package response
+
+type BreakerStatusResponse struct {
+	Name     string `json:"name"`
+	State    string `json:"state"` // closed open half-open
+	Failures int    `json:"failures"`
+	OpenedAt string `json:"opened_at,omitempty"` // 最近一次熔断的时间
+}
+
+type CacheStatusResponse struct {
+	Backend      string                `json:"backend"`
+	Breaker      BreakerStatusResponse `json:"breaker"`
+	BloomFilters map[string]bool       `json:"bloom_filters"` // 各个布隆过滤器的 RedisBloom 模块是否可用
+}
+
+type KeyTTLResponse struct {
+	Key string `json:"key"`
+	TTL int64  `json:"ttl"` // 剩余有效期(秒) -1 表示没有设置有效期
+}
+
+type CacheStatsItemResponse struct {
+	Prefix          string           `json:"prefix"`
+	Hits            int64            `json:"hits"`
+	LocalHits       int64            `json:"local_hits"` // 进程内缓存的命中 同时计入 hits
+	Misses          int64            `json:"misses"`
+	HitRate         float64          `json:"hit_rate"`
+	BloomRejections int64            `json:"bloom_rejections"`
+	Fallbacks       int64            `json:"fallbacks"`
+	FallbackAvgMs   float64          `json:"fallback_avg_ms"`
+	Shared          int64            `json:"shared"` // singleflight 共享的结果数
+	Errors          int64            `json:"errors"`
+	Samples         []KeyTTLResponse `json:"samples"`
+}
+
+type CacheStatsResponse struct {
+	Prefixes []CacheStatsItemResponse `json:"prefixes"`
+}
@@ -0,0 +1,11 @@
+package response
+
+type UploadResponse struct {
+	ID          int64  `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Hash        string `json:"hash"` // 文件内容的 sha256
+	URL         string `json:"url"`  // 带签名的下载链接
+	CreatedAt   string `json:"created_at"`
+}
@@ -5,13 +5,88 @@ import "my_zhihu_backend/app/model"
 type UserResponse struct {
 	Id       model.UserId          `json:"id"`
 	Username string                `json:"username"`
+	Handle   string                `json:"handle,omitempty"`
 	Email    string                `json:"email"`
 	Gender   model.UserGender      `json:"gender"`
 	Region   string                `json:"region"`
 	Other    UserOtherInfoResponse `json:"other"`
+	Settings *UserSettingsResponse `json:"settings,omitempty"` // 只在查看自己的资料时返回
+}
+
+// UserSettingsResponse 各字段的可见范围 0 所有人 1 关注了我的用户 2 互相关注的用户 3 仅自己
+type UserSettingsResponse struct {
+	EmailVisibility      model.Visibility `json:"email_visibility"`
+	GenderVisibility     model.Visibility `json:"gender_visibility"`
+	RegionVisibility     model.Visibility `json:"region_visibility"`
+	FollowersVisibility  model.Visibility `json:"followers_visibility"`
+	FollowingsVisibility model.Visibility `json:"followings_visibility"`
+	ActivityVisibility   model.Visibility `json:"activity_visibility"`
 }
 
 type UserOtherInfoResponse struct {
-	Introduction string `json:"introduction"`
-	Icon         string `json:"icon"`
+	Introduction string            `json:"introduction"`
+	Icon         string            `json:"icon"`
+	IconSizes    map[string]string `json:"icon_sizes,omitempty"`
+}
+
+// UserSummaryResponse 列表中展示的用户摘要
+type UserSummaryResponse struct {
+	Id           model.UserId      `json:"id"`
+	Username     string            `json:"username"`
+	Handle       string            `json:"handle,omitempty"`
+	Introduction string            `json:"introduction"`
+	Icon         string            `json:"icon"`
+	IconSizes    map[string]string `json:"icon_sizes,omitempty"`
+}
+
+type FollowEntryResponse struct {
+	UserId     model.UserId         `json:"user_id"`
+	FollowedAt string               `json:"followed_at"`
+	IFollow    bool                 `json:"i_follow"`   // 当前用户关注了该用户
+	FollowsMe  bool                 `json:"follows_me"` // 该用户关注了当前用户
+	User       *UserSummaryResponse `json:"user,omitempty"`
+}
+
+type ListFollowsResponse struct {
+	Records    []FollowEntryResponse `json:"records"`
+	NextCursor string                `json:"next_cursor,omitempty"` // 为空时没有更多数据
+}
+
+// ActivityResponse 用户动态 按 Type 返回 question_id answer_id target_user_id 中的一部分
+type ActivityResponse struct {
+	Id           int64        `json:"id"`
+	Type         string       `json:"type"`
+	CreatedAt    string       `json:"created_at"`
+	QuestionId   int64        `json:"question_id,omitempty"`
+	AnswerId     int64        `json:"answer_id,omitempty"`
+	TargetUserId model.UserId `json:"target_user_id,omitempty"`
+}
+
+type ListActivitiesResponse struct {
+	Records    []ActivityResponse `json:"records"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// UserSampleResponse 共同粉丝等只展示部分用户的列表 Count 为总数
+type UserSampleResponse struct {
+	Count int64                 `json:"count"`
+	Users []UserSummaryResponse `json:"users"`
+}
+
+type RecommendedUserResponse struct {
+	User    UserSummaryResponse `json:"user"`
+	Overlap int                 `json:"overlap"` // 我关注的用户中关注了该用户的人数
+}
+
+type ListUsersResponse struct {
+	Total   int64                 `json:"total"`
+	Page    int                   `json:"page"`
+	Size    int                   `json:"size"`
+	Records []UserSummaryResponse `json:"records"`
+}
+
+// HandleLookupResponse 通过 handle 查找用户 Moved 为 true 时 handle 是用户以前使用过的 客户端应跳转到 User.Handle
+type HandleLookupResponse struct {
+	User  UserResponse `json:"user"`
+	Moved bool         `json:"moved"`
 }
@@ -17,5 +17,38 @@ func InitArticleRouter(r *gin.Engine, articleController *controller.ArticleContr
 		q.PATCH("/:id", articleController.UpdateQuestion)
 		q.GET("/:id", articleController.GetQuestion)
 		q.GET("", articleController.ListQuestions)
+		q.PATCH("/:id/status", articleController.UpdateQuestionStatus)
+		q.PUT("/:id/accepted-answer", articleController.AcceptAnswer)
+		q.DELETE("/:id/accepted-answer", articleController.UnacceptAnswer)
+		q.GET("/:id/revisions", articleController.ListQuestionRevisions)
+		q.GET("/:id/revisions/diff", articleController.DiffQuestionRevisions)
+		q.POST("/:id/revisions/:version/rollback", articleController.RollbackQuestion)
+		q.POST("/:id/watch", articleController.WatchQuestion)
+		q.DELETE("/:id/watch", articleController.UnwatchQuestion)
+		q.POST("/:id/invitations", articleController.InviteToAnswer)
+		q.GET("/:id/invitations/suggestions", articleController.SuggestInvitees)
+	}
+
+	a := r.Group("/answers")
+	a.Use(middleware.Auth(authService))
+	{
+		a.GET("/:id/revisions", articleController.ListAnswerRevisions)
+		a.GET("/:id/revisions/diff", articleController.DiffAnswerRevisions)
+		a.POST("/:id/revisions/:version/rollback", articleController.RollbackAnswer)
+	}
+
+	d := r.Group("/drafts")
+	d.Use(middleware.Auth(authService))
+	{
+		d.POST("/questions", articleController.CreateQuestionDraft)
+		d.GET("/questions", articleController.ListQuestionDrafts)
+		d.PUT("/questions/:id", articleController.AutosaveQuestionDraft)
+		d.POST("/questions/:id/publish", articleController.PublishQuestionDraft)
+		d.DELETE("/questions/:id/schedule", articleController.CancelQuestionSchedule)
+		d.POST("/answers", articleController.CreateAnswerDraft)
+		d.GET("/answers", articleController.ListAnswerDrafts)
+		d.PUT("/answers/:id", articleController.AutosaveAnswerDraft)
+		d.POST("/answers/:id/publish", articleController.PublishAnswerDraft)
+		d.DELETE("/answers/:id/schedule", articleController.CancelAnswerSchedule)
 	}
 }
@@ -0,0 +1,19 @@
+package router
+
+import (
+	"my_zhihu_backend/app/controller"
+	"my_zhihu_backend/app/middleware"
+	"my_zhihu_backend/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitNotificationRouter(r *gin.Engine, ctrl *controller.NotificationController, authService *service.AuthService) {
+	n := r.Group("/notifications")
+	n.Use(middleware.Auth(authService))
+	{
+		n.GET("", ctrl.ListNotifications) // 获取通知列表
+		n.PATCH("", ctrl.MarkAllRead)     // 全部标为已读
+		n.PATCH("/:id", ctrl.MarkRead)    // 单条标为已读
+	}
+}
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"my_zhihu_backend/app/controller"
+	"my_zhihu_backend/app/middleware"
+	"my_zhihu_backend/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitSystemRouter(r *gin.Engine, ctrl *controller.SystemController, authService *service.AuthService) {
+	s := r.Group("/system")
+	s.Use(middleware.Auth(authService))
+	{
+		s.GET("/cache", ctrl.CacheStatus)      // 缓存状态
+		s.GET("/cache/stats", ctrl.CacheStats) // 缓存计数
+	}
+}
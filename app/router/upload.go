@@ -0,0 +1,19 @@
+package router
+
+import (
+	"my_zhihu_backend/app/controller"
+	"my_zhihu_backend/app/middleware"
+	"my_zhihu_backend/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitUploadRouter(r *gin.Engine, ctrl *controller.UploadController, authService *service.AuthService) {
+	uploads := r.Group("/uploads")
+	{
+		uploads.POST("", middleware.Auth(authService), ctrl.Upload)             // 上传文件
+		uploads.GET("/:id", ctrl.Download)                                      // 通过签名链接下载文件
+		uploads.GET("/:id/url", middleware.Auth(authService), ctrl.GetURL)      // 重新获取下载链接
+		uploads.DELETE("/:id", middleware.Auth(authService), ctrl.DeleteUpload) // 删除上传
+	}
+}
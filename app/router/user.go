@@ -12,16 +12,29 @@ import (
 
 func InitUsersRouter(r *gin.Engine, ctrl *controller.UserController, service *service.AuthService, client *redis.Client) {
 	cache := middleware.CacheQuery(client, config.C().Prefix.UserSearchPrefix, "user-search-filter")
+	// 用户信息和粉丝列表按当前用户区分 用户信息、设置和关注关系变化时通过标签清除
+	userCache := middleware.CacheQuery(client, config.C().Prefix.UserSearchPrefix, "user-search-filter", middleware.VaryByUser(), middleware.TagByParam("id"))
 	users := r.Group("/users")
 	{
-		users.POST("", ctrl.CreateNewUser)                                                // 创建用户
-		users.GET("", middleware.Auth(service), cache, ctrl.SearchUserByUsername)         // 搜索用户
-		users.GET("/:id", middleware.Auth(service), cache, ctrl.GetUser)                  // 获取用户信息
-		users.DELETE("/me", middleware.Auth(service), ctrl.DeleteUser)                    // 删除用户
-		users.PATCH("/me", middleware.Auth(service), ctrl.UpdateUser)                     // 更新用户信息
-		users.POST("/follow/:id", middleware.Auth(service), ctrl.AddFollowing)            // 关注用户
-		users.DELETE("/follow/:id", middleware.Auth(service), ctrl.RemoveFollowing)       // 取消关注用户
-		users.GET("/followers/:id", middleware.Auth(service), cache, ctrl.GetFollowers)   // 获取粉丝列表
-		users.GET("/followings/:id", middleware.Auth(service), cache, ctrl.GetFollowings) // 获取关注列表
+		users.POST("", ctrl.CreateNewUser)                                                    // 创建用户
+		users.GET("", middleware.Auth(service), cache, ctrl.SearchUserByUsername)             // 搜索用户
+		users.GET("/autocomplete", middleware.Auth(service), ctrl.AutocompleteUsername)       // 用户名自动补全
+		users.POST("/batch", middleware.Auth(service), ctrl.BatchGetUsers)                    // 批量获取用户信息
+		users.GET("/by-handle/:handle", middleware.Auth(service), ctrl.GetUserByHandle)       // 通过 handle 获取用户信息
+		users.GET("/:id", middleware.Auth(service), userCache, ctrl.GetUser)                  // 获取用户信息
+		users.DELETE("/me", middleware.Auth(service), ctrl.DeleteUser)                        // 删除用户
+		users.PATCH("/me", middleware.Auth(service), ctrl.UpdateUser)                         // 更新用户信息
+		users.PUT("/me/avatar", middleware.Auth(service), ctrl.UpdateAvatar)                  // 上传头像
+		users.PUT("/me/handle", middleware.Auth(service), ctrl.UpdateHandle)                  // 设置 handle
+		users.POST("/follow/:id", middleware.Auth(service), ctrl.AddFollowing)                // 关注用户
+		users.DELETE("/follow/:id", middleware.Auth(service), ctrl.RemoveFollowing)           // 取消关注用户
+		users.POST("/block/:id", middleware.Auth(service), ctrl.AddBlock)                     // 拉黑用户
+		users.DELETE("/block/:id", middleware.Auth(service), ctrl.RemoveBlock)                // 取消拉黑用户
+		users.GET("/followers/:id", middleware.Auth(service), userCache, ctrl.GetFollowers)   // 获取粉丝列表
+		users.GET("/followings/:id", middleware.Auth(service), userCache, ctrl.GetFollowings) // 获取关注列表
+		users.GET("/mutual-followers/:id", middleware.Auth(service), ctrl.GetMutualFollowers) // 共同粉丝
+		users.GET("/followed-by/:id", middleware.Auth(service), ctrl.GetFollowedBy)           // 我关注的人中关注了该用户的人
+		users.GET("/activities/:id", middleware.Auth(service), ctrl.GetActivities)            // 获取用户动态
+		users.GET("/recommendations", middleware.Auth(service), ctrl.GetRecommendations)      // 可能认识的人
 	}
 }
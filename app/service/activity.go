@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/dao"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/request"
+	"my_zhihu_backend/app/response"
+	"my_zhihu_backend/app/util"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// activityTypeNames 动态类型在接口中使用的名称
+var activityTypeNames = map[model.ActivityType]string{
+	model.ActivityAsk:           "ask",
+	model.ActivityAnswer:        "answer",
+	model.ActivityFollowUser:    "follow_user",
+	model.ActivityWatchQuestion: "watch_question",
+}
+
+func parseActivityTypes(names []string) []model.ActivityType {
+	var types []model.ActivityType
+	for t, name := range activityTypeNames {
+		for _, n := range names {
+			if n == name {
+				types = append(types, t)
+				break
+			}
+		}
+	}
+	return types
+}
+
+type ActivityService struct {
+	dao  *dao.ActivityDAO
+	util *util.Util
+}
+
+func NewActivityService(db *gorm.DB) *ActivityService {
+	return &ActivityService{dao: dao.NewActivityDAO(db), util: new(util.Util)}
+}
+
+// Record 在调用方的写入流程中同步记录动态 失败只记录日志 不影响主业务
+// 同步写入保证同一用户的操作和撤销按请求顺序生效 不会留下已撤销的动态
+func (service *ActivityService) Record(ctx context.Context, activity model.Activity) {
+	activity.ID = service.util.GenerateSnowflakeID()
+	if err := service.dao.CreateActivity(ctx, &activity); err != nil {
+		l.Error("failed to record activity", err.ErrorField()...)
+	}
+}
+
+// Remove 同步删除与 activity 中非零字段匹配的动态 用于撤销操作和删除内容 失败只记录日志
+func (service *ActivityService) Remove(ctx context.Context, activities ...model.Activity) {
+	for _, activity := range activities {
+		if err := service.dao.DeleteActivities(ctx, activity); err != nil {
+			l.Error("failed to remove activity", err.ErrorField()...)
+		}
+	}
+}
+
+// ListActivities 游标分页获取用户的动态 可见范围由用户的 ActivityVisibility 设置决定
+func (service *UserService) ListActivities(ctx context.Context, viewerId, id model.UserId, req *request.ListActivitiesRequest) (*response.ListActivitiesResponse, app_error.AppError) {
+	if err := service.CheckVisible(ctx, viewerId, id, model.PrivacyActivity); err != nil {
+		return nil, err
+	}
+
+	var beforeAt *time.Time
+	var beforeId int64
+	if req.Cursor != "" {
+		t, cid, err := util.DecodeCursor(req.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor.WithError(err)
+		}
+		beforeAt, beforeId = &t, cid
+	}
+	activities, err := service.activities.dao.ListActivities(ctx, id, parseActivityTypes(req.Types), beforeAt, beforeId, req.Size+1)
+	if err != nil {
+		return nil, err
+	}
+	var nextCursor string
+	if len(activities) > req.Size {
+		activities = activities[:req.Size]
+		last := activities[len(activities)-1]
+		nextCursor = util.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	records := make([]response.ActivityResponse, 0, len(activities))
+	for _, a := range activities {
+		records = append(records, response.ActivityResponse{
+			Id:           a.ID,
+			Type:         activityTypeNames[a.Type],
+			CreatedAt:    a.CreatedAt.Format(time.DateTime),
+			QuestionId:   a.QuestionId,
+			AnswerId:     a.AnswerId,
+			TargetUserId: a.TargetUserId,
+		})
+	}
+	return &response.ListActivitiesResponse{Records: records, NextCursor: nextCursor}, nil
+}
@@ -2,25 +2,85 @@ package service
 
 import (
 	"context"
+	"errors"
 	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/cache"
+	"my_zhihu_backend/app/config"
 	"my_zhihu_backend/app/dao"
+	"my_zhihu_backend/app/log"
 	"my_zhihu_backend/app/model"
 	"my_zhihu_backend/app/request"
 	"my_zhihu_backend/app/response"
 	"my_zhihu_backend/app/util"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+var al = log.L().With(zap.String("module", "article service"))
+
 type ArticleService struct {
-	dao  *dao.ArticleDAO
-	util *util.Util
+	dao          *dao.ArticleDAO
+	uDAO         *dao.UserDAO
+	notification *NotificationService
+	activities   *ActivityService
+	uploadDAO    *dao.UploadDAO
+	mentionDAO   *dao.MentionDAO
+	renderer     *MarkdownRenderer
+	cfg          config.ReadConfigFunc
+	util         *util.Util
+
+	questionCacher cache.Cacher[model.Question] // 问题详情的缓存 不包括草稿 见 question_cache.go
+	questionBloom  cache.BloomFilter
+	// questionBloomPopulated 布隆过滤器是否已经写入所有问题 返回 false 时被拦截的请求仍然查询数据库
+	// memory 存储下在提供服务前填充 redis 存储下由 RegisterBloomSources 替换为 BloomManager 的状态
+	questionBloomPopulated func() bool
+
+	viewsMu sync.Mutex
+	views   map[int64]int64 // 还没有写入数据库的浏览次数 见 RunViewFlusher
 }
 
-func NewArticleService(db *gorm.DB) *ArticleService {
+func NewArticleService(db *gorm.DB, client *redis.Client) *ArticleService {
+	cfg := config.C
 	aDAO := dao.NewArticleDAO(db)
+	uDAO := dao.NewUserDAO(cfg, db)
 	u := new(util.Util)
-	return &ArticleService{aDAO, u}
+	backend := cache.Backend(cfg().Service.CacheBackend)
+	a := &ArticleService{
+		dao:           aDAO,
+		uDAO:          uDAO,
+		notification:  NewNotificationService(db),
+		activities:    NewActivityService(db),
+		uploadDAO:     dao.NewUploadDAO(db),
+		mentionDAO:    dao.NewMentionDAO(db),
+		renderer:      NewMarkdownRenderer(backend, client, cfg().Prefix.RenderedContentPrefix),
+		cfg:           cfg,
+		util:          u,
+		questionBloom: cache.NewBloomFilterOn(backend, questionBloomFilterName, client),
+	}
+	a.questionBloomPopulated = func() bool { return true }
+	a.views = make(map[int64]int64)
+	a.questionCacher = cache.NewJsonCacherOn(backend, client, questionCacheTTL, cfg().Prefix.QuestionInfoPrefix, a.loadQuestion,
+		a.questionBloom, cache.WithCodec(cache.Msgpack), cache.WithVersion(questionCacheVersion))
+	return a
+}
+
+// RenderContent 将问题或回答的 Markdown 内容渲染为安全的 HTML
+func (a *ArticleService) RenderContent(ctx context.Context, content string) string {
+	return a.renderer.Render(ctx, content)
+}
+
+// bindUploads 标记内容中引用的作者自己的上传 其他用户的上传不会因为被引用而保留
+// 失败时只记录日志 未标记的上传在保留期后才会被清理
+func (a *ArticleService) bindUploads(ctx context.Context, authorId model.UserId, contents ...string) {
+	ids := uploadIdsInContent(a.cfg().Service.UploadURLPrefix, contents...)
+	if err := a.uploadDAO.BindUploads(ctx, authorId, ids); err != nil {
+		al.Error("failed to bind uploads", err.ErrorField()...)
+	}
 }
 
 func (a *ArticleService) PostNewQuestion(ctx context.Context, userId model.UserId, req *request.PostNewQuestionRequest) (*model.Question, app_error.AppError) {
@@ -35,23 +95,54 @@ func (a *ArticleService) PostNewQuestion(ctx context.Context, userId model.UserI
 	if err != nil {
 		return nil, err
 	}
+	a.bindUploads(ctx, model.UserId(question.AuthorId), question.Content)
+	a.saveQuestionMentions(ctx, question)
+	a.recordQuestion(ctx, question)
+	a.cacheQuestion(ctx, question)
 	return question, nil
 }
 
 func (a *ArticleService) UpdateQuestion(ctx context.Context, userId model.UserId, questionId int64, req *request.UpdateQuestionRequest) (*model.Question, app_error.AppError) {
-	updateData := map[string]interface{}{}
+	question, err := a.dao.GetQuestion(ctx, questionId)
+	if err != nil {
+		return nil, err
+	}
+	if question.IsDraft {
+		return nil, app_error.ErrQuestionNotFound
+	}
+	if question.AuthorId != int64(userId) {
+		return nil, app_error.ErrUserPermissionDenied
+	}
+	if question.Status == model.QuestionStatusLocked {
+		return nil, app_error.ErrQuestionLocked
+	}
+
+	title, content := question.Title, question.Content
 	if req.Title != "" {
-		updateData["title"] = req.Title
+		title = req.Title
 	}
 	if req.Body != "" {
-		updateData["body"] = req.Body
+		content = req.Body
 	}
 
-	return a.dao.UpdateQuestion(ctx, int64(userId), questionId, updateData)
+	updated, err := a.dao.UpdateQuestion(ctx, userId, questionId, title, content)
+	if err != nil {
+		return nil, err
+	}
+	a.bindUploads(ctx, model.UserId(updated.AuthorId), updated.Content)
+	a.saveQuestionMentions(ctx, updated)
+	a.invalidateQuestion(ctx, questionId)
+	return updated, nil
 }
 
+// DeleteQuestion 删除问题 同时删除与问题相关的所有动态
 func (a *ArticleService) DeleteQuestion(ctx context.Context, userId model.UserId, questionId int64) app_error.AppError {
-	return a.dao.DeleteQuestion(ctx, int64(userId), questionId)
+	if err := a.dao.DeleteQuestion(ctx, int64(userId), questionId); err != nil {
+		return err
+	}
+	a.activities.Remove(ctx, model.Activity{QuestionId: questionId})
+	a.invalidateQuestion(ctx, questionId)
+	return nil
 }
 
 func (a *ArticleService) GetQuestion(ctx context.Context, questionId int64) (*model.Question, app_error.AppError) {
@@ -59,20 +150,36 @@ func (a *ArticleService) GetQuestion(ctx context.Context, questionId int64) (*mo
 	if err != nil {
 		return nil, err
 	}
+	if q.IsDraft { // 草稿只能通过草稿接口访问
+		return nil, app_error.ErrQuestionNotFound
+	}
 	if !q.IsAvailable {
 		return nil, app_error.ErrUserPermissionDenied
 	}
 	return q, nil
 }
 
+// GetAndIncrQuestion 通过缓存读取问题详情 同时浏览次数加一 浏览次数先在内存中累计 由 RunViewFlusher 定时写入数据库
+// 浏览次数只用于预热 不出现在缓存的问题中 内部的权限检查使用 GetQuestion 直接读取数据库
 func (a *ArticleService) GetAndIncrQuestion(ctx context.Context, questionId int64) (*model.Question, app_error.AppError) {
-	q, err := a.dao.GetQuestion(ctx, questionId)
+	q, err := a.questionCacher.Get(ctx, questionKey(questionId), questionId)
 	if err != nil {
-		return nil, err
+		if !errors.Is(err, app_error.ErrRedisCacheKeyNotExists) {
+			return nil, err
+		}
+		// 被布隆过滤器拦截 过滤器还没有写入所有问题时再查询数据库
+		if a.questionBloomPopulated() {
+			return nil, app_error.ErrQuestionNotFound
+		}
+		if q, err = a.loadQuestion(ctx, questionId); err != nil {
+			return nil, err
+		}
+		a.cacheQuestion(ctx, q)
 	}
 	if !q.IsAvailable {
 		return nil, app_error.ErrUserPermissionDenied
 	}
+	a.countView(questionId)
 	return q, nil
 }
 
@@ -86,10 +193,13 @@ func (a *ArticleService) ListQuestions(ctx context.Context, page, size int, keyw
 
 func (a *ArticleService) PostNewAnswer(ctx context.Context, userId model.UserId, req *request.PostNewAnswerRequest) (*model.Answer, app_error.AppError) {
 	// 检查问题是否存在
-	_, err := a.dao.GetQuestion(ctx, req.QuestionId)
+	question, err := a.dao.GetQuestion(ctx, req.QuestionId)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkAcceptingAnswers(question); err != nil {
+		return nil, err
+	}
 
 	answer := &model.Answer{
 		ID:          a.util.GenerateSnowflakeID(),
@@ -103,15 +213,145 @@ func (a *ArticleService) PostNewAnswer(ctx context.Context, userId model.UserId,
 	if err != nil {
 		return nil, err
 	}
+	a.bindUploads(ctx, model.UserId(answer.AuthorId), answer.Content)
+	a.saveAnswerMentions(ctx, answer)
+	a.notifyNewAnswer(ctx, question, answer)
+	a.recordAnswer(ctx, answer)
 	return answer, nil
 }
 
-func (a *ArticleService) UpdateAnswer(ctx context.Context, userId model.UserId, answerId int64, req *request.UpdateAnswerRequest) app_error.AppError {
-	return a.dao.UpdateAnswer(ctx, int64(userId), answerId, req.Content)
+// recordQuestion 记录提问动态
+func (a *ArticleService) recordQuestion(ctx context.Context, question *model.Question) {
+	a.activities.Record(ctx, model.Activity{UserId: model.UserId(question.AuthorId), Type: model.ActivityAsk, QuestionId: question.ID})
+}
+
+// recordAnswer 记录回答动态
+func (a *ArticleService) recordAnswer(ctx context.Context, answer *model.Answer) {
+	a.activities.Record(ctx, model.Activity{UserId: model.UserId(answer.AuthorId), Type: model.ActivityAnswer, QuestionId: answer.QuestionId, AnswerId: answer.ID})
+}
+
+// checkAcceptingAnswers 检查问题是否还能接受新回答
+func checkAcceptingAnswers(question *model.Question) app_error.AppError {
+	if question.IsDraft {
+		return app_error.ErrQuestionNotFound
+	}
+	switch question.Status {
+	case model.QuestionStatusOpen:
+		return nil
+	case model.QuestionStatusLocked:
+		return app_error.ErrQuestionLocked
+	default: // 关闭和重复的问题不再接受新回答
+		return app_error.ErrQuestionClosed
+	}
 }
 
+// notifyNewAnswer 通知提问者和关注问题的用户 回答者本人不会收到通知
+func (a *ArticleService) notifyNewAnswer(ctx context.Context, question *model.Question, answer *model.Answer) {
+	watchers, err := a.dao.ListQuestionWatchers(ctx, question.ID)
+	if err != nil {
+		al.Error("failed to list question watchers", err.ErrorField()...)
+		return
+	}
+	recipients := append(watchers, model.UserId(question.AuthorId))
+	notified := make(map[model.UserId]struct{}, len(recipients))
+	notifications := make([]model.Notification, 0, len(recipients))
+	for _, id := range recipients {
+		if _, ok := notified[id]; ok || id == model.UserId(answer.AuthorId) {
+			continue
+		}
+		notified[id] = struct{}{}
+		notifications = append(notifications, model.Notification{
+			UserId:     id,
+			Type:       model.NotificationNewAnswer,
+			ActorId:    model.UserId(answer.AuthorId),
+			QuestionId: question.ID,
+			AnswerId:   answer.ID,
+		})
+	}
+	a.notification.Notify(notifications...)
+}
+
+// getAnswer 获取已发布的回答 草稿视为不存在
+func (a *ArticleService) getAnswer(ctx context.Context, answerId int64) (*model.Answer, app_error.AppError) {
+	answer, err := a.dao.GetAnswer(ctx, answerId)
+	if err != nil {
+		return nil, err
+	}
+	if answer.IsDraft {
+		return nil, app_error.ErrAnswerNotFound
+	}
+	return answer, nil
+}
+
+func (a *ArticleService) UpdateAnswer(ctx context.Context, userId model.UserId, answerId int64, req *request.UpdateAnswerRequest) (*model.Answer, app_error.AppError) {
+	answer, err := a.getAnswer(ctx, answerId)
+	if err != nil {
+		return nil, err
+	}
+	if answer.AuthorId != int64(userId) {
+		return nil, app_error.ErrUserPermissionDenied
+	}
+	if err := a.checkAnswerUnlocked(ctx, userId, answer); err != nil {
+		return nil, err
+	}
+	updated, err := a.dao.UpdateAnswer(ctx, userId, answerId, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	a.bindUploads(ctx, model.UserId(updated.AuthorId), updated.Content)
+	a.saveAnswerMentions(ctx, updated)
+	return updated, nil
+}
+
+// DeleteAnswer 删除自己的回答 被采纳的回答删除后问题变为未采纳
 func (a *ArticleService) DeleteAnswer(ctx context.Context, userId model.UserId, answerId int64) app_error.AppError {
-	return a.dao.DeleteAnswer(ctx, int64(userId), answerId)
+	answer, err := a.dao.GetAnswer(ctx, answerId)
+	if err != nil {
+		return err
+	}
+	if answer.AuthorId != int64(userId) {
+		return app_error.ErrUserPermissionDenied
+	}
+	if err := a.checkAnswerUnlocked(ctx, userId, answer); err != nil {
+		return err
+	}
+	unaccepted, err := a.dao.DeleteAnswer(ctx, int64(userId), answerId)
+	if err != nil {
+		return err
+	}
+	if unaccepted {
+		a.invalidateQuestion(ctx, answer.QuestionId)
+	}
+	a.activities.Remove(ctx, model.Activity{AnswerId: answerId})
+	return nil
+}
+
+// questionLocked 回答所在的问题是否处于锁定状态 问题已删除时视为没有锁定
+func (a *ArticleService) questionLocked(ctx context.Context, questionId int64) (bool, app_error.AppError) {
+	question, err := a.dao.GetQuestion(ctx, questionId)
+	if err != nil {
+		if errors.Is(err, app_error.ErrQuestionNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return question.Status == model.QuestionStatusLocked, nil
+}
+
+// checkAnswerUnlocked 锁定的问题下的回答只有版主可以编辑和删除
+func (a *ArticleService) checkAnswerUnlocked(ctx context.Context, userId model.UserId, answer *model.Answer) app_error.AppError {
+	locked, err := a.questionLocked(ctx, answer.QuestionId)
+	if err != nil || !locked {
+		return err
+	}
+	moderator, err := a.isModerator(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		return app_error.ErrQuestionLocked
+	}
+	return nil
 }
 
 func (a *ArticleService) ListAnswers(ctx context.Context, questionId int64, page, size int) ([]model.Answer, int64, app_error.AppError) {
@@ -120,7 +360,7 @@ func (a *ArticleService) ListAnswers(ctx context.Context, questionId int64, page
 
 func (a *ArticleService) PostNewComment(ctx context.Context, userId model.UserId, req *request.PostNewCommentRequest) (*model.Comment, app_error.AppError) {
 	// 检查答案是否存在
-	_, err := a.dao.GetAnswer(ctx, req.AnswerId)
+	_, err := a.getAnswer(ctx, req.AnswerId)
 	if err != nil {
 		return nil, err
 	}
@@ -144,6 +384,7 @@ func (a *ArticleService) PostNewComment(ctx context.Context, userId model.UserId
 	if err != nil {
 		return nil, err
 	}
+	a.saveCommentMentions(ctx, comment)
 	return comment, nil
 }
 
@@ -157,7 +398,12 @@ func (a *ArticleService) UpdateComment(ctx context.Context, userId model.UserId,
 		return app_error.ErrUserPermissionDenied
 	}
 
-	return a.dao.UpdateComment(ctx, int64(userId), commentId, req.Content)
+	if err := a.dao.UpdateComment(ctx, int64(userId), commentId, req.Content); err != nil {
+		return err
+	}
+	comment.Content = req.Content
+	a.saveCommentMentions(ctx, comment)
+	return nil
 }
 
 func (a *ArticleService) DeleteComment(ctx context.Context, userId model.UserId, commentId int64) app_error.AppError {
@@ -167,3 +413,296 @@ func (a *ArticleService) DeleteComment(ctx context.Context, userId model.UserId,
 func (a *ArticleService) ListComments(ctx context.Context, answerId int64, page, size int) ([]model.Comment, int64, app_error.AppError) {
 	return a.dao.ListComments(ctx, answerId, page, size)
 }
+
+// WatchQuestion 关注问题 有新回答时收到通知
+func (a *ArticleService) WatchQuestion(ctx context.Context, userId model.UserId, questionId int64) app_error.AppError {
+	if _, err := a.GetQuestion(ctx, questionId); err != nil {
+		return err
+	}
+	if err := a.dao.WatchQuestion(ctx, userId, questionId); err != nil {
+		return err
+	}
+	a.activities.Record(ctx, model.Activity{UserId: userId, Type: model.ActivityWatchQuestion, QuestionId: questionId})
+	return nil
+}
+
+// UnwatchQuestion 取消关注问题
+func (a *ArticleService) UnwatchQuestion(ctx context.Context, userId model.UserId, questionId int64) app_error.AppError {
+	if err := a.dao.UnwatchQuestion(ctx, userId, questionId); err != nil {
+		return err
+	}
+	a.activities.Remove(ctx, model.Activity{UserId: userId, Type: model.ActivityWatchQuestion, QuestionId: questionId})
+	return nil
+}
+
+// InviteToAnswer 邀请用户回答问题 只能邀请回答还在接受回答的问题
+// 每个用户 24 小时内的邀请数受 config.ServiceConfig.InvitationDailyLimit 限制
+func (a *ArticleService) InviteToAnswer(ctx context.Context, userId model.UserId, questionId int64, req *request.InviteToAnswerRequest) (*model.QuestionInvitation, app_error.AppError) {
+	question, err := a.GetQuestion(ctx, questionId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAcceptingAnswers(question); err != nil {
+		return nil, err
+	}
+	if req.InviteeId == userId || req.InviteeId == model.UserId(question.AuthorId) {
+		return nil, app_error.ErrInvalidInvitee
+	}
+	if _, err := a.uDAO.GetById(ctx, req.InviteeId); err != nil {
+		return nil, err
+	}
+	if blocked, err := a.uDAO.IsBlocked(ctx, userId, req.InviteeId); err != nil {
+		return nil, err
+	} else if blocked {
+		return nil, app_error.ErrUserBlocked
+	}
+
+	invitation := &model.QuestionInvitation{
+		ID:         a.util.GenerateSnowflakeID(),
+		QuestionId: questionId,
+		InviterId:  userId,
+		InviteeId:  req.InviteeId,
+	}
+	since := time.Now().Add(-24 * time.Hour)
+	if err := a.dao.CreateInvitation(ctx, invitation, since, a.cfg().Service.InvitationDailyLimit); err != nil {
+		return nil, err
+	}
+	a.notification.Notify(model.Notification{
+		UserId:     req.InviteeId,
+		Type:       model.NotificationInvitation,
+		ActorId:    userId,
+		QuestionId: questionId,
+	})
+	return invitation, nil
+}
+
+// SuggestInvitees 推荐可以邀请回答问题的用户
+func (a *ArticleService) SuggestInvitees(ctx context.Context, userId model.UserId, questionId int64, size int) ([]model.UserId, app_error.AppError) {
+	question, err := a.GetQuestion(ctx, questionId)
+	if err != nil {
+		return nil, err
+	}
+	return a.dao.ListInviteeSuggestions(ctx, question, userId, size)
+}
+
+// questionTransitions 问题状态机 key 为当前状态 value 为允许变更到的状态
+var questionTransitions = map[model.QuestionStatus][]model.QuestionStatus{
+	model.QuestionStatusOpen:      {model.QuestionStatusClosed, model.QuestionStatusDuplicate, model.QuestionStatusLocked},
+	model.QuestionStatusClosed:    {model.QuestionStatusOpen, model.QuestionStatusLocked},
+	model.QuestionStatusDuplicate: {model.QuestionStatusOpen, model.QuestionStatusLocked},
+	model.QuestionStatusLocked:    {model.QuestionStatusOpen},
+}
+
+func (a *ArticleService) isModerator(ctx context.Context, userId model.UserId) (bool, app_error.AppError) {
+	user, err := a.uDAO.GetById(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+	return user.Role == model.UserRoleModerator, nil
+}
+
+// UpdateQuestionStatus 变更问题状态 提问者和版主可以关闭、标记重复和重新打开问题 锁定和解锁只允许版主操作
+func (a *ArticleService) UpdateQuestionStatus(ctx context.Context, userId model.UserId, questionId int64, req *request.UpdateQuestionStatusRequest) (*model.Question, app_error.AppError) {
+	question, err := a.GetQuestion(ctx, questionId)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(questionTransitions[question.Status], req.Status) {
+		return nil, app_error.ErrInvalidStatusTransition
+	}
+
+	moderator, err := a.isModerator(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if question.Status == model.QuestionStatusLocked || req.Status == model.QuestionStatusLocked {
+		if !moderator {
+			return nil, app_error.ErrUserPermissionDenied
+		}
+	} else if !moderator && question.AuthorId != int64(userId) {
+		return nil, app_error.ErrUserPermissionDenied
+	}
+
+	var duplicateOfId *int64
+	if req.Status == model.QuestionStatusDuplicate {
+		if req.DuplicateOf == nil || *req.DuplicateOf == questionId {
+			return nil, app_error.ErrInvalidStatusTransition
+		}
+		if _, err := a.GetQuestion(ctx, *req.DuplicateOf); err != nil {
+			return nil, err
+		}
+		duplicateOfId = req.DuplicateOf
+	}
+
+	updated, err := a.dao.UpdateQuestionStatus(ctx, questionId, question.Status, req.Status, duplicateOfId)
+	if err != nil {
+		return nil, err
+	}
+	a.invalidateQuestion(ctx, questionId)
+	return updated, nil
+}
+
+// AcceptAnswer 提问者采纳回答 锁定的问题不允许变更采纳
+func (a *ArticleService) AcceptAnswer(ctx context.Context, userId model.UserId, questionId int64, req *request.AcceptAnswerRequest) (*model.Question, app_error.AppError) {
+	question, err := a.GetQuestion(ctx, questionId)
+	if err != nil {
+		return nil, err
+	}
+	if question.AuthorId != int64(userId) {
+		return nil, app_error.ErrUserPermissionDenied
+	}
+	if question.Status == model.QuestionStatusLocked {
+		return nil, app_error.ErrQuestionLocked
+	}
+
+	answer, err := a.getAnswer(ctx, req.AnswerId)
+	if err != nil {
+		return nil, err
+	}
+	if answer.QuestionId != questionId || !answer.IsAvailable {
+		return nil, app_error.ErrAnswerNotFound
+	}
+
+	return a.setAcceptedAnswer(ctx, userId, questionId, &answer.ID)
+}
+
+// UnacceptAnswer 提问者取消采纳
+func (a *ArticleService) UnacceptAnswer(ctx context.Context, userId model.UserId, questionId int64) (*model.Question, app_error.AppError) {
+	question, err := a.GetQuestion(ctx, questionId)
+	if err != nil {
+		return nil, err
+	}
+	if question.AuthorId != int64(userId) {
+		return nil, app_error.ErrUserPermissionDenied
+	}
+	if question.Status == model.QuestionStatusLocked {
+		return nil, app_error.ErrQuestionLocked
+	}
+
+	return a.setAcceptedAnswer(ctx, userId, questionId, nil)
+}
+
+func (a *ArticleService) setAcceptedAnswer(ctx context.Context, userId model.UserId, questionId int64, answerId *int64) (*model.Question, app_error.AppError) {
+	updated, err := a.dao.SetAcceptedAnswer(ctx, int64(userId), questionId, answerId)
+	if err != nil {
+		return nil, err
+	}
+	a.invalidateQuestion(ctx, questionId)
+	return updated, nil
+}
+
+// revisionTarget 检查修订对象是否存在 返回其作者以及是否处于锁定状态 回答按所在的问题判断是否锁定
+func (a *ArticleService) revisionTarget(ctx context.Context, target model.RevisionTarget, targetId int64) (authorId model.UserId, locked bool, err app_error.AppError) {
+	switch target {
+	case model.RevisionTargetQuestion:
+		question, err := a.GetQuestion(ctx, targetId)
+		if err != nil {
+			return 0, false, err
+		}
+		return model.UserId(question.AuthorId), question.Status == model.QuestionStatusLocked, nil
+	case model.RevisionTargetAnswer:
+		answer, err := a.getAnswer(ctx, targetId)
+		if err != nil {
+			return 0, false, err
+		}
+		if !answer.IsAvailable {
+			return 0, false, app_error.ErrUserPermissionDenied
+		}
+		locked, err := a.questionLocked(ctx, answer.QuestionId)
+		if err != nil {
+			return 0, false, err
+		}
+		return model.UserId(answer.AuthorId), locked, nil
+	default:
+		return 0, false, app_error.ErrRevisionNotFound
+	}
+}
+
+// ListRevisions 获取问题或回答的修订历史
+func (a *ArticleService) ListRevisions(ctx context.Context, target model.RevisionTarget, targetId int64, page, size int) ([]model.Revision, int64, app_error.AppError) {
+	if _, _, err := a.revisionTarget(ctx, target, targetId); err != nil {
+		return nil, 0, err
+	}
+	return a.dao.ListRevisions(ctx, target, targetId, page, size)
+}
+
+// DiffRevisions 比较两个版本的标题和内容
+func (a *ArticleService) DiffRevisions(ctx context.Context, target model.RevisionTarget, targetId int64, req *request.DiffRevisionsRequest) (titleDiff, contentDiff []util.DiffChunk, err app_error.AppError) {
+	if _, _, err = a.revisionTarget(ctx, target, targetId); err != nil {
+		return nil, nil, err
+	}
+	from, err := a.dao.GetRevision(ctx, target, targetId, req.From)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := a.dao.GetRevision(ctx, target, targetId, req.To)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	diff := util.DiffLines
+	if req.Mode == request.DiffModeChar {
+		diff = util.DiffChars
+	}
+	if target == model.RevisionTargetQuestion {
+		titleDiff = util.DiffChars(from.Title, to.Title) // 标题只有一行 始终按字符比较
+	}
+	return titleDiff, diff(from.Content, to.Content), nil
+}
+
+// RollbackQuestion 将问题回滚到指定版本 回滚本身也会生成一条新的修订记录 只有提问者和版主可以回滚
+func (a *ArticleService) RollbackQuestion(ctx context.Context, userId model.UserId, questionId int64, version int) (*model.Question, app_error.AppError) {
+	if err := a.checkRollbackPermission(ctx, userId, model.RevisionTargetQuestion, questionId); err != nil {
+		return nil, err
+	}
+	revision, err := a.dao.GetRevision(ctx, model.RevisionTargetQuestion, questionId, version)
+	if err != nil {
+		return nil, err
+	}
+	question, err := a.dao.UpdateQuestion(ctx, userId, questionId, revision.Title, revision.Content)
+	if err != nil {
+		return nil, err
+	}
+	a.saveQuestionMentions(ctx, question)
+	a.invalidateQuestion(ctx, questionId)
+	return question, nil
+}
+
+// RollbackAnswer 将回答回滚到指定版本 只有回答者和版主可以回滚
+func (a *ArticleService) RollbackAnswer(ctx context.Context, userId model.UserId, answerId int64, version int) (*model.Answer, app_error.AppError) {
+	if err := a.checkRollbackPermission(ctx, userId, model.RevisionTargetAnswer, answerId); err != nil {
+		return nil, err
+	}
+	revision, err := a.dao.GetRevision(ctx, model.RevisionTargetAnswer, answerId, version)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := a.dao.UpdateAnswer(ctx, userId, answerId, revision.Content)
+	if err != nil {
+		return nil, err
+	}
+	a.saveAnswerMentions(ctx, answer)
+	return answer, nil
+}
+
+// checkRollbackPermission 作者和版主可以回滚 锁定的问题只有版主可以回滚
+func (a *ArticleService) checkRollbackPermission(ctx context.Context, userId model.UserId, target model.RevisionTarget, targetId int64) app_error.AppError {
+	authorId, locked, err := a.revisionTarget(ctx, target, targetId)
+	if err != nil {
+		return err
+	}
+	moderator, err := a.isModerator(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if moderator {
+		return nil
+	}
+	if locked {
+		return app_error.ErrQuestionLocked
+	}
+	if authorId != userId {
+		return app_error.ErrUserPermissionDenied
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/cache"
 	"my_zhihu_backend/app/config"
 	"my_zhihu_backend/app/dao"
 	"my_zhihu_backend/app/model"
@@ -31,7 +32,7 @@ type AuthService struct {
 
 func NewAuthService(db *gorm.DB, client *redis.Client) *AuthService {
 	cfg := config.C
-	aDAO := dao.NewAuthDAO(client, cfg)
+	aDAO := dao.NewAuthDAO(cache.NewKVStoreOn(cache.Backend(cfg().Service.CacheBackend), client), cfg)
 	uDAO := dao.NewUserDAO(cfg, db)
 	u := new(util.Util)
 	return &AuthService{
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/request"
+	"my_zhihu_backend/app/util"
+	"strconv"
+)
+
+// avatarSizes 头像的各个尺寸 从小到大 最大的尺寸同时写入 Icon 兼容只读取 Icon 的客户端
+var avatarSizes = []int{32, 96, 256}
+
+// maxAvatarPixels 原图允许的最大像素数
+const maxAvatarPixels = 25_000_000
+
+// UpdateAvatar 处理上传的头像 解码校验并按 EXIF 方向旋转后居中裁剪为正方形 缩放为各个尺寸并重新编码(去除 EXIF 等元数据)
+// 存储后通过 UpdateUser 写入 Icon 和 IconSizes
+func (service *UserService) UpdateAvatar(ctx context.Context, id int64, r io.ReadSeeker) (*model.User, app_error.AppError) {
+	img, err := util.DecodeImage(r, maxAvatarPixels)
+	if err != nil {
+		return nil, app_error.ErrInvalidImage.WithError(err)
+	}
+	if b := img.Bounds(); b.Dx() < avatarSizes[0] || b.Dy() < avatarSizes[0] {
+		return nil, app_error.ErrInvalidImage.WithError(fmt.Errorf("image smaller than %dx%d", avatarSizes[0], avatarSizes[0]))
+	}
+	square := util.CropSquare(img)
+
+	sizes := make(map[string]string, len(avatarSizes))
+	ids := make([]int64, 0, len(avatarSizes))
+	for _, size := range avatarSizes {
+		data, err := util.EncodeImage(util.Resize(square, size))
+		if err != nil {
+			return nil, app_error.NewInternalError(app_error.ErrCodeBlobStore, err)
+		}
+		upload, appErr := service.uploads.Upload(ctx, model.UserId(id), fmt.Sprintf("avatar_%d", size), bytes.NewReader(data))
+		if appErr != nil {
+			return nil, appErr
+		}
+		sizes[strconv.Itoa(size)] = service.uploads.URL(upload)
+		ids = append(ids, upload.ID)
+	}
+	if err := service.uploads.BindUploads(ctx, model.UserId(id), ids...); err != nil {
+		return nil, err
+	}
+
+	icon := sizes[strconv.Itoa(avatarSizes[len(avatarSizes)-1])]
+	return service.UpdateUser(ctx, id, &request.UpdateUserRequest{
+		Other: &request.UserOtherInfoRequest{Icon: &icon, IconSizes: sizes},
+	})
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/request"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// dueDraftBatchSize 每次检查最多发布的草稿数 剩余的在下一次检查时处理
+const dueDraftBatchSize = 100
+
+// getQuestionDraft 获取当前用户的问题草稿
+func (a *ArticleService) getQuestionDraft(ctx context.Context, userId model.UserId, questionId int64) (*model.Question, app_error.AppError) {
+	question, err := a.dao.GetQuestion(ctx, questionId)
+	if err != nil {
+		if errors.Is(err, app_error.ErrQuestionNotFound) {
+			return nil, app_error.ErrDraftNotFound
+		}
+		return nil, err
+	}
+	if !question.IsDraft || question.AuthorId != int64(userId) {
+		return nil, app_error.ErrDraftNotFound
+	}
+	return question, nil
+}
+
+// getAnswerDraft 获取当前用户的回答草稿
+func (a *ArticleService) getAnswerDraft(ctx context.Context, userId model.UserId, answerId int64) (*model.Answer, app_error.AppError) {
+	answer, err := a.dao.GetAnswer(ctx, answerId)
+	if err != nil {
+		if errors.Is(err, app_error.ErrAnswerNotFound) {
+			return nil, app_error.ErrDraftNotFound
+		}
+		return nil, err
+	}
+	if !answer.IsDraft || answer.AuthorId != int64(userId) {
+		return nil, app_error.ErrDraftNotFound
+	}
+	return answer, nil
+}
+
+// checkQuestionDraft 发布前检查草稿的标题和内容 与 PostNewQuestion 的必填字段一致
+func checkQuestionDraft(question *model.Question) app_error.AppError {
+	if strings.TrimSpace(question.Title) == "" || strings.TrimSpace(question.Content) == "" {
+		return app_error.ErrDraftIncomplete
+	}
+	return nil
+}
+
+// checkAnswerDraft 发布前检查草稿的内容 与 PostNewAnswer 的必填字段一致
+func checkAnswerDraft(answer *model.Answer) app_error.AppError {
+	if strings.TrimSpace(answer.Content) == "" {
+		return app_error.ErrDraftIncomplete
+	}
+	return nil
+}
+
+// CreateQuestionDraft 创建问题草稿
+func (a *ArticleService) CreateQuestionDraft(ctx context.Context, userId model.UserId, req *request.SaveQuestionDraftRequest) (*model.Question, app_error.AppError) {
+	question := &model.Question{
+		ID:          a.util.GenerateSnowflakeID(),
+		Title:       req.Title,
+		Content:     req.Content,
+		AuthorId:    int64(userId),
+		IsAvailable: true,
+		IsDraft:     true,
+		Version:     1,
+	}
+	if err := a.dao.CreateQuestionDraft(ctx, question); err != nil {
+		return nil, err
+	}
+	a.bindUploads(ctx, model.UserId(question.AuthorId), question.Content)
+	return question, nil
+}
+
+// AutosaveQuestionDraft 自动保存问题草稿 客户端需要携带上一次保存得到的版本号
+func (a *ArticleService) AutosaveQuestionDraft(ctx context.Context, userId model.UserId, questionId int64, req *request.AutosaveQuestionDraftRequest) (*model.Question, app_error.AppError) {
+	if _, err := a.getQuestionDraft(ctx, userId, questionId); err != nil {
+		return nil, err
+	}
+	question, err := a.dao.AutosaveQuestionDraft(ctx, userId, questionId, req.Version, req.Title, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	a.bindUploads(ctx, model.UserId(question.AuthorId), question.Content)
+	return question, nil
+}
+
+// ListQuestionDrafts 获取当前用户的问题草稿
+func (a *ArticleService) ListQuestionDrafts(ctx context.Context, userId model.UserId, page, size int) ([]model.Question, int64, app_error.AppError) {
+	return a.dao.ListQuestionDrafts(ctx, userId, page, size)
+}
+
+// PublishQuestionDraft 立即发布问题草稿 PublishAt 晚于当前时间时改为定时发布
+func (a *ArticleService) PublishQuestionDraft(ctx context.Context, userId model.UserId, questionId int64, req *request.PublishDraftRequest) (*model.Question, app_error.AppError) {
+	draft, err := a.getQuestionDraft(ctx, userId, questionId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkQuestionDraft(draft); err != nil {
+		return nil, err
+	}
+	if req.PublishAt != nil && req.PublishAt.After(time.Now()) {
+		return a.dao.ScheduleQuestion(ctx, userId, questionId, req.PublishAt)
+	}
+	question, published, err := a.dao.PublishQuestion(ctx, questionId)
+	if err != nil {
+		return nil, err
+	}
+	if !published {
+		return nil, app_error.ErrDraftNotFound
+	}
+	a.saveQuestionMentions(ctx, question)
+	a.recordQuestion(ctx, question)
+	a.cacheQuestion(ctx, question)
+	return question, nil
+}
+
+// CancelQuestionSchedule 取消问题草稿的定时发布
+func (a *ArticleService) CancelQuestionSchedule(ctx context.Context, userId model.UserId, questionId int64) (*model.Question, app_error.AppError) {
+	if _, err := a.getQuestionDraft(ctx, userId, questionId); err != nil {
+		return nil, err
+	}
+	return a.dao.ScheduleQuestion(ctx, userId, questionId, nil)
+}
+
+// CreateAnswerDraft 创建回答草稿 问题需要仍然接受新回答
+func (a *ArticleService) CreateAnswerDraft(ctx context.Context, userId model.UserId, req *request.SaveAnswerDraftRequest) (*model.Answer, app_error.AppError) {
+	question, err := a.dao.GetQuestion(ctx, req.QuestionId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAcceptingAnswers(question); err != nil {
+		return nil, err
+	}
+
+	answer := &model.Answer{
+		ID:          a.util.GenerateSnowflakeID(),
+		QuestionId:  req.QuestionId,
+		AuthorId:    int64(userId),
+		Content:     req.Content,
+		IsAvailable: true,
+		IsDraft:     true,
+		Version:     1,
+	}
+	if err := a.dao.CreateAnswerDraft(ctx, answer); err != nil {
+		return nil, err
+	}
+	a.bindUploads(ctx, model.UserId(answer.AuthorId), answer.Content)
+	return answer, nil
+}
+
+// AutosaveAnswerDraft 自动保存回答草稿
+func (a *ArticleService) AutosaveAnswerDraft(ctx context.Context, userId model.UserId, answerId int64, req *request.AutosaveAnswerDraftRequest) (*model.Answer, app_error.AppError) {
+	if _, err := a.getAnswerDraft(ctx, userId, answerId); err != nil {
+		return nil, err
+	}
+	answer, err := a.dao.AutosaveAnswerDraft(ctx, userId, answerId, req.Version, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	a.bindUploads(ctx, model.UserId(answer.AuthorId), answer.Content)
+	return answer, nil
+}
+
+// ListAnswerDrafts 获取当前用户的回答草稿
+func (a *ArticleService) ListAnswerDrafts(ctx context.Context, userId model.UserId, page, size int) ([]model.Answer, int64, app_error.AppError) {
+	return a.dao.ListAnswerDrafts(ctx, userId, page, size)
+}
+
+// PublishAnswerDraft 立即或定时发布回答草稿
+func (a *ArticleService) PublishAnswerDraft(ctx context.Context, userId model.UserId, answerId int64, req *request.PublishDraftRequest) (*model.Answer, app_error.AppError) {
+	draft, err := a.getAnswerDraft(ctx, userId, answerId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAnswerDraft(draft); err != nil {
+		return nil, err
+	}
+	if req.PublishAt != nil && req.PublishAt.After(time.Now()) {
+		return a.dao.ScheduleAnswer(ctx, userId, answerId, req.PublishAt)
+	}
+	return a.publishAnswer(ctx, draft)
+}
+
+// CancelAnswerSchedule 取消回答草稿的定时发布
+func (a *ArticleService) CancelAnswerSchedule(ctx context.Context, userId model.UserId, answerId int64) (*model.Answer, app_error.AppError) {
+	if _, err := a.getAnswerDraft(ctx, userId, answerId); err != nil {
+		return nil, err
+	}
+	return a.dao.ScheduleAnswer(ctx, userId, answerId, nil)
+}
+
+// publishAnswer 发布回答草稿并通知关注问题的用户 草稿已被发布时返回 ErrDraftNotFound
+func (a *ArticleService) publishAnswer(ctx context.Context, draft *model.Answer) (*model.Answer, app_error.AppError) {
+	if err := checkAnswerDraft(draft); err != nil {
+		return nil, err
+	}
+	question, err := a.dao.GetQuestion(ctx, draft.QuestionId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAcceptingAnswers(question); err != nil {
+		return nil, err
+	}
+	answer, published, err := a.dao.PublishAnswer(ctx, draft.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !published {
+		return nil, app_error.ErrDraftNotFound
+	}
+	a.saveAnswerMentions(ctx, answer)
+	a.notifyNewAnswer(ctx, question, answer)
+	a.recordAnswer(ctx, answer)
+	return answer, nil
+}
+
+// RunPublishScheduler 按 config.ServiceConfig.PublishScheduleInterval 定时发布到期的草稿 直到 ctx 结束
+// 发布时使用条件更新 多个实例同时运行也不会重复发布
+func (a *ArticleService) RunPublishScheduler(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg().Service.PublishScheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			a.publishDueDrafts(ctx, now)
+		}
+	}
+}
+
+func (a *ArticleService) publishDueDrafts(ctx context.Context, now time.Time) {
+	timeout, cancel := context.WithTimeout(ctx, a.cfg().Service.PublishScheduleInterval)
+	defer cancel()
+
+	questions, err := a.dao.ListDueQuestionDrafts(timeout, now, dueDraftBatchSize)
+	if err != nil {
+		al.Error("failed to list due question drafts", err.ErrorField()...)
+	}
+	for _, q := range questions {
+		if err := checkQuestionDraft(&q); err != nil {
+			// 标题或内容为空时取消定时 草稿保留给作者处理
+			al.Warn("skip incomplete question draft", append(err.ErrorField(), zap.Int64("question_id", q.ID))...)
+			if _, err := a.dao.ScheduleQuestion(timeout, model.UserId(q.AuthorId), q.ID, nil); err != nil {
+				al.Error("failed to cancel question draft schedule", err.ErrorField()...)
+			}
+			continue
+		}
+		question, published, err := a.dao.PublishQuestion(timeout, q.ID)
+		if err != nil {
+			al.Error("failed to publish question draft", append(err.ErrorField(), zap.Int64("question_id", q.ID))...)
+		} else if published {
+			a.saveQuestionMentions(timeout, question)
+			a.recordQuestion(timeout, question)
+			a.cacheQuestion(timeout, question)
+		}
+	}
+
+	answers, err := a.dao.ListDueAnswerDrafts(timeout, now, dueDraftBatchSize)
+	if err != nil {
+		al.Error("failed to list due answer drafts", err.ErrorField()...)
+	}
+	for _, draft := range answers {
+		_, err := a.publishAnswer(timeout, &draft)
+		if err == nil || errors.Is(err, app_error.ErrDraftNotFound) { // 已被其他实例发布
+			continue
+		}
+		al.Warn("failed to publish answer draft", append(err.ErrorField(), zap.Int64("answer_id", draft.ID))...)
+		// 内容为空 或者问题已关闭、锁定或删除时取消定时 草稿保留给作者处理 避免每次检查都重复失败
+		if errors.Is(err, app_error.ErrDraftIncomplete) || errors.Is(err, app_error.ErrQuestionClosed) || errors.Is(err, app_error.ErrQuestionLocked) || errors.Is(err, app_error.ErrQuestionNotFound) {
+			if _, err := a.dao.ScheduleAnswer(timeout, model.UserId(draft.AuthorId), draft.ID, nil); err != nil {
+				al.Error("failed to cancel answer draft schedule", err.ErrorField()...)
+			}
+		}
+	}
+}
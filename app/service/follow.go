@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/request"
+	"my_zhihu_backend/app/response"
+	"my_zhihu_backend/app/util"
+	"slices"
+	"time"
+)
+
+var ErrInvalidCursor = app_error.NewInputError("invalid cursor", app_error.ErrCodeInvalidParameters, nil)
+
+func newUserSummary(user *model.User) *response.UserSummaryResponse {
+	return &response.UserSummaryResponse{
+		Id:           user.Id,
+		Username:     user.Username,
+		Handle:       util.Deref(user.Handle),
+		Introduction: user.Other.Introduction,
+		Icon:         user.Other.Icon,
+		IconSizes:    user.Other.IconSizes,
+	}
+}
+
+// followPageLoader 按关注时间倒序加载一页关注关系
+type followPageLoader func(ctx context.Context, beforeAt *time.Time, beforeId model.UserId, size int) ([]model.UserFollowers, app_error.AppError)
+
+// listFollows 游标分页获取粉丝或关注列表 附带当前用户与列表中用户的关注关系 hydrate 时附带用户摘要
+// other 返回关系中列表展示的一方
+func (service *UserService) listFollows(ctx context.Context, viewerId model.UserId, req *request.ListFollowsRequest, load followPageLoader, other func(model.UserFollowers) model.UserId) (*response.ListFollowsResponse, app_error.AppError) {
+	var beforeAt *time.Time
+	var beforeId model.UserId
+	if req.Cursor != "" {
+		t, id, err := util.DecodeCursor(req.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor.WithError(err)
+		}
+		beforeAt, beforeId = &t, model.UserId(id)
+	}
+
+	relations, err := load(ctx, beforeAt, beforeId, req.Size+1) // 多取一条判断是否还有下一页
+	if err != nil {
+		return nil, err
+	}
+	var nextCursor string
+	if len(relations) > req.Size {
+		relations = relations[:req.Size]
+		last := relations[len(relations)-1]
+		nextCursor = util.EncodeCursor(last.CreatedAt, int64(other(last)))
+	}
+
+	ids := make([]model.UserId, 0, len(relations))
+	for _, r := range relations {
+		ids = append(ids, other(r))
+	}
+	followings, err := service.dao.FilterFollowings(ctx, viewerId, ids)
+	if err != nil {
+		return nil, err
+	}
+	followers, err := service.dao.FilterFollowers(ctx, viewerId, ids)
+	if err != nil {
+		return nil, err
+	}
+	var users map[model.UserId]*model.User
+	if req.Hydrate {
+		if users, err = service.getUsers(ctx, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	records := make([]response.FollowEntryResponse, 0, len(relations))
+	for _, r := range relations {
+		id := other(r)
+		entry := response.FollowEntryResponse{
+			UserId:     id,
+			FollowedAt: r.CreatedAt.Format(time.DateTime),
+			IFollow:    slices.Contains(followings, id),
+			FollowsMe:  slices.Contains(followers, id),
+		}
+		if user, ok := users[id]; ok {
+			entry.User = newUserSummary(user)
+		}
+		records = append(records, entry)
+	}
+	return &response.ListFollowsResponse{Records: records, NextCursor: nextCursor}, nil
+}
+
+// ListFollowers 分页获取粉丝列表
+func (service *UserService) ListFollowers(ctx context.Context, viewerId, id model.UserId, req *request.ListFollowsRequest) (*response.ListFollowsResponse, app_error.AppError) {
+	return service.listFollows(ctx, viewerId, req, func(ctx context.Context, beforeAt *time.Time, beforeId model.UserId, size int) ([]model.UserFollowers, app_error.AppError) {
+		return service.dao.ListFollowersPage(ctx, id, beforeAt, beforeId, size)
+	}, func(r model.UserFollowers) model.UserId { return r.FollowerID })
+}
+
+// ListFollowings 分页获取关注列表
+func (service *UserService) ListFollowings(ctx context.Context, viewerId, id model.UserId, req *request.ListFollowsRequest) (*response.ListFollowsResponse, app_error.AppError) {
+	return service.listFollows(ctx, viewerId, req, func(ctx context.Context, beforeAt *time.Time, beforeId model.UserId, size int) ([]model.UserFollowers, app_error.AppError) {
+		return service.dao.ListFollowingsPage(ctx, id, beforeAt, beforeId, size)
+	}, func(r model.UserFollowers) model.UserId { return r.FollowingID })
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/util"
+	"time"
+)
+
+// ChangeHandle 设置或修改 handle 第一次设置不受 config.ServiceConfig.HandleChangeCooldown 限制
+func (service *UserService) ChangeHandle(ctx context.Context, id int64, handle string) (*model.User, app_error.AppError) {
+	handle = util.NormalizeHandle(handle)
+	if err := util.ValidateHandle(handle); err != nil {
+		return nil, app_error.ErrInvalidHandle.WithError(err)
+	}
+
+	user, err := service.dao.GetById(ctx, model.UserId(id))
+	if err != nil {
+		return nil, err
+	}
+	if user.Handle != nil && *user.Handle == handle {
+		return user, nil
+	}
+	if user.Handle != nil && user.HandleChangedAt != nil {
+		if next := user.HandleChangedAt.Add(service.cfg().Service.HandleChangeCooldown); time.Now().Before(next) {
+			return nil, app_error.ErrHandleChangeTooFrequent.WithError(fmt.Errorf("next change allowed after %s", next.Format(time.DateTime)))
+		}
+	}
+
+	if err := service.dao.ChangeHandle(ctx, user, handle, time.Now().Add(-service.cfg().Service.HandleReserveTTL)); err != nil {
+		return nil, err
+	}
+	user, err = service.dao.GetById(ctx, model.UserId(id))
+	if err != nil {
+		return nil, err
+	}
+	service.store(ctx, *user)
+	service.purgeResponses(ctx, user.Id)
+	return user, nil
+}
+
+// GetUserByHandle 通过 handle 获取用户 handle 是用户以前使用过的时返回用户当前的信息 moved 为 true
+func (service *UserService) GetUserByHandle(ctx context.Context, handle string) (user *model.User, moved bool, err app_error.AppError) {
+	handle = util.NormalizeHandle(handle)
+	user, err = service.dao.GetByHandle(ctx, handle)
+	if err == nil {
+		return user, false, nil
+	}
+	if !errors.Is(err, app_error.ErrUserNotExists) {
+		return nil, false, err
+	}
+
+	history, err := service.dao.GetHandleHistory(ctx, handle)
+	if err != nil {
+		return nil, false, err
+	}
+	if history == nil {
+		return nil, false, app_error.ErrUserNotExists
+	}
+	user, err = service.GetUser(ctx, int64(history.UserId))
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
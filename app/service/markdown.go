@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/cache"
+	"my_zhihu_backend/app/util"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// renderedContentTTL 渲染结果只与内容有关 可以缓存较长时间
+const renderedContentTTL = 7 * 24 * time.Hour
+
+// MarkdownRenderer 将问题和回答的 Markdown 内容渲染为安全的 HTML
+// 以内容的 sha256 作为缓存键 同一修订版本的内容只渲染一次 编辑后自然使用新的键
+type MarkdownRenderer struct {
+	cacher cache.Cacher[string]
+}
+
+func NewMarkdownRenderer(backend cache.Backend, client *redis.Client, prefix string) *MarkdownRenderer {
+	cacher := cache.NewPlainCacherOn(backend, client, renderedContentTTL, prefix, func(ctx context.Context, args ...any) (*string, app_error.AppError) {
+		rendered := render(args[0].(string))
+		return &rendered, nil
+	}, cache.NewBloomFilterOn(backend, "rendered-content-filter", client))
+	return &MarkdownRenderer{cacher: cacher}
+}
+
+// render 渲染失败时退化为转义后的原文 保证输出始终是安全的
+func render(content string) string {
+	rendered, err := util.RenderMarkdown(content)
+	if err != nil {
+		al.Warn("failed to render markdown", zap.Error(err))
+		return html.EscapeString(content)
+	}
+	return rendered
+}
+
+func contentKey(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Render 优先读取缓存 缓存不存在时渲染并异步写入缓存 Redis 出错时直接渲染
+func (r *MarkdownRenderer) Render(ctx context.Context, content string) string {
+	if content == "" {
+		return ""
+	}
+	key := contentKey(content)
+	rendered, err := r.cacher.Get(ctx, key, content)
+	if err == nil {
+		return *rendered
+	}
+
+	result := render(content)
+	if errors.Is(err, app_error.ErrRedisCacheKeyNotExists) {
+		go func() {
+			timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := r.cacher.Put(timeout, key, result); err != nil {
+				al.Error("failed to cache rendered content", err.ErrorField()...)
+			}
+		}()
+	} else {
+		al.Warn("failed to read rendered content cache", err.ErrorField()...)
+	}
+	return result
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/util"
+	"slices"
+	"strings"
+)
+
+// maxMentionedUsers 一条内容最多解析的不同用户名数量 超出的部分当作普通文本
+const maxMentionedUsers = 20
+
+// resolveMentions 将内容中的 @username 解析为用户 优先匹配不区分大小写的 handle 其次匹配不区分大小写的用户名
+// 同名用户有多个时 依次优先选择作者关注的、关注了作者的用户 仍然无法确定时不解析
+// 与作者存在拉黑关系的用户 以及动态对作者不可见的用户不会被解析
+func (a *ArticleService) resolveMentions(ctx context.Context, authorId model.UserId, content string) ([]model.Mention, app_error.AppError) {
+	spans := util.ParseMentions(content)
+	if len(spans) == 0 {
+		return nil, nil
+	}
+	var usernames []string
+	for _, span := range spans {
+		// 用户名按不区分大小写比较 与数据库的排序规则一致
+		if !slices.ContainsFunc(usernames, func(name string) bool { return strings.EqualFold(name, span.Username) }) {
+			usernames = append(usernames, span.Username)
+		}
+	}
+	if len(usernames) > maxMentionedUsers {
+		usernames = usernames[:maxMentionedUsers]
+	}
+
+	users, err := a.uDAO.ListUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+	handles := make([]string, 0, len(usernames))
+	for _, name := range usernames {
+		handles = append(handles, util.NormalizeHandle(name))
+	}
+	handleUsers, err := a.uDAO.ListUsersByHandles(ctx, handles)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]model.UserId, 0, len(users)+len(handleUsers))
+	for _, u := range users {
+		ids = append(ids, u.Id)
+	}
+	for _, u := range handleUsers {
+		ids = append(ids, u.Id)
+	}
+	followings, err := a.uDAO.FilterFollowings(ctx, authorId, ids)
+	if err != nil {
+		return nil, err
+	}
+	followers, err := a.uDAO.FilterFollowers(ctx, authorId, ids)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := a.uDAO.ListBlockedAmong(ctx, authorId, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string][]model.User)
+	for _, u := range users {
+		if slices.Contains(blocked, u.Id) {
+			continue
+		}
+		name := strings.ToLower(u.Username)
+		candidates[name] = append(candidates[name], u)
+	}
+	// handle 是唯一的 匹配到 handle 时不再考虑同名的用户
+	for _, u := range handleUsers {
+		for _, name := range usernames {
+			if util.NormalizeHandle(name) != *u.Handle {
+				continue
+			}
+			if slices.Contains(blocked, u.Id) {
+				delete(candidates, strings.ToLower(name))
+			} else {
+				candidates[strings.ToLower(name)] = []model.User{u}
+			}
+		}
+	}
+	pick := func(users []model.User, among []model.UserId) *model.User {
+		var picked *model.User
+		for i := range users {
+			if slices.Contains(among, users[i].Id) {
+				if picked != nil {
+					return nil
+				}
+				picked = &users[i]
+			}
+		}
+		return picked
+	}
+	resolved := make(map[string]*model.User)
+	for name, cands := range candidates {
+		var user *model.User
+		if len(cands) == 1 {
+			user = &cands[0]
+		} else if user = pick(cands, followings); user == nil {
+			user = pick(cands, followers)
+		}
+		if user == nil {
+			continue
+		}
+		rel := Relation{Self: user.Id == authorId, Follower: slices.Contains(followings, user.Id), Following: slices.Contains(followers, user.Id)}
+		if !CanView(&user.Settings, model.PrivacyActivity, rel) {
+			continue
+		}
+		resolved[name] = user
+	}
+
+	var mentions []model.Mention
+	for _, span := range spans {
+		user, ok := resolved[strings.ToLower(span.Username)]
+		if !ok {
+			continue
+		}
+		mentions = append(mentions, model.Mention{
+			ID:       a.util.GenerateSnowflakeID(),
+			AuthorId: authorId,
+			UserId:   user.Id,
+			Username: span.Username,
+			Start:    span.Start,
+			End:      span.End,
+		})
+	}
+	return mentions, nil
+}
+
+// saveMentions 解析并保存内容中的提及 通知新被提及的用户 notification 提供通知的作者和关联的内容
+// 失败时只记录日志 不影响内容的写入
+func (a *ArticleService) saveMentions(ctx context.Context, target model.MentionTarget, targetId int64, content string, notification model.Notification) {
+	mentions, err := a.resolveMentions(ctx, notification.ActorId, content)
+	if err != nil {
+		al.Error("failed to resolve mentions", err.ErrorField()...)
+		return
+	}
+	for i := range mentions {
+		mentions[i].TargetType = target
+		mentions[i].TargetId = targetId
+	}
+	added, err := a.mentionDAO.ReplaceMentions(ctx, target, targetId, mentions)
+	if err != nil {
+		al.Error("failed to save mentions", err.ErrorField()...)
+		return
+	}
+
+	notifications := make([]model.Notification, 0, len(added))
+	for _, userId := range added {
+		if userId == notification.ActorId {
+			continue
+		}
+		n := notification
+		n.UserId = userId
+		n.Type = model.NotificationMention
+		notifications = append(notifications, n)
+	}
+	a.notification.Notify(notifications...)
+}
+
+func (a *ArticleService) saveQuestionMentions(ctx context.Context, q *model.Question) {
+	a.saveMentions(ctx, model.MentionTargetQuestion, q.ID, q.Content, model.Notification{ActorId: model.UserId(q.AuthorId), QuestionId: q.ID})
+}
+
+func (a *ArticleService) saveAnswerMentions(ctx context.Context, an *model.Answer) {
+	a.saveMentions(ctx, model.MentionTargetAnswer, an.ID, an.Content, model.Notification{ActorId: model.UserId(an.AuthorId), QuestionId: an.QuestionId, AnswerId: an.ID})
+}
+
+func (a *ArticleService) saveCommentMentions(ctx context.Context, c *model.Comment) {
+	a.saveMentions(ctx, model.MentionTargetComment, c.ID, c.Content, model.Notification{ActorId: model.UserId(c.AuthorId), AnswerId: c.AnswerId, CommentId: c.ID})
+}
+
+// ListMentions 获取内容中的提及 用于在响应中返回提及的位置 失败时只记录日志并返回空列表
+func (a *ArticleService) ListMentions(ctx context.Context, target model.MentionTarget, targetId int64) []model.Mention {
+	mentions, err := a.mentionDAO.ListMentions(ctx, target, targetId)
+	if err != nil {
+		al.Error("failed to list mentions", err.ErrorField()...)
+		return nil
+	}
+	return mentions
+}
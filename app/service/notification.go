@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/dao"
+	"my_zhihu_backend/app/log"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/util"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var nl = log.L().With(zap.String("module", "notification service"))
+
+type NotificationService struct {
+	dao  *dao.NotificationDAO
+	util *util.Util
+}
+
+func NewNotificationService(db *gorm.DB) *NotificationService {
+	nDAO := dao.NewNotificationDAO(db)
+	u := new(util.Util)
+	return &NotificationService{dao: nDAO, util: u}
+}
+
+// Notify 在后台异步写入通知 通知失败不影响主业务
+func (service *NotificationService) Notify(notifications ...model.Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+	for i := range notifications {
+		notifications[i].ID = service.util.GenerateSnowflakeID()
+	}
+	go func() {
+		timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := service.dao.CreateNotifications(timeout, notifications); err != nil {
+			nl.Error("failed to create notifications", err.ErrorField()...)
+		}
+	}()
+}
+
+// ListNotifications 分页获取通知
+func (service *NotificationService) ListNotifications(ctx context.Context, userId model.UserId, unreadOnly bool, page, size int) ([]model.Notification, int64, app_error.AppError) {
+	return service.dao.ListNotifications(ctx, userId, unreadOnly, page, size)
+}
+
+// MarkRead 标记单条通知已读
+func (service *NotificationService) MarkRead(ctx context.Context, userId model.UserId, notificationId int64) app_error.AppError {
+	return service.dao.MarkRead(ctx, userId, notificationId)
+}
+
+// MarkAllRead 标记全部通知已读
+func (service *NotificationService) MarkAllRead(ctx context.Context, userId model.UserId) app_error.AppError {
+	return service.dao.MarkAllRead(ctx, userId)
+}
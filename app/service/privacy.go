@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/request"
+	"my_zhihu_backend/app/response"
+	"my_zhihu_backend/app/util"
+	"slices"
+)
+
+// hiddenField 对查看者不可见的字符串字段返回的内容
+const hiddenField = "nothing here"
+
+// Relation 查看者与资料所属用户之间的关系
+type Relation struct {
+	Self      bool // 查看自己的资料
+	Follower  bool // 查看者关注了用户
+	Following bool // 用户关注了查看者
+}
+
+// SelfRelation 查看自己的资料
+var SelfRelation = Relation{Self: true}
+
+// CanView 隐私策略 所有面向用户的响应都通过它判断资料字段、关注列表和动态对查看者是否可见
+func CanView(settings *model.UserSettings, field model.PrivacyField, rel Relation) bool {
+	if rel.Self {
+		return true
+	}
+	switch settings.Visibility(field) {
+	case model.VisibilityPublic:
+		return true
+	case model.VisibilityFollowers:
+		return rel.Follower
+	case model.VisibilityMutual:
+		return rel.Follower && rel.Following
+	}
+	return false
+}
+
+// Relation 获取查看者与用户之间的关系
+func (service *UserService) Relation(ctx context.Context, viewerId, id model.UserId) (Relation, app_error.AppError) {
+	relations, err := service.Relations(ctx, viewerId, []model.UserId{id})
+	if err != nil {
+		return Relation{}, err
+	}
+	return relations[id], nil
+}
+
+// Relations 批量获取查看者与用户之间的关系
+func (service *UserService) Relations(ctx context.Context, viewerId model.UserId, ids []model.UserId) (map[model.UserId]Relation, app_error.AppError) {
+	followings, err := service.dao.FilterFollowings(ctx, viewerId, ids)
+	if err != nil {
+		return nil, err
+	}
+	followers, err := service.dao.FilterFollowers(ctx, viewerId, ids)
+	if err != nil {
+		return nil, err
+	}
+	relations := make(map[model.UserId]Relation, len(ids))
+	for _, id := range ids {
+		relations[id] = Relation{
+			Self:      id == viewerId,
+			Follower:  slices.Contains(followings, id),
+			Following: slices.Contains(followers, id),
+		}
+	}
+	return relations, nil
+}
+
+func newUserOtherInfoResponse(other model.UserOtherInfo) response.UserOtherInfoResponse {
+	return response.UserOtherInfoResponse{
+		Introduction: other.Introduction,
+		Icon:         other.Icon,
+		IconSizes:    other.IconSizes,
+	}
+}
+
+func newUserSettingsResponse(settings model.UserSettings) *response.UserSettingsResponse {
+	return &response.UserSettingsResponse{
+		EmailVisibility:      settings.EmailVisibility,
+		GenderVisibility:     settings.GenderVisibility,
+		RegionVisibility:     settings.RegionVisibility,
+		FollowersVisibility:  settings.FollowersVisibility,
+		FollowingsVisibility: settings.FollowingsVisibility,
+		ActivityVisibility:   settings.ActivityVisibility,
+	}
+}
+
+// applySettings 将请求中的隐私设置应用到 settings 上
+func applySettings(settings *model.UserSettings, req *request.UserSettings) {
+	if req.HidePrivacy != nil {
+		if *req.HidePrivacy {
+			settings.SetAll(model.VisibilityPrivate)
+		} else {
+			settings.SetAll(model.VisibilityPublic)
+		}
+	}
+	for _, f := range []struct {
+		dst *model.Visibility
+		src *model.Visibility
+	}{
+		{&settings.EmailVisibility, req.EmailVisibility},
+		{&settings.GenderVisibility, req.GenderVisibility},
+		{&settings.RegionVisibility, req.RegionVisibility},
+		{&settings.FollowersVisibility, req.FollowersVisibility},
+		{&settings.FollowingsVisibility, req.FollowingsVisibility},
+		{&settings.ActivityVisibility, req.ActivityVisibility},
+	} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+}
+
+// NewUserResponse 按隐私策略生成用户资料 不可见的字段被替换 只有本人可以看到隐私设置
+func NewUserResponse(user *model.User, rel Relation) response.UserResponse {
+	resp := response.UserResponse{
+		Id:       user.Id,
+		Username: user.Username,
+		Handle:   util.Deref(user.Handle),
+		Email:    user.Email,
+		Gender:   *user.Gender,
+		Region:   user.Region,
+		Other:    newUserOtherInfoResponse(user.Other),
+	}
+	if !CanView(&user.Settings, model.PrivacyEmail, rel) {
+		resp.Email = hiddenField
+	}
+	if !CanView(&user.Settings, model.PrivacyGender, rel) {
+		resp.Gender = model.UserGenderSecret
+	}
+	if !CanView(&user.Settings, model.PrivacyRegion, rel) {
+		resp.Region = hiddenField
+	}
+	if rel.Self {
+		resp.Settings = newUserSettingsResponse(user.Settings)
+	}
+	return resp
+}
+
+// CheckVisible 查看者能否查看用户的 field 如粉丝列表、关注列表和动态
+func (service *UserService) CheckVisible(ctx context.Context, viewerId, id model.UserId, field model.PrivacyField) app_error.AppError {
+	if viewerId == id {
+		return nil
+	}
+	user, err := service.GetUser(ctx, int64(id))
+	if err != nil {
+		return err
+	}
+	rel, err := service.Relation(ctx, viewerId, id)
+	if err != nil {
+		return err
+	}
+	if !CanView(&user.Settings, field, rel) {
+		return app_error.ErrUserPermissionDenied
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/cache"
+	"my_zhihu_backend/app/model"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	questionBloomFilterName = "question-filter" // 问题缓存使用的布隆过滤器
+	questionCacheVersion    = 2                 // 问题缓存的版本 修改 model.Question 的字段时加一
+	questionBloomBatchSize  = 1000              // 重建布隆过滤器时每批读取的问题数
+	questionCacheTTL        = time.Hour
+)
+
+func questionKey(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// loadQuestion 问题缓存的 fallback 草稿只能通过草稿接口访问 不写入缓存
+func (a *ArticleService) loadQuestion(ctx context.Context, args ...any) (*model.Question, app_error.AppError) {
+	q, err := a.dao.GetQuestion(ctx, args[0].(int64))
+	if err != nil {
+		return nil, err
+	}
+	if q.IsDraft {
+		return nil, app_error.ErrQuestionNotFound
+	}
+	return q, nil
+}
+
+// cacheQuestion 新发布的问题写入缓存 同时加入布隆过滤器 失败时只记录日志
+func (a *ArticleService) cacheQuestion(ctx context.Context, q *model.Question) {
+	if err := a.questionCacher.Put(ctx, questionKey(q.ID), *q); err != nil {
+		al.Warn("failed to cache question", append(err.ErrorField(), zap.Int64("question_id", q.ID))...)
+	}
+}
+
+// invalidateQuestion 问题修改或删除后删除缓存 下次读取时从数据库加载 失败时只记录日志 缓存在过期后自然失效
+func (a *ArticleService) invalidateQuestion(ctx context.Context, id int64) {
+	if _, err := a.questionCacher.Invalidate(ctx, questionKey(id)); err != nil && !errors.Is(err, app_error.ErrRedisCacheKeyNotExists) {
+		al.Warn("failed to invalidate question cache", append(err.ErrorField(), zap.Int64("question_id", id))...)
+	}
+}
+
+// RegisterBloomSources 设置问题缓存的布隆过滤器重建时的数据来源 重建完成前被拦截的请求仍然查询数据库
+// 需要在提供服务前调用
+func (a *ArticleService) RegisterBloomSources(m *cache.BloomManager) {
+	m.SetSource(questionBloomFilterName, a.questionBloomSource)
+	a.questionBloomPopulated = func() bool { return m.Populated(questionBloomFilterName) }
+}
+
+// FillBloomFilters 从数据库填充问题缓存的布隆过滤器 memory 存储下在提供服务前调用 redis 存储下由 BloomManager 重建
+func (a *ArticleService) FillBloomFilters(ctx context.Context) {
+	if err := cache.FillBloomFilter(ctx, a.questionBloom, a.questionBloomSource); err != nil {
+		al.Error("failed to fill question bloom filter", zap.Error(err))
+	}
+}
+
+// questionBloomSource 按 id 顺序读取 after 之后的所有已发布问题 写入问题缓存的 key
+func (a *ArticleService) questionBloomSource(ctx context.Context, after int64, add func(values ...any) error) (int64, error) {
+	last := after
+	for {
+		ids, err := a.dao.ListQuestionIds(ctx, last, questionBloomBatchSize)
+		if err != nil {
+			return last, err
+		}
+		if len(ids) == 0 {
+			return last, nil
+		}
+		keys := make([]any, 0, len(ids))
+		for _, id := range ids {
+			keys = append(keys, a.cfg().Prefix.QuestionInfoPrefix+questionKey(id))
+		}
+		if err := add(keys...); err != nil {
+			return last, err
+		}
+		last = ids[len(ids)-1]
+	}
+}
+
+// countView 在内存中累计问题的浏览次数
+func (a *ArticleService) countView(questionId int64) {
+	a.viewsMu.Lock()
+	a.views[questionId]++
+	a.viewsMu.Unlock()
+}
+
+// RunViewFlusher 按 config.ServiceConfig.ViewFlushInterval 把累计的浏览次数写入数据库 直到 ctx 结束
+// 每个问题每次只执行一次 UPDATE 避免每次浏览都更新同一行 ctx 结束时写入剩余的浏览次数
+func (a *ArticleService) RunViewFlusher(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg().Service.ViewFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			a.flushViews(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			a.flushViews(ctx)
+		}
+	}
+}
+
+// flushViews 写入失败的浏览次数放回内存 下次重试
+func (a *ArticleService) flushViews(ctx context.Context) {
+	a.viewsMu.Lock()
+	views := a.views
+	a.views = make(map[int64]int64, len(views))
+	a.viewsMu.Unlock()
+	if len(views) == 0 {
+		return
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, a.cfg().Service.ViewFlushInterval)
+	defer cancel()
+	failed := make(map[int64]int64)
+	for id, n := range views {
+		if err := a.dao.IncrQuestionViews(timeout, id, n); err != nil {
+			al.Warn("failed to flush question views", append(err.ErrorField(), zap.Int64("question_id", id))...)
+			failed[id] = n
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	a.viewsMu.Lock()
+	for id, n := range failed {
+		a.views[id] += n
+	}
+	a.viewsMu.Unlock()
+}
+
+// WarmUpCache 预热浏览最多的问题 在启动时执行 失败时只记录日志
+func (a *ArticleService) WarmUpCache(ctx context.Context) {
+	size := a.cfg().Service.WarmUpQuestionCount
+	if size <= 0 {
+		return
+	}
+	questions, err := a.dao.ListMostViewedQuestions(ctx, size)
+	if err != nil {
+		al.Error("failed to list questions to warm up", err.ErrorField()...)
+		return
+	}
+	values := make(map[string]model.Question, len(questions))
+	for _, q := range questions {
+		values[questionKey(q.ID)] = q
+	}
+	if err := cache.WarmUp(ctx, a.questionCacher, values); err != nil {
+		al.Error("failed to warm up question cache", err.ErrorField()...)
+		return
+	}
+	al.Info("question cache warmed up", zap.Int("count", len(values)))
+}
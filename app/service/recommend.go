@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/response"
+)
+
+// summarizeUsers 按 ids 的顺序返回用户摘要 跳过不存在的用户
+func (service *UserService) summarizeUsers(ctx context.Context, ids []model.UserId) ([]response.UserSummaryResponse, app_error.AppError) {
+	users, err := service.getUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]response.UserSummaryResponse, 0, len(ids))
+	for _, id := range ids {
+		if user, ok := users[id]; ok {
+			summaries = append(summaries, *newUserSummary(user))
+		}
+	}
+	return summaries, nil
+}
+
+// ListMutualFollowers 同时关注了当前用户和 id 的用户
+func (service *UserService) ListMutualFollowers(ctx context.Context, viewerId, id model.UserId, size int) (*response.UserSampleResponse, app_error.AppError) {
+	ids, total, err := service.dao.ListMutualFollowers(ctx, viewerId, id, size)
+	if err != nil {
+		return nil, err
+	}
+	users, err := service.summarizeUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return &response.UserSampleResponse{Count: total, Users: users}, nil
+}
+
+// ListFollowedBy 当前用户关注的人中关注了 id 的用户 在用户主页展示 "你关注的 xx 等 n 人也关注了他"
+func (service *UserService) ListFollowedBy(ctx context.Context, viewerId, id model.UserId, size int) (*response.UserSampleResponse, app_error.AppError) {
+	ids, total, err := service.dao.ListFollowingsFollowing(ctx, viewerId, id, size)
+	if err != nil {
+		return nil, err
+	}
+	users, err := service.summarizeUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return &response.UserSampleResponse{Count: total, Users: users}, nil
+}
+
+// RecommendFollows 可能认识的人 按我关注的用户中关注了对方的人数排序
+func (service *UserService) RecommendFollows(ctx context.Context, userId model.UserId, size int) ([]response.RecommendedUserResponse, app_error.AppError) {
+	recommended, err := service.dao.ListRecommendedFollows(ctx, userId, size)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]model.UserId, 0, len(recommended))
+	for _, r := range recommended {
+		ids = append(ids, r.UserId)
+	}
+	users, err := service.getUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]response.RecommendedUserResponse, 0, len(recommended))
+	for _, r := range recommended {
+		if user, ok := users[r.UserId]; ok {
+			results = append(results, response.RecommendedUserResponse{User: *newUserSummary(user), Overlap: r.Overlap})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/cache"
+	"my_zhihu_backend/app/config"
+	"my_zhihu_backend/app/dao"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/response"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// cacheSampleSize 每个缓存前缀返回的示例 key 数量
+const cacheSampleSize = 5
+
+// SystemService 查看服务内部状态 只允许版主访问
+type SystemService struct {
+	uDAO   *dao.UserDAO
+	cfg    config.ReadConfigFunc
+	client *redis.Client
+}
+
+func NewSystemService(db *gorm.DB, client *redis.Client) *SystemService {
+	return &SystemService{uDAO: dao.NewUserDAO(config.C, db), cfg: config.C, client: client}
+}
+
+func (s *SystemService) checkModerator(ctx context.Context, userId model.UserId) app_error.AppError {
+	user, err := s.uDAO.GetById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.Role != model.UserRoleModerator {
+		return app_error.ErrUserPermissionDenied
+	}
+	return nil
+}
+
+// CacheStatus 获取缓存的熔断器和布隆过滤器状态
+func (s *SystemService) CacheStatus(ctx context.Context, userId model.UserId) (*response.CacheStatusResponse, app_error.AppError) {
+	if err := s.checkModerator(ctx, userId); err != nil {
+		return nil, err
+	}
+	status := cache.RedisBreaker.Status()
+	resp := &response.CacheStatusResponse{
+		Backend: s.cfg().Service.CacheBackend,
+		Breaker: response.BreakerStatusResponse{
+			Name:     status.Name,
+			State:    status.State.String(),
+			Failures: status.Failures,
+		},
+		BloomFilters: cache.BloomFilterAvailability(),
+	}
+	if !status.OpenedAt.IsZero() {
+		resp.Breaker.OpenedAt = status.OpenedAt.Format(time.DateTime)
+	}
+	return resp, nil
+}
+
+// CacheStats 获取各个缓存前缀的计数以及部分 key 的剩余有效期 计数只包括当前实例
+func (s *SystemService) CacheStats(ctx context.Context, userId model.UserId) (*response.CacheStatsResponse, app_error.AppError) {
+	if err := s.checkModerator(ctx, userId); err != nil {
+		return nil, err
+	}
+	backend := cache.Backend(s.cfg().Service.CacheBackend)
+	resp := &response.CacheStatsResponse{Prefixes: []response.CacheStatsItemResponse{}}
+	for _, stats := range cache.AllStats() {
+		item := response.CacheStatsItemResponse{
+			Prefix:          stats.Prefix,
+			Hits:            stats.Hits,
+			LocalHits:       stats.LocalHits,
+			Misses:          stats.Misses,
+			HitRate:         stats.HitRate(),
+			BloomRejections: stats.BloomRejections,
+			Fallbacks:       stats.Fallbacks,
+			Shared:          stats.Shared,
+			Errors:          stats.Errors,
+			Samples:         []response.KeyTTLResponse{},
+		}
+		if stats.Fallbacks > 0 {
+			item.FallbackAvgMs = float64(stats.FallbackTime.Milliseconds()) / float64(stats.Fallbacks)
+		}
+		// redis 不可用时不返回示例 计数仍然有效
+		if samples, err := cache.SampleTTLs(ctx, backend, s.client, stats.Prefix, cacheSampleSize); err == nil {
+			for _, sample := range samples {
+				ttl := int64(-1)
+				if sample.TTL >= 0 {
+					ttl = int64(sample.TTL.Seconds())
+				}
+				item.Samples = append(item.Samples, response.KeyTTLResponse{Key: sample.Key, TTL: ttl})
+			}
+		}
+		resp.Prefixes = append(resp.Prefixes, item)
+	}
+	return resp, nil
+}
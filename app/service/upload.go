@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/config"
+	"my_zhihu_backend/app/dao"
+	"my_zhihu_backend/app/log"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/storage"
+	"my_zhihu_backend/app/util"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var ul = log.L().With(zap.String("module", "upload service"))
+
+// allowedUploadTypes 允许上传的文件类型 以服务端嗅探的结果为准 不信任客户端声明的 Content-Type
+var allowedUploadTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+}
+
+// unreferencedBlobBatchSize 每次清理最多删除的文件数 剩余的在下一次清理时处理
+const unreferencedBlobBatchSize = 500
+
+const maxFilenameLength = 100
+
+type UploadService struct {
+	dao   *dao.UploadDAO
+	store storage.BlobStore
+	cfg   config.ReadConfigFunc
+	util  *util.Util
+}
+
+func NewUploadService(db *gorm.DB, store storage.BlobStore) *UploadService {
+	return &UploadService{
+		dao:   dao.NewUploadDAO(db),
+		store: store,
+		cfg:   config.C,
+		util:  new(util.Util),
+	}
+}
+
+// sniffContentType 根据文件头部判断文件类型
+func sniffContentType(head []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// cleanFilename 去掉客户端文件名中的路径 并限制长度
+func cleanFilename(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if filename == "." || filename == "/" {
+		return "file"
+	}
+	if runes := []rune(filename); len(runes) > maxFilenameLength {
+		filename = string(runes[len(runes)-maxFilenameLength:])
+	}
+	return filename
+}
+
+// Upload 保存上传的文件 先写入临时文件并计算 sha256 再按内容寻址写入 BlobStore 相同内容只存储一份
+func (s *UploadService) Upload(ctx context.Context, ownerId model.UserId, filename string, r io.Reader) (*model.Upload, app_error.AppError) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, app_error.NewInternalError(app_error.ErrCodeBlobStore, err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	maxSize := s.cfg().Service.UploadMaxSize
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(r, maxSize+1))
+	if err != nil {
+		if _, ok := errors.AsType[*http.MaxBytesError](err); ok {
+			return nil, app_error.ErrFileTooLarge.WithError(err)
+		}
+		return nil, app_error.NewInternalError(app_error.ErrCodeBlobStore, err)
+	}
+	if size > maxSize {
+		return nil, app_error.ErrFileTooLarge
+	}
+	if size == 0 {
+		return nil, app_error.ErrUnsupportedFileType
+	}
+
+	head := make([]byte, 512)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, app_error.NewInternalError(app_error.ErrCodeBlobStore, err)
+	}
+	contentType := sniffContentType(head[:n])
+	if !allowedUploadTypes[contentType] {
+		return nil, app_error.ErrUnsupportedFileType
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if existing, err := s.dao.FindUploadByHash(ctx, ownerId, hash); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return existing, nil
+	}
+
+	upload := &model.Upload{
+		ID:          s.util.GenerateSnowflakeID(),
+		OwnerId:     ownerId,
+		Hash:        hash,
+		Filename:    cleanFilename(filename),
+		Size:        size,
+		ContentType: contentType,
+	}
+	blob := &model.Blob{Hash: hash, Size: size, ContentType: contentType}
+	// 相同内容的文件可能已经存在 仍然重新写入 避免清理任务在检查之后删除它
+	put := func() app_error.AppError {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return app_error.NewInternalError(app_error.ErrCodeBlobStore, err)
+		}
+		if err := s.store.Put(ctx, hash, tmp); err != nil {
+			return app_error.NewInternalError(app_error.ErrCodeBlobStore, err)
+		}
+		return nil
+	}
+	if err := s.dao.CreateUpload(ctx, blob, upload, put); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (s *UploadService) sign(id int64, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg().Service.UploadSignSecret))
+	_, _ = fmt.Fprintf(mac, "%d:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedURL 生成带签名的下载链接 expiresAt 为零值时链接不会过期
+func (s *UploadService) SignedURL(id int64, expiresAt time.Time) string {
+	var expires int64
+	if !expiresAt.IsZero() {
+		expires = expiresAt.Unix()
+	}
+	return fmt.Sprintf("%s/%d?expires=%d&sig=%s", s.cfg().Service.UploadURLPrefix, id, expires, s.sign(id, expires))
+}
+
+// URL 图片会被嵌入问题、回答和头像中 使用不过期的链接 签名只用于防止遍历 id
+// 其他文件的链接在 UploadURLExpire 后过期 过期后可以由上传者重新获取
+func (s *UploadService) URL(upload *model.Upload) string {
+	if strings.HasPrefix(upload.ContentType, "image/") {
+		return s.SignedURL(upload.ID, time.Time{})
+	}
+	return s.SignedURL(upload.ID, time.Now().Add(s.cfg().Service.UploadURLExpire))
+}
+
+// RefreshURL 上传者重新获取下载链接
+func (s *UploadService) RefreshURL(ctx context.Context, userId model.UserId, id int64) (*model.Upload, app_error.AppError) {
+	upload, err := s.dao.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.OwnerId != userId {
+		return nil, app_error.ErrUserPermissionDenied
+	}
+	return upload, nil
+}
+
+// Open 校验签名后打开文件 调用者负责关闭返回的 io.ReadCloser
+func (s *UploadService) Open(ctx context.Context, id int64, expires int64, sig string) (*model.Upload, io.ReadCloser, app_error.AppError) {
+	if !hmac.Equal([]byte(sig), []byte(s.sign(id, expires))) {
+		return nil, nil, app_error.ErrInvalidSignature
+	}
+	if expires != 0 && time.Now().Unix() > expires {
+		return nil, nil, app_error.ErrInvalidSignature
+	}
+	upload, err := s.dao.GetUpload(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc, e := s.store.Open(ctx, upload.Hash)
+	if e != nil {
+		if errors.Is(e, storage.ErrBlobNotFound) {
+			return nil, nil, app_error.ErrUploadNotFound
+		}
+		return nil, nil, app_error.NewInternalError(app_error.ErrCodeBlobStore, e)
+	}
+	return upload, rc, nil
+}
+
+func (s *UploadService) DeleteUpload(ctx context.Context, userId model.UserId, id int64) app_error.AppError {
+	return s.dao.DeleteUpload(ctx, userId, id)
+}
+
+// BindUploads 标记 ownerId 的上传已被引用 被引用的上传不会被清理
+func (s *UploadService) BindUploads(ctx context.Context, ownerId model.UserId, ids ...int64) app_error.AppError {
+	return s.dao.BindUploads(ctx, ownerId, ids)
+}
+
+// uploadIdsInContent 找出内容中引用的上传 id
+func uploadIdsInContent(urlPrefix string, contents ...string) []int64 {
+	pattern := regexp.MustCompile(regexp.QuoteMeta(urlPrefix) + `/(\d+)`)
+	var ids []int64
+	for _, content := range contents {
+		for _, match := range pattern.FindAllStringSubmatch(content, -1) {
+			if id, err := strconv.ParseInt(match[1], 10, 64); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// RunCleaner 按 config.ServiceConfig.UploadCleanupInterval 定时清理未被引用的上传和文件 直到 ctx 结束
+func (s *UploadService) RunCleaner(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg().Service.UploadCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.cleanup(ctx, now)
+		}
+	}
+}
+
+func (s *UploadService) cleanup(ctx context.Context, now time.Time) {
+	timeout, cancel := context.WithTimeout(ctx, s.cfg().Service.UploadCleanupInterval)
+	defer cancel()
+
+	before := now.Add(-s.cfg().Service.UploadOrphanTTL)
+	count, err := s.dao.DeleteOrphanUploads(timeout, before)
+	if err != nil {
+		ul.Error("failed to delete orphan uploads", err.ErrorField()...)
+		return
+	}
+	if count > 0 {
+		ul.Info("orphan uploads deleted", zap.Int("count", count))
+	}
+
+	remove := func(hash string) app_error.AppError {
+		if err := s.store.Delete(timeout, hash); err != nil {
+			return app_error.NewInternalError(app_error.ErrCodeBlobStore, err)
+		}
+		return nil
+	}
+	deleted, err := s.dao.DeleteUnreferencedBlobs(timeout, before, unreferencedBlobBatchSize, remove)
+	if err != nil {
+		ul.Error("failed to delete unreferenced blobs", err.ErrorField()...)
+	}
+	if deleted > 0 {
+		ul.Info("unreferenced blobs deleted", zap.Int("count", deleted))
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"my_zhihu_backend/app/app_error"
 	"my_zhihu_backend/app/cache"
@@ -11,6 +12,7 @@ import (
 	"my_zhihu_backend/app/model"
 	"my_zhihu_backend/app/request"
 	"my_zhihu_backend/app/util"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -20,18 +22,35 @@ import (
 
 var l = log.L().With(zap.String("module", "user service"))
 
-func NewUserService(db *gorm.DB, client *redis.Client) *UserService {
+func NewUserService(db *gorm.DB, client *redis.Client, uploads *UploadService) *UserService {
 	cfg := config.C
 	userDAO := dao.NewUserDAO(cfg, db)
 	u := new(util.Util)
-	bloomFilter := cache.NewBloomFilter("user-filter", client)
-	infoCacher := cache.NewJsonCacher(client, 24*time.Hour, cfg().Prefix.UserInfoPrefix, func(ctx context.Context, args ...any) (*model.User, app_error.AppError) {
+	backend := cache.Backend(cfg().Service.CacheBackend)
+	bloomFilter := cache.NewBloomFilterOn(backend, userBloomFilterName, client)
+	infoCacher := cache.NewJsonCacherOn(backend, client, 24*time.Hour, cfg().Prefix.UserInfoPrefix, func(ctx context.Context, args ...any) (*model.User, app_error.AppError) {
 		return userDAO.GetById(ctx, args[0].(model.UserId))
-	}, bloomFilter)
+	}, bloomFilter, cache.WithCodec(cache.Msgpack), cache.WithVersion(userInfoCacheVersion),
+		cache.WithNegativeCache(cfg().Service.NegativeCacheTTL, app_error.ErrUserNotExists),
+		cache.WithStaleWhileRevalidate(cfg().Service.UserInfoSoftTTL))
+	var localCacher *cache.LocalCacher[model.User]
+	if backend != cache.BackendMemory { // 进程内缓存已经是本地的 不需要再加一层
+		localCacher = cache.NewLocalCacher(infoCacher, client, cfg().Prefix.CacheInvalidationChannel, cfg().Service.LocalCacheSize, cfg().Service.LocalCacheTTL)
+		infoCacher = localCacher
+	}
+	if cfg().Service.HotKeyThreshold > 0 {
+		infoCacher = cache.NewHotKeyCacher(infoCacher, cfg().Service.HotKeyWindow, cfg().Service.HotKeyThreshold)
+	}
 	return &UserService{
 		dao:         userDAO,
 		infoCacher:  infoCacher,
+		asyncCacher: cache.NewAsyncCacher(infoCacher),
+		localCacher: localCacher,
 		bloomFilter: bloomFilter,
+		uploads:     uploads,
+		activities:  NewActivityService(db),
+		responses:   cache.NewTagIndexOn(backend, client, cfg().Prefix.ResponseTagPrefix),
+		usernames:   cache.NewLexIndexOn(backend, client, cfg().Prefix.UsernameIndex),
 		cfg:         cfg,
 		util:        u,
 	}
@@ -42,7 +61,13 @@ type UserService struct {
 	cfg         config.ReadConfigFunc
 	util        *util.Util
 	infoCacher  cache.Cacher[model.User]
-	bloomFilter *cache.BloomFilter
+	asyncCacher *cache.AsyncCacher[model.User] // 异步写入 infoCacher 合并同一时间的多次写入
+	localCacher *cache.LocalCacher[model.User] // 使用 redis 时 infoCacher 外层的进程内缓存
+	bloomFilter cache.BloomFilter
+	uploads     *UploadService
+	activities  *ActivityService
+	responses   cache.TagIndex // 接口响应缓存的标签
+	usernames   cache.LexIndex // 用户名索引 见 user_search.go
 }
 
 func (service *UserService) store(_ context.Context, user model.User) {
@@ -51,7 +76,7 @@ func (service *UserService) store(_ context.Context, user model.User) {
 		defer cancel()
 		key := fmt.Sprintf("%d", user.Id)
 		user.HPassword = ""
-		res := cache.NewAsyncCacher(service.infoCacher).PutChan(timeout, key, user)
+		res := service.asyncCacher.PutChan(timeout, key, user)
 		if err := res.Err(timeout); err != nil {
 			l.Error("failed to cache user info", err.ErrorField()...)
 		} else {
@@ -60,6 +85,86 @@ func (service *UserService) store(_ context.Context, user model.User) {
 	}()
 }
 
+const (
+	userBloomFilterName  = "user-filter" // 用户信息缓存使用的布隆过滤器
+	userInfoCacheVersion = 1             // 用户信息缓存的版本 修改 model.User 的字段时加一
+)
+
+// RegisterBloomSources 设置用户信息缓存的布隆过滤器重建时的数据来源
+func (service *UserService) RegisterBloomSources(m *cache.BloomManager) {
+	m.SetSource(userBloomFilterName, service.userBloomSource)
+}
+
+// FillBloomFilters 从数据库填充用户信息缓存的布隆过滤器 memory 存储下启动时调用 redis 存储下由 BloomManager 重建
+func (service *UserService) FillBloomFilters(ctx context.Context) {
+	if err := cache.FillBloomFilter(ctx, service.bloomFilter, service.userBloomSource); err != nil {
+		l.Error("failed to fill user bloom filter", zap.Error(err))
+	}
+}
+
+// userBloomSource 按 id 顺序读取 after 之后的所有用户 写入用户信息缓存的 key 已删除的用户不会被写入
+func (service *UserService) userBloomSource(ctx context.Context, after int64, add func(values ...any) error) (int64, error) {
+	last := model.UserId(after)
+	for {
+		users, err := service.dao.ListUsernames(ctx, last, usernameIndexBatchSize)
+		if err != nil {
+			return int64(last), err
+		}
+		if len(users) == 0 {
+			return int64(last), nil
+		}
+		keys := make([]any, 0, len(users))
+		for _, u := range users {
+			keys = append(keys, fmt.Sprintf("%s%d", service.cfg().Prefix.UserInfoPrefix, u.Id))
+		}
+		if err := add(keys...); err != nil {
+			return int64(last), err
+		}
+		last = users[len(users)-1].Id
+	}
+}
+
+// WarmUpCache 预热粉丝最多的用户的信息 在启动时执行 失败时只记录日志
+func (service *UserService) WarmUpCache(ctx context.Context) {
+	size := service.cfg().Service.WarmUpUserCount
+	if size <= 0 {
+		return
+	}
+	users, err := service.dao.ListMostFollowedUsers(ctx, size)
+	if err != nil {
+		l.Error("failed to list users to warm up", err.ErrorField()...)
+		return
+	}
+	values := make(map[string]model.User, len(users))
+	for _, user := range users {
+		user.HPassword = ""
+		values[fmt.Sprintf("%d", user.Id)] = user
+	}
+	if err := cache.WarmUp(ctx, service.infoCacher, values); err != nil {
+		l.Error("failed to warm up user info cache", err.ErrorField()...)
+		return
+	}
+	l.Info("user info cache warmed up", zap.Int("count", len(values)))
+}
+
+// RunCacheSubscriber 接收其他实例发出的用户信息缓存失效消息 直到 ctx 结束
+func (service *UserService) RunCacheSubscriber(ctx context.Context) {
+	if service.localCacher != nil {
+		service.localCacher.Run(ctx)
+	}
+}
+
+// purgeResponses 删除与这些用户相关的接口响应缓存 失败时只记录日志 缓存在过期后自然失效
+func (service *UserService) purgeResponses(ctx context.Context, ids ...model.UserId) {
+	tags := make([]string, 0, len(ids))
+	for _, id := range ids {
+		tags = append(tags, cache.UserTag(id))
+	}
+	if err := service.responses.Purge(ctx, tags...); err != nil {
+		l.Error("failed to purge cached responses", err.ErrorField()...)
+	}
+}
+
 func (service *UserService) CreateNewUser(ctx context.Context, req *request.CreateNewUserRequest) (*model.User, app_error.AppError) {
 	if req.Region == "" {
 		req.Region = "unknown"
@@ -71,9 +176,8 @@ func (service *UserService) CreateNewUser(ctx context.Context, req *request.Crea
 		req.Other.Introduction = util.Ptr("nothing here")
 	}
 
-	if req.Settings.HidePrivacy == nil {
-		req.Settings.HidePrivacy = util.Ptr(false)
-	}
+	var settings model.UserSettings
+	applySettings(&settings, &req.Settings)
 
 	if hPasswd, err := service.util.EncryptPassword(req.Password); err != nil {
 		return nil, app_error.NewInternalError(app_error.ErrCodeEncryption, err)
@@ -87,7 +191,7 @@ func (service *UserService) CreateNewUser(ctx context.Context, req *request.Crea
 			FollowingCount: 0,
 			Gender:         &req.Gender,
 			Region:         req.Region,
-			Settings:       model.UserSettings{HidePrivacy: *req.Settings.HidePrivacy},
+			Settings:       settings,
 			Other:          model.UserOtherInfo{Introduction: *req.Other.Introduction, Icon: *req.Other.Icon},
 		}
 		service.store(ctx, user)
@@ -95,18 +199,105 @@ func (service *UserService) CreateNewUser(ctx context.Context, req *request.Crea
 		if err != nil {
 			return nil, err
 		}
+		service.indexUsername(ctx, user.Id, "", user.Username)
 		return service.dao.GetByEmail(ctx, req.Email)
 	}
 }
 
-// DeleteUser 删除用户
+// DeleteUser 删除用户 同时清除该用户、粉丝和关注的人的响应缓存
 func (service *UserService) DeleteUser(ctx context.Context, id int64) app_error.AppError {
-	return service.dao.DeleteUser(ctx, model.UserId(id))
+	user, err := service.dao.GetById(ctx, model.UserId(id))
+	if err != nil {
+		return err
+	}
+	// 删除前读取关注关系 粉丝和关注的人缓存的关注列表中也包含被删除的用户
+	followers, err := service.dao.ListFollowers(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+	followings, err := service.dao.ListFollowings(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+	if err := service.dao.DeleteUser(ctx, model.UserId(id)); err != nil {
+		return err
+	}
+	// 之后的读取从数据库得到不存在 并记录在负缓存中
+	if _, err := service.infoCacher.Invalidate(ctx, fmt.Sprintf("%d", user.Id)); err != nil && !errors.Is(err, app_error.ErrRedisCacheKeyNotExists) {
+		l.Warn("failed to invalidate user info cache", err.ErrorField()...)
+	}
+	service.indexUsername(ctx, user.Id, user.Username, "")
+	service.purgeResponses(ctx, append(append([]model.UserId{user.Id}, followers...), followings...)...)
+	service.activities.Remove(ctx, model.Activity{UserId: user.Id}, model.Activity{Type: model.ActivityFollowUser, TargetUserId: user.Id})
+	return nil
 }
 
 // GetUser 获取用户信息
 func (service *UserService) GetUser(ctx context.Context, id int64) (*model.User, app_error.AppError) {
-	return service.infoCacher.Get(ctx, fmt.Sprintf("%d", id))
+	return service.infoCacher.Get(ctx, fmt.Sprintf("%d", id), model.UserId(id))
+}
+
+// getUsers 批量获取用户 通过 pipeline 一次读取缓存 未命中的用户通过一次 IN 查询从数据库读取并写回缓存
+// 不存在的用户不会出现在结果中
+func (service *UserService) getUsers(ctx context.Context, ids []model.UserId) (map[model.UserId]*model.User, app_error.AppError) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf("%d", id))
+	}
+	cached, err := service.infoCacher.MGet(ctx, keys, service.loadUsers)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[model.UserId]*model.User, len(ids))
+	for i, id := range ids {
+		if cached[i] != nil {
+			users[id] = cached[i]
+		}
+	}
+	return users, nil
+}
+
+// loadUsers 批量读取用户信息缓存未命中时的 BatchFallback 通过一次 IN 查询读取
+func (service *UserService) loadUsers(ctx context.Context, keys []string) (map[string]*model.User, app_error.AppError) {
+	ids := make([]model.UserId, 0, len(keys))
+	for _, key := range keys {
+		if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+			ids = append(ids, model.UserId(id))
+		}
+	}
+	loaded, err := service.dao.ListUsersByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]*model.User, len(loaded))
+	for i := range loaded {
+		loaded[i].HPassword = ""
+		users[fmt.Sprintf("%d", loaded[i].Id)] = &loaded[i]
+	}
+	return users, nil
+}
+
+// BatchGetUsers 批量获取用户信息 按 ids 的顺序返回 重复和不存在的用户会被跳过
+func (service *UserService) BatchGetUsers(ctx context.Context, ids []model.UserId) ([]*model.User, app_error.AppError) {
+	unique := make([]model.UserId, 0, len(ids))
+	seen := make(map[model.UserId]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	users, err := service.getUsers(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*model.User, 0, len(users))
+	for _, id := range unique {
+		if user, ok := users[id]; ok {
+			results = append(results, user)
+		}
+	}
+	return results, nil
 }
 
 // UpdateUser 更新用户信息
@@ -126,9 +317,21 @@ func (service *UserService) UpdateUser(ctx context.Context, id int64, req *reque
 	}
 
 	if req.Settings != nil {
-		fields["settings"] = make(map[string]any)
-		if req.Settings.HidePrivacy != nil {
-			fields["settings"].(map[string]any)["hide_privacy"] = *req.Settings.HidePrivacy
+		// 在当前设置的基础上修改并写入全部字段 同时清除旧版本的 hide_privacy 避免未设置的字段回退到旧设置
+		user, err := service.dao.GetById(ctx, model.UserId(id))
+		if err != nil {
+			return nil, err
+		}
+		settings := user.Settings
+		applySettings(&settings, req.Settings)
+		fields["settings"] = map[string]any{
+			"hide_privacy":          false,
+			"email_visibility":      settings.EmailVisibility,
+			"gender_visibility":     settings.GenderVisibility,
+			"region_visibility":     settings.RegionVisibility,
+			"followers_visibility":  settings.FollowersVisibility,
+			"followings_visibility": settings.FollowingsVisibility,
+			"activity_visibility":   settings.ActivityVisibility,
 		}
 	}
 
@@ -140,6 +343,11 @@ func (service *UserService) UpdateUser(ctx context.Context, id int64, req *reque
 		if req.Other.Icon != nil {
 			fields["other"].(map[string]any)["icon"] = *req.Other.Icon
 		}
+		if req.Other.IconSizes != nil {
+			fields["other"].(map[string]any)["icon_sizes"] = req.Other.IconSizes
+		} else if req.Other.Icon != nil {
+			fields["other"].(map[string]any)["icon_sizes"] = map[string]string(nil) // 直接设置 Icon 时清空旧头像的各尺寸地址
+		}
 	}
 
 	if req.Password != "" {
@@ -154,44 +362,77 @@ func (service *UserService) UpdateUser(ctx context.Context, id int64, req *reque
 		return nil, nil // 没有要更新的字段
 	}
 
+	var oldUsername string
+	if req.Username != "" {
+		old, err := service.dao.GetById(ctx, model.UserId(id))
+		if err != nil {
+			return nil, err
+		}
+		oldUsername = old.Username
+	}
+
 	user, err := service.dao.UpdateFields(ctx, model.UserId(id), fields)
 	if err != nil {
 		return nil, err
 	}
 	service.store(ctx, *user)
+	service.purgeResponses(ctx, user.Id)
+	if req.Username != "" && oldUsername != user.Username {
+		service.indexUsername(ctx, user.Id, oldUsername, user.Username)
+	}
 	return user, err
 }
 
-// SearchUserByUsername 根据用户名搜索用户
-func (service *UserService) SearchUserByUsername(ctx context.Context, username string) ([]int64, app_error.AppError) {
-	users, err := service.dao.ListUserByUsername(ctx, username)
+// FollowUser 关注用户 存在拉黑关系时不能关注
+func (service *UserService) FollowUser(ctx context.Context, followerID, followingID int64) app_error.AppError {
+	blocked, err := service.dao.IsBlocked(ctx, model.UserId(followerID), model.UserId(followingID))
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	ids := make([]int64, 0, len(users))
-	for _, user := range users {
-		ids = append(ids, int64(user.Id))
+	if blocked {
+		return app_error.ErrUserBlocked
 	}
-	return ids, nil
-}
-
-// FollowUser 关注用户
-func (service *UserService) FollowUser(ctx context.Context, followerID, followingID int64) app_error.AppError {
-	return service.dao.FollowUser(ctx, model.UserId(followerID), model.UserId(followingID))
+	if err := service.dao.FollowUser(ctx, model.UserId(followerID), model.UserId(followingID)); err != nil {
+		return err
+	}
+	service.activities.Record(ctx, model.Activity{UserId: model.UserId(followerID), Type: model.ActivityFollowUser, TargetUserId: model.UserId(followingID)})
+	service.purgeResponses(ctx, model.UserId(followerID), model.UserId(followingID))
+	return nil
 }
 
 // UnfollowUser 取消关注用户
 func (service *UserService) UnfollowUser(ctx context.Context, followerID, followingID int64) app_error.AppError {
-	return service.dao.UnfollowUser(ctx, model.UserId(followerID), model.UserId(followingID))
+	if err := service.dao.UnfollowUser(ctx, model.UserId(followerID), model.UserId(followingID)); err != nil {
+		return err
+	}
+	service.activities.Remove(ctx, model.Activity{UserId: model.UserId(followerID), Type: model.ActivityFollowUser, TargetUserId: model.UserId(followingID)})
+	service.purgeResponses(ctx, model.UserId(followerID), model.UserId(followingID))
+	return nil
 }
 
-// GetFollowers 获取粉丝列表
-func (service *UserService) GetFollowers(ctx context.Context, id model.UserId) ([]model.UserId, app_error.AppError) {
-	return service.dao.ListFollowers(ctx, id)
+// BlockUser 拉黑用户 同时解除双方的关注关系
+func (service *UserService) BlockUser(ctx context.Context, blockerID, blockedID int64) app_error.AppError {
+	if blockerID == blockedID {
+		return app_error.NewInputError("cannot block yourself", app_error.ErrCodeInvalidParameters, nil)
+	}
+	if err := service.dao.BlockUser(ctx, model.UserId(blockerID), model.UserId(blockedID)); err != nil {
+		return err
+	}
+	if err := service.dao.UnfollowUser(ctx, model.UserId(blockerID), model.UserId(blockedID)); err != nil {
+		return err
+	}
+	if err := service.dao.UnfollowUser(ctx, model.UserId(blockedID), model.UserId(blockerID)); err != nil {
+		return err
+	}
+	service.activities.Remove(ctx,
+		model.Activity{UserId: model.UserId(blockerID), Type: model.ActivityFollowUser, TargetUserId: model.UserId(blockedID)},
+		model.Activity{UserId: model.UserId(blockedID), Type: model.ActivityFollowUser, TargetUserId: model.UserId(blockerID)},
+	)
+	service.purgeResponses(ctx, model.UserId(blockerID), model.UserId(blockedID))
+	return nil
 }
 
-// GetFollowings 获取关注的用户列表
-func (service *UserService) GetFollowings(ctx context.Context, id model.UserId) ([]model.UserId, app_error.AppError) {
-	return service.dao.ListFollowings(ctx, id)
+// UnblockUser 取消拉黑
+func (service *UserService) UnblockUser(ctx context.Context, blockerID, blockedID int64) app_error.AppError {
+	return service.dao.UnblockUser(ctx, model.UserId(blockerID), model.UserId(blockedID))
 }
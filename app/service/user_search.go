@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"my_zhihu_backend/app/app_error"
+	"my_zhihu_backend/app/model"
+	"my_zhihu_backend/app/response"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// usernameIndexBatchSize 重建用户名索引时每批读取的用户数
+const usernameIndexBatchSize = 1000
+
+// 用户名索引的成员为 "小写用户名\x00id" 按字节序排列 可以按前缀查找
+// \x00 保证同名用户排在一起且不会匹配到更长的用户名
+
+func usernameIndexMember(id model.UserId, username string) string {
+	return fmt.Sprintf("%s\x00%d", strings.ToLower(username), id)
+}
+
+// indexUsername 在用户创建、改名和删除时维护用户名索引 oldUsername 或 newUsername 为空表示不存在
+// 失败时只记录日志 索引可以通过 RebuildUsernameIndex 重建
+func (service *UserService) indexUsername(ctx context.Context, id model.UserId, oldUsername, newUsername string) {
+	var remove, add string
+	if oldUsername != "" {
+		remove = usernameIndexMember(id, oldUsername)
+	}
+	if newUsername != "" {
+		add = usernameIndexMember(id, newUsername)
+	}
+	if err := service.usernames.Update(ctx, remove, add); err != nil {
+		l.Error("failed to update username index", append(err.ErrorField(), zap.Any("user_id", id))...)
+	}
+}
+
+// RebuildUsernameIndex 从数据库重建用户名索引 索引已存在时跳过 启动时调用
+func (service *UserService) RebuildUsernameIndex(ctx context.Context) {
+	if exists, err := service.usernames.Exists(ctx); err != nil {
+		l.Error("failed to check username index", err.ErrorField()...)
+		return
+	} else if exists {
+		return
+	}
+
+	count := 0
+	err := service.usernames.Rebuild(ctx, func(add func(members ...string) error) error {
+		var afterId model.UserId
+		for {
+			users, err := service.dao.ListUsernames(ctx, afterId, usernameIndexBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(users) == 0 {
+				return nil
+			}
+			members := make([]string, 0, len(users))
+			for _, u := range users {
+				members = append(members, usernameIndexMember(u.Id, u.Username))
+			}
+			if err := add(members...); err != nil {
+				return err
+			}
+			count += len(users)
+			afterId = users[len(users)-1].Id
+		}
+	})
+	if err != nil {
+		l.Error("failed to rebuild username index", err.ErrorField()...)
+		return
+	}
+	if count > 0 {
+		l.Info("username index rebuilt", zap.Int("count", count))
+	}
+}
+
+// SearchUsers 分页搜索用户名包含 keyword 的用户 依次按完全匹配、前缀匹配、粉丝数排序
+func (service *UserService) SearchUsers(ctx context.Context, keyword string, page, size int) (*response.ListUsersResponse, app_error.AppError) {
+	users, total, err := service.dao.SearchUsers(ctx, keyword, page, size)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]response.UserSummaryResponse, 0, len(users))
+	for i := range users {
+		records = append(records, *newUserSummary(&users[i]))
+	}
+	return &response.ListUsersResponse{Total: total, Page: page, Size: size, Records: records}, nil
+}
+
+// AutocompleteUsername 按前缀补全用户名 不区分大小写 结果按用户名的字节序排列
+func (service *UserService) AutocompleteUsername(ctx context.Context, prefix string, size int) ([]response.UserSummaryResponse, app_error.AppError) {
+	prefix = strings.ToLower(prefix)
+	members, err := service.usernames.Range(ctx, prefix, size)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]model.UserId, 0, len(members))
+	for _, member := range members {
+		_, rawId, ok := strings.Cut(member, "\x00")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.ParseInt(rawId, 10, 64); err == nil {
+			ids = append(ids, model.UserId(id))
+		}
+	}
+	return service.summarizeUsers(ctx, ids)
+}
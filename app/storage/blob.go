@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore 二进制对象存储 上传的文件以内容的 sha256 作为键 相同内容只存储一份
+// 目前只有本地磁盘实现 后续可以增加 S3 兼容的实现
+type BlobStore interface {
+	// Put 写入对象 键已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open 读取对象 对象不存在时返回 ErrBlobNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete 删除对象 对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+}
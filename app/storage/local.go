@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// LocalBlobStore 将对象保存在本地磁盘 按键的前四位分两级目录 避免单个目录下文件过多
+type LocalBlobStore struct {
+	root string
+}
+
+func NewLocalBlobStore(root string) *LocalBlobStore {
+	return &LocalBlobStore{root: root}
+}
+
+// path 键只允许 sha256 十六进制串 防止路径穿越
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if !keyPattern.MatchString(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, key[:2], key[2:4], key), nil
+}
+
+// Put 先写入同目录下的临时文件再重命名 保证读取时不会看到写了一半的文件
+func (s *LocalBlobStore) Put(_ context.Context, key string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalBlobStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *LocalBlobStore) Exists(_ context.Context, key string) (bool, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *LocalBlobStore) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
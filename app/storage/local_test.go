@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalBlobStore(t.TempDir())
+	content := "hello blob"
+	sum := sha256.Sum256([]byte(content))
+	key := hex.EncodeToString(sum[:])
+
+	exists, err := store.Exists(ctx, key)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, store.Put(ctx, key, strings.NewReader(content)))
+	exists, err = store.Exists(ctx, key)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	rc, err := store.Open(ctx, key)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.NoError(t, rc.Close())
+	assert.Equal(t, content, string(data))
+
+	assert.NoError(t, store.Delete(ctx, key))
+	assert.NoError(t, store.Delete(ctx, key))
+	_, err = store.Open(ctx, key)
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+
+	assert.Error(t, store.Put(ctx, "../../etc/passwd", strings.NewReader(content)))
+}
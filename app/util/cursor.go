@@ -0,0 +1,28 @@
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor 将排序用的时间和 id 编码为不透明的游标 用于按时间倒序的游标分页
+func EncodeCursor(t time.Time, id int64) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d", t.UnixNano(), id))
+}
+
+// DecodeCursor 解析 EncodeCursor 生成的游标
+func DecodeCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	var nano, id int64
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &nano, &id); err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, nano), id, nil
+}
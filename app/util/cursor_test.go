@@ -0,0 +1,19 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	now := time.Now()
+	ts, id, err := DecodeCursor(EncodeCursor(now, 42))
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(ts))
+	assert.Equal(t, int64(42), id)
+
+	_, _, err = DecodeCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
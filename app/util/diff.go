@@ -0,0 +1,197 @@
+package util
+
+import (
+	"strings"
+)
+
+type DiffType string
+
+const (
+	DiffEqual  DiffType = "equal"
+	DiffInsert DiffType = "insert"
+	DiffDelete DiffType = "delete"
+)
+
+// DiffChunk 连续的相同类型的修改
+type DiffChunk struct {
+	Type DiffType `json:"type"`
+	Text string   `json:"text"`
+}
+
+// DiffLines 按行比较 每行保留末尾的换行符 拼接所有 equal 和 insert 片段即可还原新文本
+func DiffLines(a, b string) []DiffChunk {
+	return diffTokens(splitLines(a), splitLines(b))
+}
+
+// DiffChars 按字符(rune)比较
+func DiffChars(a, b string) []DiffChunk {
+	return diffTokens(splitChars(a), splitChars(b))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func splitChars(s string) []string {
+	chars := make([]string, 0, len(s))
+	for _, r := range s {
+		chars = append(chars, string(r))
+	}
+	return chars
+}
+
+func diffTokens(a, b []string) []DiffChunk {
+	var chunks []DiffChunk
+	var text strings.Builder
+	d := &differ{budget: diffMaxCost}
+	ops := d.diff(a, b, nil)
+	for i, op := range ops {
+		text.WriteString(op.text)
+		if i == len(ops)-1 || ops[i+1].typ != op.typ {
+			chunks = append(chunks, DiffChunk{Type: op.typ, Text: text.String()})
+			text.Reset()
+		}
+	}
+	return chunks
+}
+
+type diffOp struct {
+	typ  DiffType
+	text string
+}
+
+// diffMaxCost 一次比较最多搜索的对角线步数 超过后剩余部分按整段删除再插入处理
+// 完全不同的长文本的最短编辑距离很大 不限制时耗时为 O((N+M)D)
+const diffMaxCost = 1 << 22
+
+// differ 使用线性空间的 Myers 差分算法 每次找到中间的 snake 后分成两半递归
+// 空间复杂度 O(N+M) 搜索的总步数受 budget 限制 用完后结果不再是最短的编辑序列
+type differ struct {
+	budget int
+}
+
+func (d *differ) diff(a, b []string, ops []diffOp) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{DiffEqual, a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		ops = appendOps(ops, DiffInsert, b)
+	case len(b) == 0:
+		ops = appendOps(ops, DiffDelete, a)
+	default:
+		if x, y, ok := d.bisect(a, b); ok {
+			ops = d.diff(a[:x], b[:y], ops)
+			ops = d.diff(a[x:], b[y:], ops)
+		} else {
+			ops = appendOps(ops, DiffDelete, a)
+			ops = appendOps(ops, DiffInsert, b)
+		}
+	}
+	return appendOps(ops, DiffEqual, common)
+}
+
+func appendOps(ops []diffOp, typ DiffType, tokens []string) []diffOp {
+	for _, token := range tokens {
+		ops = append(ops, diffOp{typ, token})
+	}
+	return ops
+}
+
+// bisect 从两端同时搜索 返回最短编辑路径中间 snake 的位置
+// 两端的搜索没有重叠或 budget 用完时返回 false
+func (d *differ) bisect(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	v1 := make([]int, 2*maxD+2)
+	v2 := make([]int, 2*maxD+2)
+	for i := range v1 {
+		v1[i], v2[i] = -1, -1
+	}
+	v1[offset+1], v2[offset+1] = 0, 0
+	delta := n - m
+	front := delta%2 != 0 // delta 为奇数时在正向搜索中检查重叠 否则在反向搜索中检查
+	// 超出边界的对角线不再搜索
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+
+	for step := 0; step < maxD; step++ {
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			if d.budget--; d.budget < 0 {
+				return 0, 0, false
+			}
+			i := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[i-1] < v1[i+1]) {
+				x1 = v1[i+1]
+			} else {
+				x1 = v1[i-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[i] = x1
+			if x1 > n {
+				k1end += 2
+			} else if y1 > m {
+				k1start += 2
+			} else if front {
+				j := offset + delta - k1
+				if j >= 0 && j < len(v2) && v2[j] != -1 && x1 >= n-v2[j] {
+					return x1, y1, true
+				}
+			}
+		}
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			if d.budget--; d.budget < 0 {
+				return 0, 0, false
+			}
+			i := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[i-1] < v2[i+1]) {
+				x2 = v2[i+1]
+			} else {
+				x2 = v2[i-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[i] = x2
+			if x2 > n {
+				k2end += 2
+			} else if y2 > m {
+				k2start += 2
+			} else if !front {
+				j := offset + delta - k2
+				if j >= 0 && j < len(v1) && v1[j] != -1 {
+					x1 := v1[j]
+					if x1 >= n-x2 {
+						return x1, x1 - (j - offset), true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
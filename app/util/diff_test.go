@@ -0,0 +1,96 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// restore 通过差分结果还原新旧文本
+func restore(chunks []DiffChunk) (oldText, newText string) {
+	var o, n strings.Builder
+	for _, chunk := range chunks {
+		switch chunk.Type {
+		case DiffEqual:
+			o.WriteString(chunk.Text)
+			n.WriteString(chunk.Text)
+		case DiffDelete:
+			o.WriteString(chunk.Text)
+		case DiffInsert:
+			n.WriteString(chunk.Text)
+		}
+	}
+	return o.String(), n.String()
+}
+
+func TestDiffLines(t *testing.T) {
+	a := "first\nsecond\nthird\n"
+	b := "first\n2nd\nthird\nfourth"
+
+	chunks := DiffLines(a, b)
+	assert.Equal(t, []DiffChunk{
+		{Type: DiffEqual, Text: "first\n"},
+		{Type: DiffDelete, Text: "second\n"},
+		{Type: DiffInsert, Text: "2nd\n"},
+		{Type: DiffEqual, Text: "third\n"},
+		{Type: DiffInsert, Text: "fourth"},
+	}, chunks)
+
+	oldText, newText := restore(chunks)
+	assert.Equal(t, a, oldText)
+	assert.Equal(t, b, newText)
+}
+
+func TestDiffChars(t *testing.T) {
+	cases := [][2]string{
+		{"", ""},
+		{"", "新增内容"},
+		{"删除内容", ""},
+		{"我的知乎后端", "我的知乎前端"},
+		{"abcabba", "cbabac"},
+	}
+	for _, c := range cases {
+		oldText, newText := restore(DiffChars(c[0], c[1]))
+		assert.Equal(t, c[0], oldText)
+		assert.Equal(t, c[1], newText)
+	}
+
+	assert.Equal(t, []DiffChunk{
+		{Type: DiffEqual, Text: "我的知乎"},
+		{Type: DiffDelete, Text: "后"},
+		{Type: DiffInsert, Text: "前"},
+		{Type: DiffEqual, Text: "端"},
+	}, DiffChars("我的知乎后端", "我的知乎前端"))
+}
+
+func TestDiffCharsLarge(t *testing.T) {
+	// 64KB 完全不同的内容 超过搜索上限后按整段删除再插入处理
+	a := strings.Repeat("a", 64<<10)
+	b := strings.Repeat("b", 64<<10)
+	start := time.Now()
+	chunks := DiffChars(a, b)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, []DiffChunk{
+		{Type: DiffDelete, Text: a},
+		{Type: DiffInsert, Text: b},
+	}, chunks)
+
+	// 大部分相同时仍然得到最短的编辑序列
+	c := a[:32<<10] + "中间" + a[32<<10:]
+	oldText, newText := restore(DiffChars(a, c))
+	assert.Equal(t, a, oldText)
+	assert.Equal(t, c, newText)
+	assert.Equal(t, []DiffChunk{
+		{Type: DiffEqual, Text: a[:32<<10]},
+		{Type: DiffInsert, Text: "中间"},
+		{Type: DiffEqual, Text: a[32<<10:]},
+	}, DiffChars(a, c))
+
+	x := strings.Repeat("ab\n", 20000)
+	y := strings.Repeat("cd\n", 20000)
+	oldText, newText = restore(DiffLines(x, y))
+	assert.Equal(t, x, oldText)
+	assert.Equal(t, y, newText)
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// exifOrientationTag EXIF 中记录拍摄方向的标签
+const exifOrientationTag = 0x0112
+
+// ReadOrientation 读取 jpeg 中 EXIF 的 Orientation 值 1 到 8 不是 jpeg 或者没有记录方向时返回 1
+// 只读取图片数据之前的标记段 读取后 r 的位置不确定 需要调用者重新定位
+func ReadOrientation(r io.Reader) int {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return 1
+	}
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+		if marker[1] == 0xDA || marker[1] == 0xD9 { // 图片数据开始 EXIF 只会出现在这之前
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(marker[2:]))
+		if length < 2 {
+			return 1
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return 1
+		}
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+	}
+}
+
+// tiffOrientation 在 EXIF 的 TIFF 结构的第一个 IFD 中查找 Orientation
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := range count {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+		if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+			return v
+		}
+		return 1
+	}
+	return 1
+}
+
+// ApplyOrientation 按 EXIF 的 Orientation 旋转或翻转图片 使其按正常方向显示 orientation 为 1 或无效值时原样返回
+func ApplyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 { // 5 到 8 需要旋转 90 度 宽高互换
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-x, y
+			case 3: // 旋转 180 度
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90 度
+				sx, sy = y, h-1-x
+			case 7: // 沿副对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针旋转 90 度
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidHandleFormat = errors.New("handle must be 3-30 letters, digits or underscores and start with a letter")
+	ErrReservedHandle      = errors.New("handle is reserved")
+)
+
+// handlePattern 字母开头 只包含字母、数字和下划线 下划线不能连续出现也不能出现在结尾
+var handlePattern = regexp.MustCompile(`^[a-z][a-z0-9]*(?:_[a-z0-9]+)*$`)
+
+const (
+	minHandleLength = 3
+	maxHandleLength = 30
+)
+
+// reservedHandles 与路由、系统账号冲突或容易被用来冒充官方的 handle
+var reservedHandles = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "official": true,
+	"moderator": true, "support": true, "help": true, "security": true, "staff": true,
+	"me": true, "api": true, "auth": true, "users": true, "uploads": true, "settings": true,
+	"notifications": true, "questions": true, "answers": true, "search": true, "batch": true,
+	"autocomplete": true, "recommendations": true, "login": true, "logout": true,
+	"null": true, "undefined": true, "zhihu": true, "everyone": true, "here": true,
+}
+
+// NormalizeHandle handle 不区分大小写 统一转换为小写保存和比较
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimSpace(handle))
+}
+
+// ValidateHandle 校验已经 NormalizeHandle 的 handle
+func ValidateHandle(handle string) error {
+	if len(handle) < minHandleLength || len(handle) > maxHandleLength || !handlePattern.MatchString(handle) {
+		return ErrInvalidHandleFormat
+	}
+	if reservedHandles[handle] {
+		return ErrReservedHandle
+	}
+	return nil
+}
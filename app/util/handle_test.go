@@ -0,0 +1,36 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateHandle(t *testing.T) {
+	cases := []struct {
+		handle string
+		err    error
+	}{
+		{"alice", nil},
+		{"bob_2024", nil},
+		{"a_b_c", nil},
+		{"ab", ErrInvalidHandleFormat},
+		{"1alice", ErrInvalidHandleFormat},
+		{"alice_", ErrInvalidHandleFormat},
+		{"al__ice", ErrInvalidHandleFormat},
+		{"al-ice", ErrInvalidHandleFormat},
+		{"Alice", ErrInvalidHandleFormat},
+		{"abcdefghijabcdefghijabcdefghijk", ErrInvalidHandleFormat},
+		{"admin", ErrReservedHandle},
+	}
+	for _, c := range cases {
+		if err := ValidateHandle(c.handle); !errors.Is(err, c.err) {
+			t.Errorf("ValidateHandle(%q) = %v, want %v", c.handle, err, c.err)
+		}
+	}
+}
+
+func TestNormalizeHandle(t *testing.T) {
+	if got := NormalizeHandle("  Alice_01 "); got != "alice_01" {
+		t.Errorf("NormalizeHandle = %q", got)
+	}
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var ErrImageTooLarge = errors.New("image dimensions too large")
+
+// DecodeImage 解码图片 解码前先读取尺寸 拒绝像素数超过 maxPixels 的图片 防止解压炸弹占用过多内存
+// jpeg 按 EXIF 的 Orientation 旋转为正常方向 重新编码时 EXIF 会被去除 不能再依赖客户端处理
+func DecodeImage(r io.ReadSeeker, maxPixels int) (image.Image, error) {
+	orientation := ReadOrientation(r)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyOrientation(img, orientation), nil
+}
+
+// CropSquare 以中心为基准裁剪为正方形
+func CropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// Resize 缩放为 size x size
+func Resize(img image.Image, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// EncodeImage 重新编码图片 不会保留原图的 EXIF 等元数据 不透明的图片使用 jpeg 其他使用 png
+func EncodeImage(img *image.RGBA) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if img.Opaque() {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
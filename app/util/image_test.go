@@ -0,0 +1,95 @@
+package util
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvatarPipeline(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for x := 0; x < 300; x++ {
+		for y := 0; y < 200; y++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, src))
+
+	img, err := DecodeImage(bytes.NewReader(buf.Bytes()), 300*200)
+	assert.NoError(t, err)
+	_, err = DecodeImage(bytes.NewReader(buf.Bytes()), 300*200-1)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+
+	square := CropSquare(img)
+	assert.Equal(t, image.Rect(0, 0, 200, 200), square.Bounds())
+	assert.Equal(t, color.RGBA{R: 50, G: 0, B: 100, A: 255}, square.At(0, 0)) // 水平方向居中裁剪
+
+	resized := Resize(square, 32)
+	assert.Equal(t, image.Rect(0, 0, 32, 32), resized.Bounds())
+	data, err := EncodeImage(resized)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/jpeg", http.DetectContentType(data))
+
+	resized.Set(0, 0, color.RGBA{}) // 有透明像素时使用 png
+	data, err = EncodeImage(resized)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", http.DetectContentType(data))
+}
+
+// withOrientation 在 jpeg 的 SOI 之后插入只包含 Orientation 的 EXIF 段
+func withOrientation(data []byte, orientation byte) []byte {
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, 0, 0, 0, 0, 0, 0}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(payload) + 2)}, payload...)
+	return append(append(slices.Clone(data[:2]), segment...), data[2:]...)
+}
+
+func TestDecodeImageOrientation(t *testing.T) {
+	// 左半边红色 右半边蓝色
+	src := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 16; y++ {
+			if x < 16 {
+				src.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				src.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, src, &jpeg.Options{Quality: 100}))
+	assert.Equal(t, 1, ReadOrientation(bytes.NewReader(buf.Bytes())))
+
+	red := func(c color.Color) bool {
+		r, _, b, _ := c.RGBA()
+		return r > b
+	}
+	cases := []struct {
+		orientation byte
+		bounds      image.Rectangle
+		redAt       image.Point
+		blueAt      image.Point
+	}{
+		{1, image.Rect(0, 0, 32, 16), image.Pt(4, 8), image.Pt(28, 8)},
+		{3, image.Rect(0, 0, 32, 16), image.Pt(28, 8), image.Pt(4, 8)},
+		{6, image.Rect(0, 0, 16, 32), image.Pt(8, 4), image.Pt(8, 28)}, // 顺时针旋转后左边在上
+		{8, image.Rect(0, 0, 16, 32), image.Pt(8, 28), image.Pt(8, 4)},
+	}
+	for _, c := range cases {
+		data := withOrientation(buf.Bytes(), c.orientation)
+		assert.Equal(t, int(c.orientation), ReadOrientation(bytes.NewReader(data)))
+		img, err := DecodeImage(bytes.NewReader(data), 32*16)
+		assert.NoError(t, err)
+		assert.Equal(t, c.bounds, img.Bounds())
+		assert.True(t, red(img.At(c.redAt.X, c.redAt.Y)), "orientation %d", c.orientation)
+		assert.False(t, red(img.At(c.blueAt.X, c.blueAt.Y)), "orientation %d", c.orientation)
+	}
+}
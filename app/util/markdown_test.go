@@ -0,0 +1,21 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	html, err := RenderMarkdown("# 标题\n\n**加粗** [链接](https://example.com)")
+	assert.NoError(t, err)
+	assert.Contains(t, html, "<h1>标题</h1>")
+	assert.Contains(t, html, "<strong>加粗</strong>")
+	assert.Contains(t, html, `rel="nofollow noopener"`)
+
+	html, err = RenderMarkdown("<script>alert(1)</script>\n\n[x](javascript:alert(1)) <img src=x onerror=alert(1)>")
+	assert.NoError(t, err)
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "javascript:")
+	assert.NotContains(t, html, "onerror")
+}
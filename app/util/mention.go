@@ -0,0 +1,30 @@
+package util
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// mentionPattern @ 前面不能是字母、数字等字符 避免把邮箱地址当作提及
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])(@([\p{L}\p{N}_-]{1,50}))`)
+
+// MentionSpan 内容中的一处 @username Start End 为 @username 按字符(rune)计算的起止位置 不包含 End
+type MentionSpan struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// ParseMentions 找出内容中所有的 @username
+func ParseMentions(content string) []MentionSpan {
+	var spans []MentionSpan
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		start := utf8.RuneCountInString(content[:m[2]])
+		spans = append(spans, MentionSpan{
+			Username: content[m[4]:m[5]],
+			Start:    start,
+			End:      start + utf8.RuneCountInString(content[m[2]:m[3]]),
+		})
+	}
+	return spans
+}
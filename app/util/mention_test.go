@@ -0,0 +1,17 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	assert.Equal(t, []MentionSpan{
+		{Username: "alice", Start: 0, End: 6},
+		{Username: "张三", Start: 10, End: 13},
+		{Username: "bob_1", Start: 14, End: 20},
+	}, ParseMentions("@alice 你好 @张三 @bob_1, 欢迎"))
+
+	assert.Empty(t, ParseMentions("联系 someone@example.com 或 @@"))
+}
@@ -51,3 +51,12 @@ func (_ *Util) GenerateSnowflakeID() int64 {
 func Ptr[T any](v T) *T {
 	return &v
 }
+
+// Deref 返回指针指向的值 指针为 nil 时返回零值
+func Deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
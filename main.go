@@ -1,30 +1,56 @@
 package main
 
 import (
+	"context"
+	"my_zhihu_backend/app/cache"
 	"my_zhihu_backend/app/config"
 	"my_zhihu_backend/app/controller"
-	_ "my_zhihu_backend/app/log"
+	"my_zhihu_backend/app/log"
 	"my_zhihu_backend/app/middleware"
 	"my_zhihu_backend/app/repository"
 	"my_zhihu_backend/app/router"
 	"my_zhihu_backend/app/service"
+	"my_zhihu_backend/app/storage"
+	"net/http"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 func main() {
 	config.InitConfig()
-	redisClient := repository.NewRedisClient(config.C)
+	cache.RedisBreaker.Configure(config.C().Service.CacheBreakerThreshold, config.C().Service.CacheBreakerCooldown)
+	backend := cache.Backend(config.C().Service.CacheBackend)
+	var redisClient *redis.Client
+	if backend != cache.BackendMemory { // memory 模式下所有用到 redis 的地方都换成了进程内的实现
+		redisClient = repository.NewRedisClient(config.C)
+	}
 	db := repository.NewMysqlDBConn(config.C)
 	repository.AutoMigrate(db)
-	userService := service.NewUserService(db, redisClient)
+	uploadService := service.NewUploadService(db, storage.NewLocalBlobStore(config.C().Service.UploadDir))
+	userService := service.NewUserService(db, redisClient, uploadService)
 	authService := service.NewAuthService(db, redisClient)
-	articleService := service.NewArticleService(db)
+	articleService := service.NewArticleService(db, redisClient)
+	notificationService := service.NewNotificationService(db)
+	systemService := service.NewSystemService(db, redisClient)
+	go articleService.RunPublishScheduler(context.Background())
+	go articleService.RunViewFlusher(context.Background())
+	go uploadService.RunCleaner(context.Background())
+	go userService.RebuildUsernameIndex(context.Background())
+	go userService.RunCacheSubscriber(context.Background())
+	go userService.WarmUpCache(context.Background())
+	go articleService.WarmUpCache(context.Background())
 	userController := controller.NewUserController(userService)
 	authController := controller.NewAuthController(authService)
 	articleController := controller.NewArticleController(articleService)
+	notificationController := controller.NewNotificationController(notificationService)
+	uploadController := controller.NewUploadController(uploadService)
+	systemController := controller.NewSystemController(systemService)
 
 	r := gin.Default()
 	r.Use(
@@ -44,6 +70,29 @@ func main() {
 	router.InitAuthRouter(r, authController, authService)
 	router.InitUsersRouter(r, userController, authService, redisClient)
 	router.InitArticleRouter(r, articleController, authService)
+	router.InitNotificationRouter(r, notificationController, authService)
+	router.InitUploadRouter(r, uploadController, authService)
+	router.InitSystemRouter(r, systemController, authService)
+	if backend != cache.BackendMemory {
+		// 所有布隆过滤器都已创建 启动后台重建
+		bloomManager := cache.NewBloomManager(redisClient, config.C().Service.BloomRotateInterval,
+			config.C().Service.BloomFalsePositiveThreshold, config.C().Service.BloomCheckInterval)
+		userService.RegisterBloomSources(bloomManager)
+		articleService.RegisterBloomSources(bloomManager)
+		go bloomManager.Run(context.Background())
+	} else {
+		// 进程内的布隆过滤器启动时是空的 在提供服务前从数据库填充 否则已有的数据都会被拦截
+		userService.FillBloomFilters(context.Background())
+		articleService.FillBloomFilters(context.Background())
+	}
+	if addr := config.C().App.MetricsAddr; addr != "" {
+		prometheus.MustRegister(cache.Collector{})
+		go func() {
+			if err := http.ListenAndServe(addr, promhttp.Handler()); err != nil {
+				log.L().Error("metrics server stopped", zap.Error(err))
+			}
+		}()
+	}
 	err := r.Run(config.C().App.ListenAddr)
 	if err != nil {
 		return